@@ -0,0 +1,107 @@
+package access
+
+import (
+	"box/config"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// FileName is the name of the file recording a project's grants, within its configuration directory
+const FileName = "access.yml"
+
+var NameRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9._@-]{0,63}$")
+
+// Grant records a team member's access to the project's droplet
+type Grant struct {
+	PublicKey   string `yaml:"public_key"`
+	Fingerprint string `yaml:"fingerprint"`
+	// ID of the key once registered with the account, from which a rebuilt droplet authorizes the same set
+	PublicKeyID int       `yaml:"public_key_id,omitempty"`
+	Granted     time.Time `yaml:"granted"`
+	// Set while the grant holds a project bundle's key, which the droplet stops accepting once it expires
+	Expires time.Time `yaml:"expires,omitempty"`
+}
+
+// Store holds the grants of a project, by team member name
+type Store struct {
+	filename string
+	Grants   map[string]*Grant `yaml:"grants"`
+}
+
+// Load loads the grants of the project, of which there are none until the first is recorded
+func Load(projectName string) (*Store, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	projectDir := filepath.Join(configDir, projectName)
+	if _, err := os.Stat(projectDir); err != nil {
+		return nil, fmt.Errorf("Project %v has not been initialized, run box init first", projectName)
+	}
+
+	store := &Store{filename: filepath.Join(projectDir, FileName)}
+	data, err := ioutil.ReadFile(store.filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, store); err != nil {
+			return nil, fmt.Errorf("Unable to process %v: %w", store.filename, err)
+		}
+	}
+	if store.Grants == nil {
+		store.Grants = map[string]*Grant{}
+	}
+
+	return store, nil
+}
+
+// Save saves the grants to disk
+func (store *Store) Save() error {
+	data, err := yaml.Marshal(store)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(store.filename, data, os.FileMode(0600))
+}
+
+// Names returns the names of the team members granted access, sorted
+func (store *Store) Names() []string {
+	names := []string{}
+	for name := range store.Grants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Expired returns whether the grant held a project bundle's key which expired before it was imported
+func (grant *Grant) Expired() bool {
+	return !grant.Expires.IsZero() && grant.Expires.Before(time.Now())
+}
+
+// AuthorizedKey returns the team member's line in the admin user's authorized keys
+func (store *Store) AuthorizedKey(name string) string {
+	grant := store.Grants[name]
+	if grant.Expires.IsZero() {
+		return fmt.Sprintf("%v %v", grant.PublicKey, Comment(name))
+	}
+
+	// Droplets keep UTC time
+	return fmt.Sprintf("expiry-time=\"%v\" %v %v", grant.Expires.UTC().Format("200601021504"), grant.PublicKey, Comment(name))
+}
+
+// Comment returns the comment which marks the team member's line in the droplet's authorized keys
+func Comment(name string) string {
+	return fmt.Sprintf("box-access-%v", name)
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"box/access"
+	"box/api/digitalocean"
+	"box/api/digitalocean/sshkeys"
+	"box/config"
+	"box/sshconn"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type AccessCmd struct {
+	Grant  AccessGrantCmd  `cmd:"" help:"Give a team member SSH access to the project's droplet, as the admin user"`
+	Revoke AccessRevokeCmd `cmd:"" help:"Remove a team member's SSH access to the project's droplet"`
+	Ls     AccessLsCmd     `cmd:"" help:"List the team members granted SSH access to the project's droplet"`
+}
+
+type AccessGrantCmd struct {
+	Name          string `arg:"" help:"Team member name"`
+	PublicKeyFile string `arg:"" type:"existingfile" help:"Team member's public key file"`
+	Project       string `help:"Project name, defaults to the project in the current directory"`
+}
+
+type AccessRevokeCmd struct {
+	Name    string `arg:"" help:"Team member name"`
+	Project string `help:"Project name, defaults to the project in the current directory"`
+}
+
+type AccessLsCmd struct {
+	Project string `help:"Project name, defaults to the project in the current directory"`
+}
+
+// connectDroplet returns an SSH connection to the project's droplet running commands as root, with the project's key
+func connectDroplet(cfg *config.Config) (*sshconn.SSHConn, error) {
+	signer, err := sshconn.GetSigner(cfg.PrivateKeyFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	return dialDroplet(cfg, signer)
+}
+
+// dialDroplet returns an SSH connection to the project's droplet running commands as root, either as root itself
+// or as the admin user through sudo, which is all a team member's key is authorized for
+func dialDroplet(cfg *config.Config, signer *sshconn.SSHSigner) (*sshconn.SSHConn, error) {
+	knownHostsFile, err := cfg.KnownHostsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sshconn.NewSSHConn(signer, "root", cfg.DropletPublicIP, knownHostsFile)
+	if err == nil {
+		return conn, nil
+	}
+	if !strings.Contains(err.Error(), "unable to authenticate") {
+		return nil, err
+	}
+	conn, adminErr := sshconn.NewSSHConn(signer, adminUser, cfg.DropletPublicIP, knownHostsFile)
+	if adminErr != nil {
+		return nil, err
+	}
+	conn.UseSudo()
+
+	return conn, nil
+}
+
+// registerKey returns the account's ID of the public key, registering it under the name if it's not among the
+// account's keys
+func registerKey(doSvc *digitalocean.Service, keys []sshkeys.SSHKey, name string, publicKey ssh.PublicKey, authorizedKey string) (int, error) {
+	for _, key := range keys {
+		accountKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key.PublicKey))
+		if err == nil && bytes.Equal(accountKey.Marshal(), publicKey.Marshal()) {
+			return key.ID, nil
+		}
+	}
+
+	createdKey, err := sshkeys.Create(doSvc, name, authorizedKey)
+	if err != nil {
+		return 0, err
+	}
+
+	return createdKey.ID, nil
+}
+
+// registeredAdminKeys returns the lines of the admin user's authorized keys on a new droplet, one for each team
+// member granted access, taken from the keys registered with the account.  Keys which were never registered are
+// registered first.  Keys from project bundles aren't, since the droplet only accepts them until they expire.
+func registeredAdminKeys(doSvc *digitalocean.Service, store *access.Store, projectName string) ([]string, error) {
+	keys, err := sshkeys.GetAll(doSvc)
+	if err != nil {
+		return nil, err
+	}
+	keysByID := map[int]string{}
+	for _, key := range keys {
+		keysByID[key.ID] = key.PublicKey
+	}
+
+	adminKeys := []string{}
+	registered := false
+	for _, name := range store.Names() {
+		grant := store.Grants[name]
+		if grant.Expired() {
+			continue
+		}
+		if !grant.Expires.IsZero() {
+			adminKeys = append(adminKeys, store.AuthorizedKey(name))
+			continue
+		}
+
+		if _, ok := keysByID[grant.PublicKeyID]; !ok {
+			publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(grant.PublicKey))
+			if err != nil {
+				return nil, fmt.Errorf("The key granted to %v is invalid: %w", name, err)
+			}
+			fmt.Printf("Registering the key of %v...", name)
+			grant.PublicKeyID, err = registerKey(doSvc, keys, fmt.Sprintf("box-%v-%v", projectName, name), publicKey, grant.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Println("Done")
+			keysByID[grant.PublicKeyID] = grant.PublicKey
+			registered = true
+		}
+
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(keysByID[grant.PublicKeyID]))
+		if err != nil {
+			return nil, fmt.Errorf("The account's key for %v is invalid: %w", name, err)
+		}
+		adminKeys = append(adminKeys, fmt.Sprintf("%v %v", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), access.Comment(name)))
+	}
+
+	if registered {
+		if err := store.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return adminKeys, nil
+}
+
+func (cmd *AccessGrantCmd) Run() error {
+	if !access.NameRe.Match([]byte(cmd.Name)) {
+		return fmt.Errorf("Team member names must only contain letters, numbers, or any of ._@- and be at most 64 characters long")
+	}
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+	store, err := access.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+	if _, exists := store.Grants[cmd.Name]; exists {
+		return fmt.Errorf("%v has already been granted access to project %v, revoke it first to change their key", cmd.Name, cfg.ProjectName)
+	}
+
+	data, err := ioutil.ReadFile(cmd.PublicKeyFile)
+	if err != nil {
+		return err
+	}
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return fmt.Errorf("Unable to process public key file %v: %w", cmd.PublicKeyFile, err)
+	}
+	if _, ok := publicKey.(ssh.CryptoPublicKey); !ok {
+		return fmt.Errorf("Public key file %v doesn't hold a plain public key", cmd.PublicKeyFile)
+	}
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+
+	apiToken, err := cfg.APIToken()
+	if err != nil {
+		return err
+	}
+	doSvc := digitalocean.NewService(apiToken)
+	// Registered so that a rebuilt droplet authorizes every granted key
+	fmt.Print("Registering the key with the account...")
+	keys, err := sshkeys.GetAll(doSvc)
+	if err != nil {
+		return err
+	}
+	publicKeyID, err := registerKey(doSvc, keys, fmt.Sprintf("box-%v-%v", cfg.ProjectName, cmd.Name), publicKey, authorizedKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	if cfg.DropletPublicIP == "" {
+		fmt.Println("The project has no droplet yet, it will accept the key once created with box mkremote")
+	} else {
+		fmt.Printf("Authorizing the key of %v on the droplet...", cmd.Name)
+		conn, err := connectDroplet(cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := conn.AuthorizeKey(adminUser, fmt.Sprintf("%v %v", authorizedKey, access.Comment(cmd.Name))); err != nil {
+			return err
+		}
+		fmt.Println("Done")
+	}
+
+	store.Grants[cmd.Name] = &access.Grant{
+		PublicKey:   authorizedKey,
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		PublicKeyID: publicKeyID,
+		Granted:     time.Now().UTC(),
+	}
+
+	return store.Save()
+}
+
+func (cmd *AccessRevokeCmd) Run() error {
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+	store, err := access.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+	if _, exists := store.Grants[cmd.Name]; !exists {
+		return fmt.Errorf("%v hasn't been granted access to project %v", cmd.Name, cfg.ProjectName)
+	}
+
+	if cfg.DropletPublicIP != "" {
+		fmt.Printf("Removing the key of %v from the droplet...", cmd.Name)
+		conn, err := connectDroplet(cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := conn.RevokeKeyByComment(adminUser, access.Comment(cmd.Name)); err != nil {
+			return err
+		}
+		// Droplets were once created with every granted key as their own, which are root's
+		publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(store.Grants[cmd.Name].PublicKey))
+		if err != nil {
+			return err
+		}
+		if err := conn.RevokeKey("root", publicKey); err != nil {
+			return err
+		}
+		fmt.Println("Done")
+	}
+
+	// The key stays registered with the account, where other projects may use it, but a rebuilt droplet no
+	// longer authorizes it
+	delete(store.Grants, cmd.Name)
+
+	return store.Save()
+}
+
+func (cmd *AccessLsCmd) Run() error {
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+	store, err := access.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tGRANTED\tEXPIRES\tFINGERPRINT")
+	for _, name := range store.Names() {
+		grant := store.Grants[name]
+		// Only bundle keys which weren't imported yet expire
+		expires := "-"
+		if grant.Expired() {
+			expires = "expired"
+		} else if !grant.Expires.IsZero() {
+			expires = grant.Expires.Local().Format(time.RFC822)
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", name, grant.Granted.Local().Format(time.RFC822), expires, grant.Fingerprint)
+	}
+	writer.Flush()
+
+	return nil
+}
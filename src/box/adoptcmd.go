@@ -0,0 +1,358 @@
+package main
+
+import (
+	"box/api/digitalocean"
+	"box/api/digitalocean/blockstorage"
+	"box/api/digitalocean/domain"
+	"box/api/digitalocean/droplet"
+	"box/api/digitalocean/firewall"
+	"box/api/digitalocean/snapshot"
+	"box/api/digitalocean/sshkeys"
+	"box/api/digitalocean/tag"
+	"box/config"
+	"box/credentials"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type AdoptCmd struct {
+	Name        string `arg:"" help:"Project name"`
+	Context     string `help:"Context the project belongs to.  Defaults to the current context"`
+	Credential  string `help:"Name of the credential holding the account's API token.  Defaults to that of the context, or the default credential"`
+	Domain      string `help:"Bare domain name, when it can't be found from the droplet's domain record"`
+	Email       string `help:"TLS certificate registration email for use with letsencrypt.  Defaults to that of the context"`
+	PrivateKey  string `help:"Private key full path, whose public key is alongside it with a .pub extension.  Defaults to that of the context, or ~/.ssh/id_rsa"`
+	Region      string `help:"Deployment region, when no droplet is found.  Defaults to that of the context"`
+	DropletSlug string `help:"Droplet slug, when no droplet is found.  Defaults to that of the context"`
+	VolumeSize  int    `help:"Volume size in gigabytes, when no block storage volume is found"`
+}
+
+// Run rebuilds the configuration of a project from the DigitalOcean resources tagged with it, for when the
+// configuration file was lost or the project is operated from another machine
+func (cmd *AdoptCmd) Run() error {
+	if _, err := config.Load(cmd.Name); err == nil {
+		return fmt.Errorf("Project %v already exists, there's nothing to adopt", cmd.Name)
+	}
+
+	acct, err := resolveAccount(cmd.Context, cmd.Credential)
+	if err != nil {
+		return err
+	}
+	context := acct.context
+	doSvc := acct.doSvc
+
+	cfg := &config.Config{
+		ProjectName:        cmd.Name,
+		Context:            acct.contextName,
+		Credential:         acct.credentialName,
+		Email:              cmd.Email,
+		BareDomainName:     cmd.Domain,
+		PrivateKeyFilename: privateKeyFilename(cmd.PrivateKey, context),
+		VolumeSize:         cmd.VolumeSize,
+	}
+	if cfg.Email == "" {
+		cfg.Email = context.Email
+	}
+
+	projectTag := config.ProjectTag(cmd.Name)
+	fmt.Printf("Looking for resources tagged %v\n", projectTag)
+	discovered := 0
+
+	fmt.Print("Looking for droplet...")
+	droplets, err := droplet.GetAllByTag(doSvc, projectTag)
+	if err != nil {
+		return err
+	}
+	switch len(droplets) {
+	case 0:
+		fmt.Println("Not found")
+	case 1:
+		dropletObj := droplets[0]
+		cfg.DropletID = dropletObj.ID
+		cfg.Region = dropletObj.Region.Slug
+		cfg.DropletSlug = dropletObj.SizeSlug
+		// A droplet created from the stock image was provisioned by cloud-init rather than from a built image
+		if !dropletObj.Image.Public {
+			cfg.ImageID = dropletObj.Image.ID
+		}
+		for _, address := range dropletObj.Networks.V4 {
+			if address.Type == "public" {
+				cfg.DropletPublicIP = address.IPAddress
+			}
+		}
+		discovered++
+		fmt.Println("Found", cfg.DropletID)
+	default:
+		return fmt.Errorf("%v droplets are tagged %v, remove the tag from all but the project's droplet", len(droplets), projectTag)
+	}
+
+	fmt.Print("Looking for block storage volume...")
+	allVolumes, err := blockstorage.GetAll(doSvc)
+	if err != nil {
+		return err
+	}
+	volumes := []blockstorage.Volume{}
+	for _, volume := range allVolumes {
+		if tag.Has(volume.Tags, projectTag) {
+			volumes = append(volumes, volume)
+		}
+	}
+	switch len(volumes) {
+	case 0:
+		fmt.Println("Not found")
+	case 1:
+		cfg.BlockStorageID = volumes[0].ID
+		cfg.VolumeSize = volumes[0].SizeGigabytes
+		if cfg.Region == "" {
+			cfg.Region = volumes[0].Region.Slug
+		}
+		discovered++
+		fmt.Println("Found", cfg.BlockStorageID)
+	default:
+		return fmt.Errorf("%v block storage volumes are tagged %v, remove the tag from all but the project's volume", len(volumes), projectTag)
+	}
+
+	// Firewalls can't be tagged themselves, but box's protect the droplets tagged with the project
+	fmt.Print("Looking for firewall...")
+	firewalls, err := firewall.GetAll(doSvc)
+	if err != nil {
+		return err
+	}
+	for _, firewallObj := range firewalls {
+		if tag.Has(firewallObj.Tags, projectTag) {
+			if cfg.FirewallID != "" {
+				return fmt.Errorf("More than one firewall protects droplets tagged %v, remove the tag from all but the project's firewall", projectTag)
+			}
+			cfg.FirewallID = firewallObj.ID
+		}
+	}
+	if cfg.FirewallID == "" {
+		fmt.Println("Not found")
+	} else {
+		discovered++
+		fmt.Println("Found", cfg.FirewallID)
+	}
+
+	if discovered == 0 {
+		return fmt.Errorf("No droplet, block storage volume or firewall is tagged %v, the project's resources were either never created or created before box tagged them", projectTag)
+	}
+
+	// The image the droplet was created from, otherwise the one built for the project
+	if cfg.ImageID == 0 {
+		fmt.Print("Looking for image...")
+		snapshots, err := snapshot.GetAllDropletSnapshots(doSvc)
+		if err != nil {
+			return err
+		}
+		for _, snapshotObj := range snapshots {
+			if tag.Has(snapshotObj.Tags, projectTag) {
+				cfg.ImageID, err = strconv.Atoi(snapshotObj.ID)
+				if err != nil {
+					return fmt.Errorf("Unable to convert snapshot ID to int: %w", err)
+				}
+			}
+		}
+		if cfg.ImageID == 0 {
+			fmt.Println("Not found, droplets will be provisioned on first boot unless one is built with: box mkimage", cmd.Name)
+		} else {
+			fmt.Println("Found", cfg.ImageID)
+		}
+	}
+
+	// Domain records can't be tagged, the domain is the one whose bare record points to the droplet
+	if cfg.BareDomainName == "" && cfg.DropletPublicIP != "" {
+		fmt.Print("Looking for domain...")
+		domainName, err := findDomain(doSvc, cfg.DropletPublicIP)
+		if err != nil {
+			return err
+		}
+		if domainName == "" {
+			fmt.Println("Not found")
+		} else {
+			cfg.BareDomainName = domainName
+			fmt.Println("Found", domainName)
+		}
+	}
+
+	missing := []string{}
+	if cfg.BareDomainName == "" {
+		missing = append(missing, "domain: not found, set it with --domain")
+	}
+	if cfg.Email == "" {
+		missing = append(missing, "email: missing, set it with --email")
+	}
+	fallback := func(value *string, given, contextValue string) {
+		if *value == "" {
+			*value = given
+		}
+		if *value == "" {
+			*value = contextValue
+		}
+	}
+	fallback(&cfg.Region, cmd.Region, context.Region)
+	fallback(&cfg.DropletSlug, cmd.DropletSlug, context.DropletSlug)
+	if cfg.Region == "" {
+		missing = append(missing, "region: not found, set it with --region")
+	}
+	if cfg.DropletSlug == "" {
+		missing = append(missing, "droplet_slug: not found, set it with --droplet-slug")
+	}
+	if cfg.VolumeSize == 0 {
+		missing = append(missing, "volume_size: not found, set it with --volume-size")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Unable to adopt project %v:\n  %v", cmd.Name, strings.Join(missing, "\n  "))
+	}
+
+	if context.PrivateKeyFilename == cfg.PrivateKeyFilename && context.PublicKeyID != 0 {
+		cfg.PublicKeyID = context.PublicKeyID
+	} else {
+		cfg.PublicKeyID, err = findPublicKey(doSvc, cfg.PrivateKeyFilename)
+		if err != nil {
+			return err
+		}
+		if cfg.PublicKeyID == 0 {
+			fmt.Printf("The public key of %v isn't registered with the account, droplets only accept it once it is\n", cfg.PrivateKeyFilename)
+		}
+	}
+
+	fmt.Printf("Writing configuration of project %v...", cmd.Name)
+	err = config.Adopt(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
+	fmt.Println("Run box mkremote", cmd.Name, "to create anything which wasn't found")
+
+	return nil
+}
+
+// account is the context and credential which a project is operated with
+type account struct {
+	contextName    string
+	context        *config.Context
+	credentialName string
+	doSvc          *digitalocean.Service
+}
+
+// resolveAccount returns the account of the context and credential given, which default to the current
+// context and its credential
+func resolveAccount(contextName, credentialName string) (*account, error) {
+	contexts, err := config.LoadContexts()
+	if err != nil {
+		return nil, err
+	}
+	if contextName == "" {
+		contextName = contexts.Current
+	}
+	context := &config.Context{}
+	if contextName != "" {
+		context, err = contexts.Get(contextName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if credentialName == "" {
+		credentialName = context.Credential
+	}
+	if credentialName == "" {
+		credentialName = credentials.DefaultName
+	}
+	creds, err := config.Credentials()
+	if err != nil {
+		return nil, err
+	}
+	apiToken, _, err := creds.Get(credentialName)
+	if err == credentials.ErrNotFound {
+		return nil, fmt.Errorf(
+			"%w, run box auth login --name %v or set %v",
+			config.ErrNoAPIToken,
+			credentialName,
+			credentials.EnvToken,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &account{
+		contextName:    contextName,
+		context:        context,
+		credentialName: credentialName,
+		doSvc:          digitalocean.NewService(apiToken),
+	}, nil
+}
+
+// privateKeyFilename returns the private key given, otherwise that of the context or the default one
+func privateKeyFilename(given string, context *config.Context) string {
+	if given != "" {
+		return given
+	}
+	if context.PrivateKeyFilename != "" {
+		return context.PrivateKeyFilename
+	}
+	homeDir, _ := os.UserHomeDir()
+
+	return filepath.Join(homeDir, ".ssh", "id_rsa")
+}
+
+// findDomain returns the name of the domain whose bare A record points to the IP address, or an empty
+// string if there is none
+func findDomain(doSvc *digitalocean.Service, ipAddress string) (string, error) {
+	domains, err := domain.GetAll(doSvc)
+	if err != nil {
+		return "", err
+	}
+
+	matches := []string{}
+	for _, domainObj := range domains {
+		domainRecs, err := domain.ListRecords(doSvc, domainObj.Name, "A")
+		if err != nil {
+			return "", err
+		}
+		for _, domainRec := range domainRecs {
+			if domainRec.Name == "@" && domainRec.Data == ipAddress {
+				matches = append(matches, domainObj.Name)
+			}
+		}
+	}
+
+	if len(matches) > 1 {
+		return "", fmt.Errorf("Domains %v all point to %v, choose one with --domain", strings.Join(matches, ", "), ipAddress)
+	}
+	if len(matches) == 0 {
+		return "", nil
+	}
+
+	return matches[0], nil
+}
+
+// findPublicKey returns the account's ID of the private key's public key, or 0 if it was never registered
+func findPublicKey(doSvc *digitalocean.Service, privateKeyFilename string) (int, error) {
+	pbkFilename := fmt.Sprintf("%v.pub", privateKeyFilename)
+	pbkData, err := ioutil.ReadFile(pbkFilename)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to read public key file %v, choose another key with --private-key", pbkFilename)
+	}
+
+	fmt.Printf("Checking for a matching SSH public key...")
+	keys, err := sshkeys.GetAll(doSvc)
+	if err != nil {
+		return 0, err
+	}
+	strKey := strings.Trim(string(pbkData), " \n\t")
+	for _, key := range keys {
+		if key.PublicKey == strKey {
+			fmt.Println("Found")
+			return key.ID, nil
+		}
+	}
+	fmt.Println("Not found")
+
+	return 0, nil
+}
@@ -8,8 +8,14 @@ import (
 )
 
 type Volume struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Region struct {
+		Slug string `json:"slug"`
+	} `json:"region"`
+	SizeGigabytes int      `json:"size_gigabytes"`
+	DropletIDs    []int    `json:"droplet_ids"`
+	Tags          []string `json:"tags"`
 }
 
 type volumeResp struct {
@@ -34,18 +40,38 @@ func Get(svc *digitalocean.Service, ID string) (*Volume, error) {
 	return &getResp.Volume, nil
 }
 
-// Create provisions a block storage volume in the supplied region
-func Create(svc *digitalocean.Service, name, region string, sizeInGb int) (*Volume, error) {
+// GetAll returns up to 200 block storage volumes (pagination is not supported)
+func GetAll(svc *digitalocean.Service) ([]Volume, error) {
+	respBody, err := svc.Get(fmt.Sprintf("%v?per_page=200", basePath))
+	if err != nil {
+		return nil, fmt.Errorf("blockstorage.GetAll: %w", err)
+	}
+
+	getResp := struct {
+		Volumes []Volume `json:"volumes"`
+	}{}
+	err = json.Unmarshal(respBody, &getResp)
+	if err != nil {
+		return nil, fmt.Errorf("blockstorage.GetAll: %w", err)
+	}
+
+	return getResp.Volumes, nil
+}
+
+// Create provisions a block storage volume in the supplied region, tagged with any tags provided
+func Create(svc *digitalocean.Service, name, region string, sizeInGb int, tags []string) (*Volume, error) {
 	type createReq struct {
-		SizeGigabytes int    `json:"size_gigabytes"`
-		Name          string `json:"name"`
-		Region        string `json:"region"`
+		SizeGigabytes int      `json:"size_gigabytes"`
+		Name          string   `json:"name"`
+		Region        string   `json:"region"`
+		Tags          []string `json:"tags,omitempty"`
 	}
 
 	create := createReq{
 		SizeGigabytes: sizeInGb,
 		Name:          name,
 		Region:        region,
+		Tags:          tags,
 	}
 
 	reqBody, err := json.Marshal(&create)
@@ -49,7 +49,7 @@ func getFullURL(urlSuffix string) string {
 // doRequest executes an authenticated HTTP request.  If there is no failure or error response returned, a
 // byte array consisting of the response body is returned.
 func (svc *Service) doRequest(req *http.Request) ([]byte, error) {
-	if req.Method == "PUT" || req.Method == "POST" || req.Method == "PATCH" {
+	if req.Method == "PUT" || req.Method == "POST" || req.Method == "PATCH" || (req.Method == "DELETE" && req.Body != nil) {
 		req.Header.Add("Content-Type", "application/json")
 	}
 	req.Header.Add("Authorization", fmt.Sprint("Bearer ", svc.APIKey))
@@ -120,3 +120,16 @@ func (svc *Service) Delete(url string) error {
 	_, err = svc.doRequest(req)
 	return err
 }
+
+// DeleteWithBody executes an authenticated DELETE request against the provided URL suffix, passing a byte array for the
+// body (JSON marshaled).  Nil is returned unless an error occurs.
+func (svc *Service) DeleteWithBody(url string, body []byte) error {
+	reader := bytes.NewReader(body)
+
+	req, err := http.NewRequest("DELETE", getFullURL(url), reader)
+	if err != nil {
+		return err
+	}
+	_, err = svc.doRequest(req)
+	return err
+}
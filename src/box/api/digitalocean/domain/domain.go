@@ -39,6 +39,24 @@ func Get(svc *digitalocean.Service, domainName string) (*Domain, error) {
 	return &getResp.Domain, nil
 }
 
+// GetAll returns up to 200 domains managed within the account (pagination is not supported)
+func GetAll(svc *digitalocean.Service) ([]Domain, error) {
+	respBody, err := svc.Get(fmt.Sprintf("%v?per_page=200", basePath))
+	if err != nil {
+		return nil, fmt.Errorf("domain.GetAll: %w", err)
+	}
+
+	getResp := struct {
+		Domains []Domain `json:"domains"`
+	}{}
+	err = json.Unmarshal(respBody, &getResp)
+	if err != nil {
+		return nil, fmt.Errorf("domain.GetAll: %w", err)
+	}
+
+	return getResp.Domains, nil
+}
+
 // Create adds domainName to the list of managed domains within the digitalocean account
 func Create(svc *digitalocean.Service, domainName string) (*Domain, error) {
 	type createReq struct {
@@ -5,6 +5,7 @@ import (
 	"box/api/digitalocean/action"
 	"encoding/json"
 	"fmt"
+	"net/url"
 )
 
 // DefaultPublicImage is the public image slug upon which to build base images
@@ -25,22 +26,35 @@ type Droplet struct {
 		V4 []Address `json:"v4"`
 	} `json:"networks"`
 	VolumeIds []string `json:"volume_ids"`
+	Region    struct {
+		Slug string `json:"slug"`
+	} `json:"region"`
+	SizeSlug string `json:"size_slug"`
+	Image    struct {
+		ID     int  `json:"id"`
+		Public bool `json:"public"`
+	} `json:"image"`
+	Tags []string `json:"tags"`
 }
 
 type createFromPublicImageRequest struct {
-	Name    string `json:"name"`
-	Size    string `json:"size"`
-	Region  string `json:"region"`
-	Image   string `json:"image"`
-	SSHKeys []int  `json:"ssh_keys"`
+	Name     string   `json:"name"`
+	Size     string   `json:"size"`
+	Region   string   `json:"region"`
+	Image    string   `json:"image"`
+	SSHKeys  []int    `json:"ssh_keys"`
+	Tags     []string `json:"tags,omitempty"`
+	UserData string   `json:"user_data,omitempty"`
 }
 
 type createFromPrivateImageRequest struct {
-	Name    string `json:"name"`
-	Size    string `json:"size"`
-	Region  string `json:"region"`
-	Image   int    `json:"image"`
-	SSHKeys []int  `json:"ssh_keys"`
+	Name     string   `json:"name"`
+	Size     string   `json:"size"`
+	Region   string   `json:"region"`
+	Image    int      `json:"image"`
+	SSHKeys  []int    `json:"ssh_keys"`
+	Tags     []string `json:"tags,omitempty"`
+	UserData string   `json:"user_data,omitempty"`
 }
 
 type createResponse struct {
@@ -64,14 +78,17 @@ func create(svc *digitalocean.Service, reqBody []byte) (*Droplet, error) {
 	return &respObj.Droplet, nil
 }
 
-// CreateFromPublicImage creates a droplet from a public image slug
-func CreateFromPublicImage(svc *digitalocean.Service, name, size, region, imageSlug string, sshKeys []int) (*Droplet, error) {
+// CreateFromPublicImage creates a droplet from a public image slug, tagged with any tags provided.  Any user data
+// provided is processed by cloud-init on the droplet's first boot.
+func CreateFromPublicImage(svc *digitalocean.Service, name, size, region, imageSlug string, sshKeys []int, tags []string, userData string) (*Droplet, error) {
 	cr := createFromPublicImageRequest{
-		Name:    name,
-		Size:    size,
-		Region:  region,
-		Image:   imageSlug,
-		SSHKeys: sshKeys,
+		Name:     name,
+		Size:     size,
+		Region:   region,
+		Image:    imageSlug,
+		SSHKeys:  sshKeys,
+		Tags:     tags,
+		UserData: userData,
 	}
 
 	reqBody, err := json.Marshal(&cr)
@@ -82,14 +99,17 @@ func CreateFromPublicImage(svc *digitalocean.Service, name, size, region, imageS
 	return create(svc, reqBody)
 }
 
-// CreateFromPrivateImage creates a droplet from a public image ID
-func CreateFromPrivateImage(svc *digitalocean.Service, name, size, region string, imageID int, sshKeys []int) (*Droplet, error) {
+// CreateFromPrivateImage creates a droplet from a private image ID, tagged with any tags provided, which cloud-init
+// configures with the user data on first boot
+func CreateFromPrivateImage(svc *digitalocean.Service, name, size, region string, imageID int, sshKeys []int, tags []string, userData string) (*Droplet, error) {
 	cr := createFromPrivateImageRequest{
-		Name:    name,
-		Size:    size,
-		Region:  region,
-		Image:   imageID,
-		SSHKeys: sshKeys,
+		Name:     name,
+		Size:     size,
+		Region:   region,
+		Image:    imageID,
+		SSHKeys:  sshKeys,
+		Tags:     tags,
+		UserData: userData,
 	}
 
 	reqBody, err := json.Marshal(&cr)
@@ -116,6 +136,24 @@ func Get(svc *digitalocean.Service, dropletID int) (*Droplet, error) {
 	return &respObj.Droplet, nil
 }
 
+// GetAllByTag returns up to 200 droplets tagged with tagName (pagination is not supported)
+func GetAllByTag(svc *digitalocean.Service, tagName string) ([]Droplet, error) {
+	respBody, err := svc.Get(fmt.Sprintf("%v?tag_name=%v&per_page=200", basePath, url.QueryEscape(tagName)))
+	if err != nil {
+		return nil, fmt.Errorf("droplet.GetAllByTag: %w", err)
+	}
+
+	getResp := struct {
+		Droplets []Droplet `json:"droplets"`
+	}{}
+	err = json.Unmarshal(respBody, &getResp)
+	if err != nil {
+		return nil, fmt.Errorf("droplet.GetAllByTag: %w", err)
+	}
+
+	return getResp.Droplets, nil
+}
+
 // CreateSnapshot creates a named snapshot of the given droplet
 func CreateSnapshot(svc *digitalocean.Service, dropletID int, name string) (*action.Action, error) {
 	type createSnapshotRequest struct {
@@ -7,9 +7,13 @@ import (
 )
 
 type Firewall struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
-	Name   string `json:"name"`
+	ID            string         `json:"id"`
+	Status        string         `json:"status"`
+	Name          string         `json:"name"`
+	InboundRules  []InboundRule  `json:"inbound_rules"`
+	OutboundRules []OutboundRule `json:"outbound_rules"`
+	// Droplets with any of the tags are protected by the firewall
+	Tags []string `json:"tags"`
 }
 
 type Addresses struct {
@@ -50,18 +54,39 @@ func Get(svc *digitalocean.Service, ID string) (*Firewall, error) {
 	return &getResp.Firewall, nil
 }
 
-// Create will create a named firewall with the provided inbound and outbound ruleset
-func Create(svc *digitalocean.Service, name string, inboundRules []InboundRule, outboundRules []OutboundRule) (*Firewall, error) {
+// GetAll returns up to 200 firewalls (pagination is not supported)
+func GetAll(svc *digitalocean.Service) ([]Firewall, error) {
+	respBody, err := svc.Get(fmt.Sprintf("%v?per_page=200", basePath))
+	if err != nil {
+		return nil, fmt.Errorf("firewall.GetAll: %w", err)
+	}
+
+	getResp := struct {
+		Firewalls []Firewall `json:"firewalls"`
+	}{}
+	err = json.Unmarshal(respBody, &getResp)
+	if err != nil {
+		return nil, fmt.Errorf("firewall.GetAll: %w", err)
+	}
+
+	return getResp.Firewalls, nil
+}
+
+// Create will create a named firewall with the provided inbound and outbound ruleset, protecting droplets
+// with any of the tags provided
+func Create(svc *digitalocean.Service, name string, inboundRules []InboundRule, outboundRules []OutboundRule, tags []string) (*Firewall, error) {
 	type createReq struct {
 		Name          string         `json:"name"`
 		InboundRules  []InboundRule  `json:"inbound_rules"`
 		OutboundRules []OutboundRule `json:"outbound_rules"`
+		Tags          []string       `json:"tags,omitempty"`
 	}
 
 	create := createReq{
 		Name:          name,
 		InboundRules:  inboundRules,
 		OutboundRules: outboundRules,
+		Tags:          tags,
 	}
 
 	reqBody, err := json.Marshal(&create)
@@ -82,3 +107,43 @@ func Create(svc *digitalocean.Service, name string, inboundRules []InboundRule,
 
 	return &createResp.Firewall, nil
 }
+
+type rulesReq struct {
+	InboundRules []InboundRule `json:"inbound_rules"`
+}
+
+// AddInboundRules adds the provided inbound rules to an existing firewall
+func AddInboundRules(svc *digitalocean.Service, ID string, inboundRules []InboundRule) error {
+	reqBody, err := json.Marshal(&rulesReq{InboundRules: inboundRules})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.Post(fmt.Sprintf("%v/%v/rules", basePath, ID), reqBody)
+	return err
+}
+
+// RemoveInboundRules removes the provided inbound rules from an existing firewall
+func RemoveInboundRules(svc *digitalocean.Service, ID string, inboundRules []InboundRule) error {
+	reqBody, err := json.Marshal(&rulesReq{InboundRules: inboundRules})
+	if err != nil {
+		return err
+	}
+
+	return svc.DeleteWithBody(fmt.Sprintf("%v/%v/rules", basePath, ID), reqBody)
+}
+
+// AddTags makes the firewall protect droplets with any of the tags provided
+func AddTags(svc *digitalocean.Service, ID string, tags []string) error {
+	type tagsReq struct {
+		Tags []string `json:"tags"`
+	}
+
+	reqBody, err := json.Marshal(&tagsReq{Tags: tags})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.Post(fmt.Sprintf("%v/%v/tags", basePath, ID), reqBody)
+	return err
+}
@@ -9,8 +9,9 @@ import (
 const basePath = "/snapshots"
 
 type Snapshot struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
 }
 
 // GetAllDropletSnapshots returns up to 200 snapshots created from droplets (pagination
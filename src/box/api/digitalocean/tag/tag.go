@@ -0,0 +1,73 @@
+package tag
+
+import (
+	"box/api/digitalocean"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Resource types which can be tagged
+const (
+	ResourceDroplet = "droplet"
+	ResourceVolume  = "volume"
+	ResourceImage   = "image"
+)
+
+// Resource identifies a resource to be tagged
+type Resource struct {
+	ID   string `json:"resource_id"`
+	Type string `json:"resource_type"`
+}
+
+const basePath = "/tags"
+
+// Create creates a tag, which must exist before resources can be tagged with it.  Creating a tag which
+// already exists isn't an error.
+func Create(svc *digitalocean.Service, name string) error {
+	type createReq struct {
+		Name string `json:"name"`
+	}
+
+	reqBody, err := json.Marshal(&createReq{Name: name})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.Post(basePath, reqBody)
+	if err != nil {
+		return fmt.Errorf("tag.Create: %w", err)
+	}
+
+	return nil
+}
+
+// TagResources tags each of the resources, leaving those already tagged as they are
+func TagResources(svc *digitalocean.Service, name string, resources []Resource) error {
+	type tagReq struct {
+		Resources []Resource `json:"resources"`
+	}
+
+	reqBody, err := json.Marshal(&tagReq{Resources: resources})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.Post(fmt.Sprintf("%v/%v/resources", basePath, url.PathEscape(name)), reqBody)
+	if err != nil {
+		return fmt.Errorf("tag.TagResources: %w", err)
+	}
+
+	return nil
+}
+
+// Has returns true if tags contains the tag by name
+func Has(tags []string, name string) bool {
+	for _, t := range tags {
+		if t == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"box/api/digitalocean"
+	"box/api/digitalocean/sshkeys"
+	"box/config"
+	"box/credentials"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+type AuthCmd struct {
+	Login  AuthLoginCmd  `cmd:"" help:"Store a DigitalOcean API token, read from standard input"`
+	Logout AuthLogoutCmd `cmd:"" help:"Remove a stored DigitalOcean API token"`
+	Status AuthStatusCmd `cmd:"" help:"Show where DigitalOcean API tokens are kept, and whether each project has one"`
+}
+
+type AuthLoginCmd struct {
+	Name   string `default:"default" help:"Credential name, projects share the default one unless created with a different token"`
+	Source string `help:"Where tokens are stored, one of keyring, helper or file.  Defaults to the one already in use, or the keyring when it's available"`
+	Helper string `help:"Credential helper command, run with get, store or erase and the credential name, for the helper source"`
+}
+
+type AuthLogoutCmd struct {
+	Name string `default:"default" help:"Credential name"`
+}
+
+type AuthStatusCmd struct {
+}
+
+// maskToken hides all but the end of the token
+func maskToken(token string) string {
+	if len(token) <= 8 {
+		return "****"
+	}
+
+	return "****" + token[len(token)-4:]
+}
+
+func (cmd *AuthLoginCmd) Run() error {
+	if err := credentials.ValidateName(cmd.Name); err != nil {
+		return err
+	}
+	creds, err := config.Credentials()
+	if err != nil {
+		return err
+	}
+	if cmd.Source == credentials.SourceHelper && cmd.Helper == "" {
+		return fmt.Errorf("A credential helper command is required to store tokens with a helper")
+	}
+
+	token, err := readHiddenInput("DigitalOcean API token: ")
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("An API token is required")
+	}
+
+	fmt.Printf("Verifying token...")
+	if _, err := sshkeys.GetAll(digitalocean.NewService(token)); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	// Only switched once the token is known to work
+	if cmd.Source != "" {
+		if err := creds.Configure(cmd.Source, cmd.Helper); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Storing token %v...", cmd.Name)
+	if err := creds.Set(cmd.Name, token); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+	fmt.Printf("Token is kept in the %v credential store\n", creds.Source())
+
+	if os.Getenv(credentials.EnvToken) != "" {
+		fmt.Printf("%v is set, and takes precedence over the stored token\n", credentials.EnvToken)
+	}
+
+	return nil
+}
+
+func (cmd *AuthLogoutCmd) Run() error {
+	if err := credentials.ValidateName(cmd.Name); err != nil {
+		return err
+	}
+	creds, err := config.Credentials()
+	if err != nil {
+		return err
+	}
+	if creds.Source() == "" {
+		return fmt.Errorf("No tokens have been stored")
+	}
+
+	fmt.Printf("Removing token %v...", cmd.Name)
+	if err := creds.Erase(cmd.Name); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+func (cmd *AuthStatusCmd) Run() error {
+	projects, err := config.ListProjects()
+	if err != nil {
+		return err
+	}
+
+	// Projects still holding a token in their configuration file have it moved as they're loaded, so they
+	// are loaded before the store is reported
+	rows := []string{}
+	for _, project := range projects {
+		cfg, err := config.Load(project)
+		if err != nil {
+			rows = append(rows, fmt.Sprintf("%v\t\t%v", project, err))
+			continue
+		}
+
+		status := ""
+		token, err := cfg.APIToken()
+		switch {
+		case errors.Is(err, config.ErrNoAPIToken):
+			status = "missing"
+		case err != nil:
+			status = err.Error()
+		default:
+			status = maskToken(token)
+		}
+		rows = append(rows, fmt.Sprintf("%v\t%v\t%v", project, cfg.CredentialName(), status))
+	}
+
+	creds, err := config.Credentials()
+	if err != nil {
+		return err
+	}
+
+	switch creds.Source() {
+	case "":
+		fmt.Println("Credential store: none, run box auth login to store a token")
+	case credentials.SourceHelper:
+		fmt.Printf("Credential store: helper (%v)\n", creds.Helper())
+	default:
+		fmt.Printf("Credential store: %v\n", creds.Source())
+	}
+	if os.Getenv(credentials.EnvToken) != "" {
+		fmt.Printf("%v is set, and takes precedence over stored tokens\n", credentials.EnvToken)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROJECT\tCREDENTIAL\tTOKEN")
+	for _, row := range rows {
+		fmt.Fprintln(writer, row)
+	}
+	writer.Flush()
+
+	return nil
+}
@@ -5,22 +5,22 @@ import (
 	"box/manifest"
 	"box/runtime"
 	"fmt"
-	"os"
-	"path/filepath"
 )
 
 type BuildCmd struct {
+	Force   bool     `default:"false" help:"Rebuild images even if their build context is unchanged"`
+	Remote  bool     `default:"false" help:"Build images for deployment to the remote host (defaults to linux/amd64)"`
+	Profile []string `help:"Also build services selected by this profile, may be repeated"`
 }
 
 func (cmd *BuildCmd) Run() error {
-	dirName, err := os.Getwd()
-	if err != nil {
-		return err
+	env := manifest.EnvDev
+	if cmd.Remote == true {
+		env = manifest.EnvProd
 	}
 
-	manifestFilename := filepath.Join(dirName, "box.yml")
 	fmt.Println("Loading run manifest")
-	mfst, err := manifest.NewManifest(manifestFilename)
+	mfst, err := loadManifest(env)
 	if err != nil {
 		return err
 	}
@@ -32,12 +32,13 @@ func (cmd *BuildCmd) Run() error {
 		return err
 	}
 
-	rt, err := runtime.New(mfst, cfg, false)
+	rt, err := runtime.New(mfst, cfg, cmd.Remote)
 	if err != nil {
 		return err
 	}
 
-	err = rt.Build()
+	rt.Profiles = cmd.Profile
+	_, err = rt.Build(cmd.Force)
 
 	if err != nil {
 		return err
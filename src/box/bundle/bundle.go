@@ -0,0 +1,95 @@
+package bundle
+
+import (
+	"box/crypt"
+	"encoding/base64"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const format = "box.do project bundle"
+const version = 1
+
+// Bundle holds what a teammate needs to operate a project from another machine
+type Bundle struct {
+	// Configuration as shared with teammates, see config.Config.Shared
+	Config string `yaml:"config"`
+	// Contents of files of the project's configuration directory, by name
+	Files map[string]string `yaml:"files,omitempty"`
+	// Seed of the ed25519 key authorized for the droplet's admin user, which the teammate replaces with their own
+	AccessKey string `yaml:"access_key,omitempty"`
+	// Name of the teammate the access key is granted to, see box access
+	AccessMember string `yaml:"access_member,omitempty"`
+}
+
+// sealedBundle is the bundle as it's written, encrypted with a key derived from a passphrase
+type sealedBundle struct {
+	Format  string `yaml:"format"`
+	Version int    `yaml:"version"`
+	Salt    string `yaml:"salt"`
+	Sealed  string `yaml:"sealed"`
+}
+
+// Seal returns the bundle encrypted with the passphrase
+func (b *Bundle) Seal(passphrase string) ([]byte, error) {
+	content, err := yaml.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := crypt.NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypt.DeriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := crypt.Seal(content, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(&sealedBundle{
+		Format:  format,
+		Version: version,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Sealed:  base64.StdEncoding.EncodeToString(sealed),
+	})
+}
+
+// Open returns the bundle in data, decrypted with the passphrase
+func Open(data []byte, passphrase string) (*Bundle, error) {
+	sb := sealedBundle{}
+	if err := yaml.Unmarshal(data, &sb); err != nil || sb.Format != format {
+		return nil, fmt.Errorf("Not a box project bundle")
+	}
+	if sb.Version > version {
+		return nil, fmt.Errorf("The bundle is version %v, which is newer than this build of box supports (%v), please upgrade box", sb.Version, version)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(sb.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("The bundle is corrupt: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(sb.Sealed)
+	if err != nil {
+		return nil, fmt.Errorf("The bundle is corrupt")
+	}
+	key, err := crypt.DeriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := crypt.Open(sealed, key)
+	if !ok {
+		return nil, fmt.Errorf("The passphrase is incorrect, or the bundle is corrupt")
+	}
+
+	b := Bundle{}
+	if err := yaml.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("The bundle is corrupt: %w", err)
+	}
+
+	return &b, nil
+}
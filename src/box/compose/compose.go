@@ -0,0 +1,273 @@
+package compose
+
+import (
+	"box/manifest"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Filenames searched for a compose file when none is given, in order
+var DefaultFilenames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Loss is something in the compose file which couldn't be expressed in the manifest
+type Loss struct {
+	// Service is empty for top level entries
+	Service string
+	Message string
+}
+
+func (l Loss) String() string {
+	if l.Service == "" {
+		return l.Message
+	}
+
+	return fmt.Sprintf("%v: %v", l.Service, l.Message)
+}
+
+// RoutingPrompt asks how HTTP requests should be routed to a service listening on the container ports,
+// returning nil if the service shouldn't be routed
+type RoutingPrompt func(service string, ports []int) (*manifest.Routing, error)
+
+type Options struct {
+	// Project name, defaults to the compose project name or the name of the directory the file is in
+	Project string
+	// Directory the manifest is written to, paths in the compose file are made relative to it
+	OutputDir string
+	// Prompt is used for services whose routing can't be inferred from their labels, if set
+	Prompt RoutingPrompt
+}
+
+// Result is a manifest converted from a compose file, along with everything which was lost converting it
+type Result struct {
+	Manifest *manifest.Manifest
+	Losses   []Loss
+}
+
+// secretDef is a top level compose secret
+type secretDef struct {
+	File        string `yaml:"file"`
+	Environment string `yaml:"environment"`
+}
+
+type converter struct {
+	dir     string
+	opts    Options
+	mfst    *manifest.Manifest
+	secrets map[string]secretDef
+	losses  []Loss
+}
+
+var projectNameInvalidRe *regexp.Regexp = regexp.MustCompile("[^a-z0-9-]+")
+
+// lose records something which couldn't be converted
+func (c *converter) lose(service, format string, args ...interface{}) {
+	c.losses = append(c.losses, Loss{Service: service, Message: fmt.Sprintf(format, args...)})
+}
+
+// path converts a path relative to the compose file into one relative to the manifest
+func (c *converter) path(p string) string {
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "~") {
+		return p
+	}
+
+	rel, err := filepath.Rel(c.opts.OutputDir, filepath.Join(c.dir, p))
+	if err != nil {
+		return p
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, ".") {
+		rel = "./" + rel
+	}
+
+	return rel
+}
+
+// Convert reads the compose file and converts it into a manifest
+func Convert(filename string, opts Options) (*Result, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = filepath.Dir(filename)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return nil, fmt.Errorf("Unable to locate compose file at %v", filename)
+		}
+		return nil, err
+	}
+
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Unable to process YAML in %v: %w", filename, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("Compose file %v must be a mapping at the top level", filename)
+	}
+
+	c := &converter{
+		dir:  filepath.Dir(filename),
+		opts: opts,
+		mfst: &manifest.Manifest{
+			Project:  opts.Project,
+			Services: map[string]*manifest.Service{},
+			Volumes:  map[string]*manifest.Volume{},
+		},
+		secrets: map[string]secretDef{},
+	}
+
+	var services, volumes *yaml.Node
+	for _, pair := range mappingPairs(doc.Content[0]) {
+		switch pair.key {
+		case "version":
+		case "name":
+			if c.mfst.Project == "" {
+				c.mfst.Project = pair.value.Value
+			}
+		case "services":
+			services = pair.value
+		case "volumes":
+			volumes = pair.value
+		case "secrets":
+			// Only used for build secrets, which refer to them by name
+			if err := pair.value.Decode(&c.secrets); err != nil {
+				return nil, fmt.Errorf("Unable to read secrets: %w", err)
+			}
+		case "networks":
+			c.lose("", "networks are not supported, all services share the project's network")
+		default:
+			if !strings.HasPrefix(pair.key, "x-") {
+				c.lose("", "%v is not supported", pair.key)
+			}
+		}
+	}
+
+	if c.mfst.Project == "" {
+		name := strings.ToLower(filepath.Base(c.dir))
+		c.mfst.Project = strings.Trim(projectNameInvalidRe.ReplaceAllString(name, "-"), "-")
+	}
+
+	if volumes != nil {
+		for _, pair := range mappingPairs(volumes) {
+			c.mfst.Volumes[pair.key] = &manifest.Volume{}
+			for _, option := range mappingPairs(pair.value) {
+				c.lose("", "volume %v: %v is not supported", pair.key, option.key)
+			}
+		}
+	}
+
+	if services == nil || len(services.Content) == 0 {
+		return nil, fmt.Errorf("Compose file %v doesn't define any services", filename)
+	}
+	for _, pair := range mappingPairs(services) {
+		if err := c.convertService(pair.key, pair.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.mfst.Volumes) == 0 {
+		c.mfst.Volumes = nil
+	}
+
+	return &Result{Manifest: c.mfst, Losses: c.losses}, nil
+}
+
+type nodePair struct {
+	key   string
+	value *yaml.Node
+}
+
+// mappingPairs returns the keys and values of a mapping node in order, resolving aliases and merge keys
+func mappingPairs(node *yaml.Node) []nodePair {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	pairs := []nodePair{}
+	seen := map[string]bool{}
+	merged := []nodePair{}
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "<<" {
+			sources := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				sources = value.Content
+			}
+			for _, source := range sources {
+				merged = append(merged, mappingPairs(source)...)
+			}
+			continue
+		}
+		seen[key.Value] = true
+		pairs = append(pairs, nodePair{key: key.Value, value: value})
+	}
+
+	// Keys given explicitly take precedence over merged ones, and the first merged source wins
+	for _, pair := range merged {
+		if !seen[pair.key] {
+			seen[pair.key] = true
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
+}
+
+// decodeStrings decodes either a single string or a list of strings
+func decodeStrings(node *yaml.Node) ([]string, error) {
+	values := []string{}
+	if err := node.Decode(&values); err == nil {
+		return values, nil
+	}
+
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return []string{value}, nil
+}
+
+// decodeKeyValues decodes either a mapping, or a list of KEY=VALUE strings.  Keys without a value are nil.
+func decodeKeyValues(node *yaml.Node) (map[string]*string, error) {
+	values := map[string]*string{}
+
+	items := []string{}
+	if err := node.Decode(&items); err == nil {
+		for _, item := range items {
+			parts := strings.SplitN(item, "=", 2)
+			if len(parts) == 2 {
+				values[parts[0]] = &parts[1]
+			} else {
+				values[parts[0]] = nil
+			}
+		}
+		return values, nil
+	}
+
+	for _, pair := range mappingPairs(node) {
+		if pair.value.Tag == "!!null" {
+			values[pair.key] = nil
+			continue
+		}
+		var value string
+		if err := pair.value.Decode(&value); err != nil {
+			return nil, err
+		}
+		values[pair.key] = &value
+	}
+
+	return values, nil
+}
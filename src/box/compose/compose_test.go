@@ -0,0 +1,233 @@
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// convert converts a compose file defining a single service named web, whose fields are given indented,
+// alongside the other files
+func convert(t *testing.T, service string, files map[string]string) *Result {
+	dir := t.TempDir()
+	content := "services:\n  web:\n    image: nginx\n" + service
+	if err := ioutil.WriteFile(filepath.Join(dir, "compose.yml"), []byte(content), os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), os.FileMode(0644)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Convert(filepath.Join(dir, "compose.yml"), Options{Project: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+// checkLosses verifies that every wanted loss was recorded, each matched by a substring
+func checkLosses(t *testing.T, losses []Loss, want []string) {
+	if len(losses) != len(want) {
+		t.Errorf("got %v losses, want %v: %v", len(losses), len(want), losses)
+		return
+	}
+	for i, substring := range want {
+		if !strings.Contains(losses[i].Message, substring) {
+			t.Errorf("got loss %q, want one containing %q", losses[i].Message, substring)
+		}
+	}
+}
+
+func TestConvertPorts(t *testing.T) {
+	tests := []struct {
+		name       string
+		service    string
+		want       []string
+		wantLosses []string
+	}{
+		{
+			name:    "short form on all interfaces",
+			service: "    ports: [\"0.0.0.0:8080:80\", \"0.0.0.0:5353:53/udp\"]\n",
+			want:    []string{"0.0.0.0:8080:80", "0.0.0.0:5353:53/udp"},
+		},
+		{
+			name:       "short form without a host IP",
+			service:    "    ports: [\"8080:80\"]\n",
+			want:       []string{"8080:80"},
+			wantLosses: []string{"published on 127.0.0.1 only"},
+		},
+		{
+			name:    "long form",
+			service: "    ports:\n      - target: 80\n        published: 8080\n        host_ip: 0.0.0.0\n        protocol: tcp\n",
+			want:    []string{"0.0.0.0:8080:80/tcp"},
+		},
+		{
+			name:       "long form target only",
+			service:    "    ports:\n      - target: 80\n",
+			want:       []string{"80"},
+			wantLosses: []string{"published on 127.0.0.1 only"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := convert(t, test.service, nil)
+			if got := result.Manifest.Services["web"].Ports; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got ports %v, want %v", got, test.want)
+			}
+			checkLosses(t, result.Losses, test.wantLosses)
+		})
+	}
+}
+
+func TestConvertVolumes(t *testing.T) {
+	tests := []struct {
+		name        string
+		service     string
+		want        []string
+		wantTmpfs   []string
+		wantVolumes []string
+		wantLosses  []string
+	}{
+		{
+			name:        "short form bind and named volume",
+			service:     "    volumes: [\"./data:/data:ro\", \"db:/var/lib/db\"]\n",
+			want:        []string{"./data:/data:ro", "db:/var/lib/db"},
+			wantVolumes: []string{"db"},
+		},
+		{
+			name:       "short form anonymous volume",
+			service:    "    volumes: [\"/cache\"]\n",
+			wantLosses: []string{"anonymous volume /cache is not supported"},
+		},
+		{
+			name:       "short form unsupported option",
+			service:    "    volumes: [\"./data:/data:z\"]\n",
+			want:       []string{"./data:/data"},
+			wantLosses: []string{"option z is not supported"},
+		},
+		{
+			name:        "long form",
+			service:     "    volumes:\n      - type: volume\n        source: db\n        target: /var/lib/db\n        read_only: true\n      - type: bind\n        source: ./conf\n        target: /etc/conf\n",
+			want:        []string{"db:/var/lib/db:ro", "./conf:/etc/conf"},
+			wantVolumes: []string{"db"},
+		},
+		{
+			name:      "long form tmpfs",
+			service:   "    volumes:\n      - type: tmpfs\n        target: /tmp\n        tmpfs:\n          size: 64m\n",
+			wantTmpfs: []string{"/tmp:size=64m"},
+		},
+		{
+			name:        "long form unsupported option",
+			service:     "    volumes:\n      - type: volume\n        source: db\n        target: /var/lib/db\n        volume:\n          nocopy: true\n",
+			want:        []string{"db:/var/lib/db"},
+			wantVolumes: []string{"db"},
+			wantLosses:  []string{"volume /var/lib/db option volume is not supported"},
+		},
+		{
+			name:       "tmpfs options",
+			service:    "    tmpfs: [\"/run:size=1m,mode=1777,noexec\"]\n",
+			wantTmpfs:  []string{"/run:size=1m,mode=1777"},
+			wantLosses: []string{"tmpfs /run option noexec is not supported"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := convert(t, test.service, nil)
+			svc := result.Manifest.Services["web"]
+			if !reflect.DeepEqual(svc.Volumes, test.want) {
+				t.Errorf("got volumes %v, want %v", svc.Volumes, test.want)
+			}
+			if !reflect.DeepEqual(svc.Tmpfs, test.wantTmpfs) {
+				t.Errorf("got tmpfs %v, want %v", svc.Tmpfs, test.wantTmpfs)
+			}
+			volumes := []string{}
+			for name := range result.Manifest.Volumes {
+				volumes = append(volumes, name)
+			}
+			if len(volumes) != len(test.wantVolumes) || (len(volumes) > 0 && !reflect.DeepEqual(volumes, test.wantVolumes)) {
+				t.Errorf("got named volumes %v, want %v", volumes, test.wantVolumes)
+			}
+			checkLosses(t, result.Losses, test.wantLosses)
+		})
+	}
+}
+
+func TestConvertEnvironment(t *testing.T) {
+	tests := []struct {
+		name       string
+		service    string
+		files      map[string]string
+		want       map[string]string
+		wantLosses []string
+	}{
+		{
+			name:    "mapping",
+			service: "    environment:\n      MODE: production\n      PORT: 80\n",
+			want:    map[string]string{"MODE": "production", "PORT": "80"},
+		},
+		{
+			name:    "list",
+			service: "    environment: [\"MODE=production\"]\n",
+			want:    map[string]string{"MODE": "production"},
+		},
+		{
+			name:       "from the shell",
+			service:    "    environment: [\"HOME\"]\n",
+			want:       map[string]string{"HOME": "${HOME}"},
+			wantLosses: []string{"take their values from the shell"},
+		},
+		{
+			name:    "env file imported as secret references",
+			service: "    env_file: .env\n",
+			files:   map[string]string{".env": "# comment\nDB_PASSWORD=\"hunter2\"\nexport API_KEY=abc\n"},
+			want:    map[string]string{"DB_PASSWORD": "secret:DB_PASSWORD", "API_KEY": "secret:API_KEY"},
+			wantLosses: []string{
+				"env_file .env is not supported",
+				"environment variables API_KEY, DB_PASSWORD were read from env files",
+			},
+		},
+		{
+			name:    "environment takes precedence over env files",
+			service: "    env_file: [.env]\n    environment:\n      MODE: production\n",
+			files:   map[string]string{".env": "MODE=development\n"},
+			want:    map[string]string{"MODE": "production"},
+			wantLosses: []string{
+				"env_file .env is not supported",
+			},
+		},
+		{
+			name:    "env file names unusable as secret names",
+			service: "    env_file: .env\n",
+			files:   map[string]string{".env": "my-var=1\nTOKEN=2\n"},
+			want:    map[string]string{"TOKEN": "secret:TOKEN"},
+			wantLosses: []string{
+				"env_file .env is not supported",
+				"env_file .env variables my-var were left out",
+				"environment variables TOKEN were read from env files",
+			},
+		},
+		{
+			name:       "optional env file missing",
+			service:    "    env_file:\n      - path: .env\n        required: false\n",
+			wantLosses: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := convert(t, test.service, test.files)
+			if got := result.Manifest.Services["web"].Environment; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got environment %v, want %v", got, test.want)
+			}
+			checkLosses(t, result.Losses, test.wantLosses)
+		})
+	}
+}
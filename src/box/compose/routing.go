@@ -0,0 +1,136 @@
+package compose
+
+import (
+	"box/manifest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Labels which describe a routing directly
+const (
+	labelRoutingPath = "box.routing.path"
+	labelRoutingType = "box.routing.type"
+	labelRoutingPort = "box.routing.port"
+)
+
+var traefikRuleRe *regexp.Regexp = regexp.MustCompile(`^traefik\.http\.routers\.[^.]+\.rule$`)
+var traefikPortRe *regexp.Regexp = regexp.MustCompile(`^traefik\.http\.services\.[^.]+\.loadbalancer\.server\.port$`)
+var traefikPathRe *regexp.Regexp = regexp.MustCompile("(PathPrefix|PathRegexp|Path)\\(\\s*[`\"']([^`\"']*)[`\"']")
+
+// Traefik path matchers, by the routing path type they correspond to
+var traefikPathTypes = map[string]string{
+	"PathPrefix": manifest.PathTypePrefix,
+	"Path":       manifest.PathTypeExact,
+	"PathRegexp": manifest.PathTypeRegex,
+}
+
+// labelValue returns the value of a label, or an empty string if it isn't set
+func (sc *serviceConverter) labelValue(key string) string {
+	if value := sc.labels[key]; value != nil {
+		return *value
+	}
+
+	return ""
+}
+
+// routingFromLabels infers the routing from box.routing.* labels, or failing those from traefik labels.  The
+// port is 0 if the labels don't give one.  Returns nil if the labels don't describe a routing.
+func (sc *serviceConverter) routingFromLabels() *manifest.Routing {
+	keys := []string{}
+	for key := range sc.labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := map[string]bool{}
+	defer func() {
+		unused := []string{}
+		for _, key := range keys {
+			if !used[key] && key != "traefik.enable" {
+				unused = append(unused, key)
+			}
+		}
+		if len(unused) > 0 {
+			sc.lose("labels are not supported: %v", strings.Join(unused, ", "))
+		}
+	}()
+
+	routing := &manifest.Routing{}
+	if pattern := sc.labelValue(labelRoutingPath); pattern != "" {
+		routing.Path.Pattern = pattern
+		routing.Path.Type = sc.labelValue(labelRoutingType)
+		if routing.Path.Type == "" {
+			routing.Path.Type = manifest.PathTypePrefix
+		}
+		routing.Port, _ = strconv.Atoi(sc.labelValue(labelRoutingPort))
+		used[labelRoutingPath], used[labelRoutingType], used[labelRoutingPort] = true, true, true
+		return routing
+	}
+
+	rules := []string{}
+	for _, key := range keys {
+		if traefikRuleRe.MatchString(key) {
+			rules = append(rules, sc.labelValue(key))
+			used[key] = true
+		}
+		if traefikPortRe.MatchString(key) && routing.Port == 0 {
+			routing.Port, _ = strconv.Atoi(sc.labelValue(key))
+			used[key] = true
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	if len(rules) > 1 {
+		sc.lose("only the first of %v traefik routers was imported", len(rules))
+	}
+
+	rule := rules[0]
+	match := traefikPathRe.FindStringSubmatch(rule)
+	if match == nil {
+		// A rule matching only on host routes everything
+		sc.lose("traefik rule %v has no path, all requests are routed to the service", rule)
+		routing.Path = manifest.Path{Pattern: "/", Type: manifest.PathTypePrefix}
+		return routing
+	}
+
+	routing.Path = manifest.Path{Pattern: match[2], Type: traefikPathTypes[match[1]]}
+	if strings.TrimSpace(rule) != match[0]+")" {
+		sc.lose("only the path of traefik rule %v was imported", rule)
+	}
+
+	return routing
+}
+
+// convertRouting infers the service's routing from its labels, or by prompting
+func (sc *serviceConverter) convertRouting() error {
+	routing := sc.routingFromLabels()
+	switch {
+	case routing != nil && routing.Port == 0:
+		if len(sc.ports) != 1 {
+			sc.lose("the port to route %v to couldn't be determined, set routing.port to import its routing", routing.Path.Pattern)
+			return nil
+		}
+		routing.Port = sc.ports[0]
+
+	case routing == nil && sc.opts.Prompt != nil && len(sc.ports) > 0:
+		var err error
+		routing, err = sc.opts.Prompt(sc.name, sc.ports)
+		if err != nil {
+			return err
+		}
+	}
+	if routing == nil {
+		return nil
+	}
+
+	if sc.svc.Hostname != "" {
+		sc.lose("hostname %v was dropped, since routed services can't have a hostname", sc.svc.Hostname)
+		sc.svc.Hostname = ""
+	}
+	sc.svc.Routing = *routing
+
+	return nil
+}
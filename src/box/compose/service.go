@@ -0,0 +1,697 @@
+package compose
+
+import (
+	"box/manifest"
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// Variables read from env files are imported as references to secrets of the same name
+var secretNameRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// serviceConverter holds the state of a single service while it's converted
+type serviceConverter struct {
+	*converter
+	name        string
+	svc         *manifest.Service
+	env         map[string]*string
+	envFiles    map[string]*string
+	labels      map[string]*string
+	ports       []int
+	hasBuild    bool
+	loopbackOut bool
+}
+
+func (sc *serviceConverter) lose(format string, args ...interface{}) {
+	sc.converter.lose(sc.name, format, args...)
+}
+
+// addPorts records the TCP container ports in a port mapping or expose entry, as candidates for routing.
+// published is true for port mappings.
+func (sc *serviceConverter) addPorts(spec string, published bool) {
+	mappings, err := nat.ParsePortSpec(spec)
+	if err != nil {
+		return
+	}
+
+	for _, mapping := range mappings {
+		if mapping.Port.Proto() != "tcp" {
+			continue
+		}
+		port := mapping.Port.Int()
+		found := false
+		for _, existing := range sc.ports {
+			found = found || existing == port
+		}
+		if !found {
+			sc.ports = append(sc.ports, port)
+		}
+		if published && mapping.Binding.HostIP == "" {
+			sc.loopbackOut = true
+		}
+	}
+}
+
+// convertService converts a compose service into a manifest service
+func (c *converter) convertService(name string, node *yaml.Node) error {
+	sc := &serviceConverter{
+		converter: c,
+		name:      name,
+		svc:       &manifest.Service{},
+		env:       map[string]*string{},
+		envFiles:  map[string]*string{},
+	}
+	svc := sc.svc
+
+	for _, pair := range mappingPairs(node) {
+		value := pair.value
+		var err error
+		switch pair.key {
+		case "image":
+			err = value.Decode(&svc.Image)
+		case "build":
+			err = sc.convertBuild(value)
+		case "environment":
+			sc.env, err = decodeKeyValues(value)
+		case "env_file":
+			err = sc.convertEnvFiles(value)
+		case "ports":
+			err = sc.convertPorts(value)
+		case "expose":
+			var exposed []string
+			if exposed, err = decodeStrings(value); err == nil {
+				for _, spec := range exposed {
+					sc.addPorts(spec, false)
+				}
+			}
+		case "volumes":
+			err = sc.convertVolumes(value)
+		case "tmpfs":
+			err = sc.convertTmpfs(value)
+		case "depends_on":
+			err = sc.convertDependencies(value)
+		case "hostname":
+			err = value.Decode(&svc.Hostname)
+		case "command":
+			err = value.Decode(&svc.Command)
+		case "entrypoint":
+			err = value.Decode(&svc.Entrypoint)
+		case "user":
+			err = value.Decode(&svc.User)
+		case "working_dir":
+			err = value.Decode(&svc.WorkingDir)
+		case "stop_grace_period":
+			err = value.Decode(&svc.StopGracePeriod)
+		case "restart":
+			err = value.Decode(&svc.Restart)
+		case "healthcheck":
+			err = sc.convertHealthCheck(value)
+		case "mem_limit":
+			err = value.Decode(&svc.Memory)
+		case "cpus":
+			svc.CPUs, err = decodeFloat(value)
+		case "deploy":
+			err = sc.convertDeploy(value)
+		case "profiles":
+			var profiles []string
+			profiles, err = decodeStrings(value)
+			svc.RuntimeEnv = manifest.EnvSelector(profiles)
+		case "labels":
+			sc.labels, err = decodeKeyValues(value)
+		case "secrets":
+			sc.lose("secrets are not supported, they won't be available to the container")
+		case "networks":
+			sc.lose("networks are not supported, all services share the project's network")
+		case "container_name":
+			sc.lose("container_name is not supported, box names containers itself")
+		default:
+			if !strings.HasPrefix(pair.key, "x-") {
+				sc.lose("%v is not supported", pair.key)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Service: %v\nUnable to read %v: %w", name, pair.key, err)
+		}
+	}
+
+	if sc.hasBuild {
+		if svc.Image != "" {
+			sc.lose("image %v is replaced by the locally built image @/%v", svc.Image, name)
+		}
+		svc.Image = manifest.LocalImagePrefix + name
+	}
+
+	// Values given in environment take precedence over those read from env files
+	for key, value := range sc.env {
+		sc.envFiles[key] = value
+	}
+	refs := []string{}
+	for key, value := range sc.envFiles {
+		if _, ok := sc.env[key]; !ok && value != nil {
+			refs = append(refs, key)
+		}
+	}
+	if len(refs) > 0 {
+		sort.Strings(refs)
+		sc.lose("environment variables %v were read from env files and imported as secret references, set each with: box secrets set NAME", strings.Join(refs, ", "))
+	}
+	if len(sc.envFiles) > 0 {
+		svc.Environment = map[string]string{}
+	}
+	inherited := []string{}
+	for key, value := range sc.envFiles {
+		if value == nil {
+			inherited = append(inherited, key)
+			svc.Environment[key] = fmt.Sprintf("${%v}", key)
+			continue
+		}
+		svc.Environment[key] = *value
+	}
+	if len(inherited) > 0 {
+		sort.Strings(inherited)
+		sc.lose("environment variables %v take their values from the shell, which isn't supported, imported as ${NAME}", strings.Join(inherited, ", "))
+	}
+
+	if sc.loopbackOut {
+		sc.lose("ports without a host IP are published on 127.0.0.1 only, prefix them with 0.0.0.0: to publish on all interfaces")
+	}
+
+	if err := sc.convertRouting(); err != nil {
+		return err
+	}
+
+	c.mfst.Services[name] = svc
+	return nil
+}
+
+// convertBuild converts either a build context path, or a build mapping
+func (sc *serviceConverter) convertBuild(node *yaml.Node) error {
+	bi := &sc.svc.Build
+
+	var context string
+	if err := node.Decode(&context); err != nil {
+		for _, pair := range mappingPairs(node) {
+			var err error
+			switch pair.key {
+			case "context":
+				err = pair.value.Decode(&context)
+			case "dockerfile":
+				err = pair.value.Decode(&bi.Dockerfile)
+			case "args":
+				var args map[string]*string
+				if args, err = decodeKeyValues(pair.value); err == nil && len(args) > 0 {
+					bi.Args = map[string]string{}
+					for key, value := range args {
+						if value == nil {
+							sc.lose("build arg %v takes its value from the shell, which isn't supported, imported as ${%v}", key, key)
+							bi.Args[key] = fmt.Sprintf("${%v}", key)
+							continue
+						}
+						bi.Args[key] = *value
+					}
+				}
+			case "target":
+				err = pair.value.Decode(&bi.Target)
+			case "cache_from":
+				bi.CacheFrom, err = decodeStrings(pair.value)
+			case "secrets":
+				err = sc.convertBuildSecrets(pair.value)
+			case "platforms":
+				var platforms []string
+				if platforms, err = decodeStrings(pair.value); err == nil {
+					if len(platforms) == 1 {
+						bi.Platform = platforms[0]
+					} else {
+						sc.lose("build for multiple platforms is not supported")
+					}
+				}
+			default:
+				sc.lose("build %v is not supported", pair.key)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if context == "" {
+		context = "."
+	}
+	if strings.Contains(context, "://") || strings.HasPrefix(context, "git@") {
+		sc.lose("remote build context %v is not supported, clone it and build from the local path instead", context)
+		*bi = manifest.BuildInfo{}
+		return nil
+	}
+
+	bi.Context = sc.path(context)
+	if bi.Dockerfile == "" {
+		bi.Dockerfile = "./Dockerfile"
+	}
+	sc.hasBuild = true
+
+	return nil
+}
+
+// convertBuildSecrets converts build secrets, which refer to top level secrets by name
+func (sc *serviceConverter) convertBuildSecrets(node *yaml.Node) error {
+	type secretRef struct {
+		Source string `yaml:"source"`
+		Target string `yaml:"target"`
+	}
+
+	refs := []secretRef{}
+	names := []string{}
+	if err := node.Decode(&names); err == nil {
+		for _, name := range names {
+			refs = append(refs, secretRef{Source: name})
+		}
+	} else if err := node.Decode(&refs); err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		def, ok := sc.secrets[ref.Source]
+		if !ok {
+			sc.lose("build secret %v isn't defined", ref.Source)
+			continue
+		}
+
+		secret := manifest.BuildSecret{ID: ref.Source}
+		if ref.Target != "" {
+			secret.ID = ref.Target
+		}
+		switch {
+		case def.File != "":
+			secret.Src = sc.path(def.File)
+		case def.Environment != "":
+			secret.Env = def.Environment
+		default:
+			sc.lose("build secret %v must come from a file or an environment variable", ref.Source)
+			continue
+		}
+		sc.svc.Build.Secrets = append(sc.svc.Build.Secrets, secret)
+	}
+
+	return nil
+}
+
+// convertEnvFiles reads the environment files, since the manifest can't refer to them, importing their
+// variables as secret references
+func (sc *serviceConverter) convertEnvFiles(node *yaml.Node) error {
+	type envFile struct {
+		Path     string `yaml:"path"`
+		Required *bool  `yaml:"required"`
+	}
+
+	files := []envFile{}
+	paths, err := decodeStrings(node)
+	if err == nil {
+		for _, path := range paths {
+			files = append(files, envFile{Path: path})
+		}
+	} else if err := node.Decode(&files); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		path := file.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(sc.dir, path)
+		}
+
+		values, err := readEnvFile(path)
+		if err != nil {
+			if os.IsNotExist(err) && file.Required != nil && !*file.Required {
+				continue
+			}
+			return err
+		}
+		// Values are left out of the manifest, which is likely to be committed, in favour of secret references
+		omitted := []string{}
+		for key, value := range values {
+			switch {
+			case value == nil:
+				sc.envFiles[key] = nil
+			case secretNameRe.Match([]byte(key)):
+				ref := manifest.SecretRefPrefix + key
+				sc.envFiles[key] = &ref
+			default:
+				omitted = append(omitted, key)
+			}
+		}
+		sc.lose("env_file %v is not supported, its variables were imported into environment", file.Path)
+		if len(omitted) > 0 {
+			sort.Strings(omitted)
+			sc.lose("env_file %v variables %v were left out, since their names can't be used as secret names", file.Path, strings.Join(omitted, ", "))
+		}
+	}
+
+	return nil
+}
+
+// readEnvFile reads KEY=VALUE lines from an environment file.  Keys without a value are nil.
+func readEnvFile(filename string) (map[string]*string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]*string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) == 1 {
+			values[key] = nil
+			continue
+		}
+
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = &value
+	}
+
+	return values, scanner.Err()
+}
+
+// convertPorts converts port mappings in either the short or long form
+func (sc *serviceConverter) convertPorts(node *yaml.Node) error {
+	type portDef struct {
+		Target    string `yaml:"target"`
+		Published string `yaml:"published"`
+		HostIP    string `yaml:"host_ip"`
+		Protocol  string `yaml:"protocol"`
+	}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for _, item := range node.Content {
+		var spec string
+		if err := item.Decode(&spec); err != nil {
+			port := portDef{}
+			if err := item.Decode(&port); err != nil {
+				return err
+			}
+
+			spec = port.Target
+			if port.Published != "" || port.HostIP != "" {
+				spec = fmt.Sprintf("%v:%v", port.Published, spec)
+			}
+			if port.HostIP != "" {
+				spec = fmt.Sprintf("%v:%v", port.HostIP, spec)
+			}
+			if port.Protocol != "" {
+				spec = fmt.Sprintf("%v/%v", spec, port.Protocol)
+			}
+		}
+
+		sc.svc.Ports = append(sc.svc.Ports, spec)
+		sc.addPorts(spec, true)
+	}
+
+	return nil
+}
+
+// convertVolumes converts volumes in either the short or long form, into binds, named volumes and tmpfs
+// mounts
+func (sc *serviceConverter) convertVolumes(node *yaml.Node) error {
+	type volumeDef struct {
+		Type     string `yaml:"type"`
+		Source   string `yaml:"source"`
+		Target   string `yaml:"target"`
+		ReadOnly bool   `yaml:"read_only"`
+		Tmpfs    struct {
+			Size string `yaml:"size"`
+		} `yaml:"tmpfs"`
+	}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for _, item := range node.Content {
+		vol := volumeDef{}
+
+		var spec string
+		if err := item.Decode(&spec); err == nil {
+			parts := strings.Split(spec, ":")
+			if len(parts) == 1 {
+				sc.lose("anonymous volume %v is not supported, declare a named volume for it instead", spec)
+				continue
+			}
+			vol.Source, vol.Target = parts[0], parts[1]
+			vol.Type = "volume"
+			if strings.ContainsAny(vol.Source[:1], "/.~") {
+				vol.Type = "bind"
+			}
+			if len(parts) > 2 {
+				for _, option := range strings.Split(parts[2], ",") {
+					switch option {
+					case "ro":
+						vol.ReadOnly = true
+					case "rw":
+					default:
+						sc.lose("volume %v option %v is not supported", spec, option)
+					}
+				}
+			}
+		} else {
+			if err := item.Decode(&vol); err != nil {
+				return err
+			}
+			for _, pair := range mappingPairs(item) {
+				switch pair.key {
+				case "type", "source", "target", "read_only", "tmpfs":
+				default:
+					sc.lose("volume %v option %v is not supported", vol.Target, pair.key)
+				}
+			}
+		}
+
+		mode := ""
+		if vol.ReadOnly {
+			mode = ":ro"
+		}
+		switch vol.Type {
+		case "bind":
+			sc.svc.Volumes = append(sc.svc.Volumes, fmt.Sprintf("%v:%v%v", sc.path(vol.Source), vol.Target, mode))
+		case "volume":
+			if vol.Source == "" {
+				sc.lose("anonymous volume %v is not supported, declare a named volume for it instead", vol.Target)
+				continue
+			}
+			if sc.mfst.Volumes[vol.Source] == nil {
+				sc.mfst.Volumes[vol.Source] = &manifest.Volume{}
+			}
+			sc.svc.Volumes = append(sc.svc.Volumes, fmt.Sprintf("%v:%v%v", vol.Source, vol.Target, mode))
+		case "tmpfs":
+			tmpfs := vol.Target
+			if vol.Tmpfs.Size != "" {
+				tmpfs = fmt.Sprintf("%v:size=%v", tmpfs, vol.Tmpfs.Size)
+			}
+			sc.svc.Tmpfs = append(sc.svc.Tmpfs, tmpfs)
+		default:
+			sc.lose("%v volume %v is not supported", vol.Type, vol.Target)
+		}
+	}
+
+	return nil
+}
+
+// convertTmpfs converts tmpfs mounts, keeping only the size and mode options
+func (sc *serviceConverter) convertTmpfs(node *yaml.Node) error {
+	specs, err := decodeStrings(node)
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		options := []string{}
+		if len(parts) == 2 {
+			for _, option := range strings.Split(parts[1], ",") {
+				if strings.HasPrefix(option, "size=") || strings.HasPrefix(option, "mode=") {
+					options = append(options, option)
+				} else {
+					sc.lose("tmpfs %v option %v is not supported", parts[0], option)
+				}
+			}
+		}
+
+		tmpfs := parts[0]
+		if len(options) > 0 {
+			tmpfs = fmt.Sprintf("%v:%v", tmpfs, strings.Join(options, ","))
+		}
+		sc.svc.Tmpfs = append(sc.svc.Tmpfs, tmpfs)
+	}
+
+	return nil
+}
+
+// convertDependencies converts depends_on, which shares the manifest's list and map forms
+func (sc *serviceConverter) convertDependencies(node *yaml.Node) error {
+	if err := node.Decode(&sc.svc.DependsOn); err != nil {
+		return err
+	}
+
+	for _, pair := range mappingPairs(node) {
+		for _, option := range mappingPairs(pair.value) {
+			if option.key != "condition" {
+				sc.lose("depends_on %v option %v is not supported", pair.key, option.key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// convertHealthCheck converts a healthcheck, whose test becomes a shell command
+func (sc *serviceConverter) convertHealthCheck(node *yaml.Node) error {
+	hc := &manifest.HealthCheck{}
+	disabled := false
+
+	for _, pair := range mappingPairs(node) {
+		var err error
+		switch pair.key {
+		case "test":
+			var test []string
+			if test, err = decodeStrings(pair.value); err == nil && len(test) > 0 {
+				switch test[0] {
+				case "NONE":
+					disabled = true
+				case "CMD-SHELL":
+					hc.Command = strings.Join(test[1:], " ")
+				case "CMD":
+					hc.Command = manifest.Command(test[1:]).String()
+				default:
+					hc.Command = strings.Join(test, " ")
+				}
+			}
+		case "disable":
+			err = pair.value.Decode(&disabled)
+		case "interval":
+			err = pair.value.Decode(&hc.Interval)
+		case "timeout":
+			err = pair.value.Decode(&hc.Timeout)
+		case "retries":
+			err = pair.value.Decode(&hc.Retries)
+		case "start_period":
+			err = pair.value.Decode(&hc.StartPeriod)
+		default:
+			sc.lose("healthcheck %v is not supported", pair.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if disabled {
+		return nil
+	}
+	if hc.Command == "" {
+		sc.lose("healthcheck without a test is not supported, the image's own healthcheck is used")
+		return nil
+	}
+	sc.svc.HealthCheck = hc
+
+	return nil
+}
+
+// convertDeploy converts the replica count, resource limits and restart policy from a deploy section
+func (sc *serviceConverter) convertDeploy(node *yaml.Node) error {
+	for _, pair := range mappingPairs(node) {
+		var err error
+		switch pair.key {
+		case "replicas":
+			err = pair.value.Decode(&sc.svc.Replicas)
+		case "resources":
+			for _, resource := range mappingPairs(pair.value) {
+				if resource.key != "limits" {
+					sc.lose("deploy resources %v is not supported", resource.key)
+					continue
+				}
+				for _, limit := range mappingPairs(resource.value) {
+					switch limit.key {
+					case "memory":
+						err = limit.value.Decode(&sc.svc.Memory)
+					case "cpus":
+						sc.svc.CPUs, err = decodeFloat(limit.value)
+					default:
+						sc.lose("deploy resource limit %v is not supported", limit.key)
+					}
+				}
+			}
+		case "restart_policy":
+			err = sc.convertRestartPolicy(pair.value)
+		default:
+			sc.lose("deploy %v is not supported", pair.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertRestartPolicy converts a deploy restart policy, unless the service sets restart itself
+func (sc *serviceConverter) convertRestartPolicy(node *yaml.Node) error {
+	policy := struct {
+		Condition   string `yaml:"condition"`
+		MaxAttempts int    `yaml:"max_attempts"`
+	}{}
+	if err := node.Decode(&policy); err != nil {
+		return err
+	}
+
+	for _, pair := range mappingPairs(node) {
+		if pair.key != "condition" && pair.key != "max_attempts" {
+			sc.lose("deploy restart_policy %v is not supported", pair.key)
+		}
+	}
+
+	if sc.svc.Restart != "" {
+		return nil
+	}
+	switch policy.Condition {
+	case "none":
+		sc.svc.Restart = manifest.RestartNo
+	case "on-failure":
+		sc.svc.Restart = manifest.RestartOnFailure
+		if policy.MaxAttempts > 0 {
+			sc.svc.Restart = fmt.Sprintf("%v:%v", manifest.RestartOnFailure, policy.MaxAttempts)
+		}
+	case "", "any":
+		sc.svc.Restart = manifest.RestartAlways
+	}
+
+	return nil
+}
+
+// decodeFloat decodes a number which may be given as a string
+func decodeFloat(node *yaml.Node) (float64, error) {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
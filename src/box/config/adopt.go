@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Adopt saves the configuration of a project whose resources already exist, such as one rebuilt from the
+// resources tagged for it after its configuration file was lost.  An existing configuration file is never
+// replaced.
+func Adopt(cfg *Config) error {
+	if !ProjectNameRe.Match([]byte(cfg.ProjectName)) {
+		return fmt.Errorf("Project name must only contain lowercase alpha, numbers, or hyphens, between 3 and 20 characters long")
+	}
+
+	projectDir, err := cfg.Dir()
+	if err != nil {
+		return err
+	}
+	configFilePath := filepath.Join(projectDir, configFileName)
+	if _, err := os.Stat(configFilePath); err == nil {
+		return fmt.Errorf("Project %v already has a configuration file at %v", cfg.ProjectName, configFilePath)
+	}
+
+	cfg.Version = CurrentVersion
+	cfg.setCredential(cfg.CredentialName())
+	if cfg.Context != "" {
+		contexts, err := LoadContexts()
+		if err != nil {
+			return err
+		}
+		context, err := contexts.Get(cfg.Context)
+		if err != nil {
+			return err
+		}
+		cfg.followContext(context)
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	dataDir, err := cfg.DataDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, os.FileMode(0755)); err != nil {
+		return err
+	}
+
+	return cfg.Save()
+}
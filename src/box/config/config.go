@@ -5,6 +5,8 @@ import (
 	"box/api/digitalocean/enum/droplet"
 	"box/api/digitalocean/enum/region"
 	"box/api/digitalocean/sshkeys"
+	"box/credentials"
+	"box/sshconn"
 	"bufio"
 	"crypto/sha256"
 	"errors"
@@ -19,29 +21,42 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
-const configDirName = ".box.do"
+// ConfigDirName is the name of the configuration directory, within the user's home directory
+const ConfigDirName = ".box.do"
 const configFileName = "config.yml"
 const dataDirName = "data"
 
+// Name of a key generated for the project, within its configuration directory
+const keyFileName = "id_ed25519"
+
 var ProjectNameRe *regexp.Regexp = regexp.MustCompile("^[a-z][a-z0-9\\-]{1,18}[a-z0-9]$")
 
-// Config holds the project configuration
+// Config holds the project configuration.  Keys are snake case since version 2, see migrations.
 type Config struct {
-	ProjectName        string
-	Email              string
-	DigitalOceanAPIKey string
-	Region             string
-	VolumeSize         int
-	DropletSlug        string
-	BareDomainName     string
-	PrivateKeyFilename string
-	ImageID            int
-	PublicKeyID        int
-	BlockStorageID     string
-	DropletID          int
-	DropletPublicIP    string
-	FirewallID         string
-	projNameHash       string
+	Version     int    `yaml:"version"`
+	ProjectName string `yaml:"project_name"`
+	Email       string `yaml:"email,omitempty"`
+	// Only read, to move the tokens of projects created before they were kept in a credential store
+	DigitalOceanAPIKey string `yaml:"digitalocean_api_key,omitempty"`
+	// Name of the credential holding the API token, empty for the default one or that of the context
+	Credential string `yaml:"credential,omitempty"`
+	// Context whose values the project inherits wherever it leaves them empty
+	Context            string `yaml:"context,omitempty"`
+	Region             string `yaml:"region,omitempty"`
+	VolumeSize         int    `yaml:"volume_size"`
+	DropletSlug        string `yaml:"droplet_slug,omitempty"`
+	BareDomainName     string `yaml:"bare_domain_name"`
+	PrivateKeyFilename string `yaml:"private_key,omitempty"`
+	ImageID            int    `yaml:"image_id"`
+	PublicKeyID        int    `yaml:"public_key_id,omitempty"`
+	BlockStorageID     string `yaml:"block_storage_id"`
+	DropletID          int    `yaml:"droplet_id"`
+	DropletPublicIP    string `yaml:"droplet_public_ip"`
+	FirewallID         string `yaml:"firewall_id"`
+	// Full paths of the project's own provisioning scripts, run in order after box's when building its image
+	Provision    []string `yaml:"provision,omitempty"`
+	projNameHash string
+	inherited    map[string]interface{}
 }
 
 const defaultRegion = region.NYC3
@@ -65,6 +80,34 @@ func CheckProjectExists(projectName string) (bool, error) {
 	return true, nil
 }
 
+// ListProjects returns the names of all initialized projects, sorted
+func ListProjects() ([]string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(configDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	projects := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(configDir, entry.Name(), configFileName)); err == nil {
+			projects = append(projects, entry.Name())
+		}
+	}
+
+	return projects, nil
+}
+
 func isEmailValid(e string) bool {
 	if len(e) < 3 && len(e) > 254 {
 		return false
@@ -79,35 +122,160 @@ func GetConfigDir() (string, error) {
 		return "", errors.New("Unable to determine user's home directory")
 	}
 
-	fullConfigPath := filepath.Join(homeDir, configDirName)
+	fullConfigPath := filepath.Join(homeDir, ConfigDirName)
 	return fullConfigPath, nil
 }
 
 // getTextInput prompts the user for a single line of input, then validates said input.
 // if validation fails, then the user is prompted again (this loop continues until input is valid).
-func getTextInput(prompt string, validationFunc func(string) bool) (string, error) {
-	result := false
-	var input string
-	var err error
-	for result == false {
+func getTextInput(prompt string, validationFunc func(string) error) (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
 		fmt.Println(prompt)
 		fmt.Print(">")
-		reader := bufio.NewReader(os.Stdin)
-		input, err = reader.ReadString('\n')
+		input, err := reader.ReadString('\n')
 		if err != nil {
 			return "", err
 		}
 
 		// Trim any leading or trailing whitespace, including the delimiter
 		input = strings.Trim(input, " \n\t")
-		result = validationFunc(input)
+		err = validationFunc(input)
+		if err == nil {
+			return input, nil
+		}
+		fmt.Println(err)
+	}
+}
+
+// getYesNo prompts the user for a yes or no answer, taking the fallback when none is given
+func getYesNo(prompt string, fallback bool) (bool, error) {
+	choices := "[y/N]"
+	if fallback {
+		choices = "[Y/n]"
+	}
+	answer, err := getTextInput(fmt.Sprintf("%v %v", prompt, choices), func(value string) error {
+		switch strings.ToLower(value) {
+		case "", "y", "yes", "n", "no":
+			return nil
+		}
+		return fmt.Errorf("Please answer y or n")
+	})
+	if err != nil {
+		return false, err
+	}
+	if answer == "" {
+		return fallback, nil
+	}
+
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// generateKey writes a new ed25519 key pair for the project, offering to protect it with a passphrase and
+// load it into the SSH agent when interactive
+func generateKey(filename, projectName string, interactive bool) error {
+	comment := fmt.Sprintf("box-%v", projectName)
+	fmt.Printf("Generating ed25519 key %v...", filename)
+	privateKey, err := sshconn.WriteKeyPair(filename, comment)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	if !interactive {
+		return nil
+	}
+	protect, err := getYesNo("Protect the key with a passphrase?", false)
+	if err != nil || !protect {
+		return err
+	}
+	if err := sshconn.ProtectKey(filename); err != nil {
+		return fmt.Errorf("Unable to protect the key with a passphrase: %w", err)
+	}
+
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		fmt.Println("No SSH agent is running, the key's passphrase will be asked for whenever box connects with it")
+		return nil
+	}
+	load, err := getYesNo("Load the key into the SSH agent?", true)
+	if err != nil || !load {
+		return err
+	}
+	fmt.Print("Loading key into the SSH agent...")
+	if err := sshconn.AddToAgent(privateKey, comment); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+// seedField is a single value of a new project's configuration
+type seedField struct {
+	// Seed file key
+	key   string
+	value *string
+	// Where else the value can be given, when not the flag and environment variable named by the key
+	sources string
+	// Value taken when none is given, or empty if one is required
+	fallback string
+	prompt   string
+	// Prints the choices before prompting
+	choices  func()
+	validate func(string) error
+}
+
+func validateRegion(value string) error {
+	if !region.IsValid(value) {
+		return fmt.Errorf("\"%v\" is not a region, one of: %v", value, strings.Join(region.Values, ", "))
+	}
+
+	return nil
+}
+
+func validateDropletSlug(value string) error {
+	if !droplet.IsValid(value) {
+		return fmt.Errorf("\"%v\" is not a droplet slug, one of: %v", value, strings.Join(droplet.Values, ", "))
+	}
+
+	return nil
+}
+
+func validateEmail(value string) error {
+	if !isEmailValid(value) {
+		return fmt.Errorf("\"%v\" is not an email address", value)
+	}
+
+	return nil
+}
+
+func validateBareDomain(value string) error {
+	// Simple regular expression to reject trivial cases, not meant to be thorough
+	matched, err := regexp.Match("^\\w+\\.\\w{1,3}$", []byte(value))
+	if err != nil {
+		panic(err)
+	}
+	if !matched {
+		return fmt.Errorf("\"%v\" is not a bare domain name (eg: mysite.com)", value)
+	}
+
+	return nil
+}
+
+func validatePrivateKey(value string) error {
+	if _, err := os.Stat(value); err != nil {
+		return fmt.Errorf("Unable to locate or access keyfile %v", value)
+	}
+	if _, err := os.Stat(fmt.Sprintf("%v.pub", value)); err != nil {
+		return fmt.Errorf("Unable to locate or access public keyfile %v.pub", value)
 	}
 
-	return input, nil
+	return nil
 }
 
-// New returns a new config struct based on user input
-func New(projectName string) (*Config, error) {
+// New returns a new config struct based on the seed, prompting the user for anything missing from it when
+// interactive.  Otherwise every missing or invalid value is reported at once.
+func New(projectName string, seed *Seed, interactive bool) (*Config, error) {
 	if !ProjectNameRe.Match([]byte(projectName)) {
 		return nil, fmt.Errorf(
 			"Project name must only contain lowercase alpha, numbers, or hyphens.  It must begin with an alpha character, and may not end with a hyphen.  Project names must be between 3 and 20 characters long.",
@@ -122,175 +290,301 @@ func New(projectName string) (*Config, error) {
 		return nil, fmt.Errorf("A project already exists by the name: %v", projectName)
 	}
 
-	config := Config{ProjectName: projectName}
+	config := Config{Version: CurrentVersion, ProjectName: projectName}
+	// Filled in place, leaving the caller's seed as it was
+	seedCopy := *seed
+	seed = &seedCopy
 
-	apiKey, err := getTextInput(
-		"DigitalOcean API key",
-		func(value string) bool {
-			return len(value) > 0
-		},
-	)
+	contexts, err := LoadContexts()
 	if err != nil {
 		return nil, err
 	}
-	config.DigitalOceanAPIKey = apiKey
-
-	for _, r := range region.Values {
-		fmt.Printf("%v	%v\n", r, region.GetName(r))
+	contextName := seed.Context
+	if contextName == "" {
+		contextName = contexts.Current
 	}
-	r, err := getTextInput(
-		fmt.Sprintf("Deployment region (enter for default %v)", defaultRegion),
-		func(value string) bool {
-			return (len(value) == 0 ||
-				region.IsValid(value))
-		},
-	)
-	if err != nil {
-		return nil, err
+	var context *Context
+	credentialName := credentials.DefaultName
+	if contextName != "" {
+		context, err = contexts.Get(contextName)
+		if err != nil {
+			return nil, err
+		}
+		seed.Merge(context.seed())
+		config.Context = contextName
+		if context.Credential != "" {
+			credentialName = context.Credential
+		}
 	}
 
-	if len(r) == 0 {
-		config.Region = defaultRegion
-	} else {
-		config.Region = r
+	volumeStr := ""
+	if seed.VolumeSize != 0 {
+		volumeStr = strconv.Itoa(seed.VolumeSize)
 	}
-
-	volumeStr, err := getTextInput(
-		"Volume size in gigabytes (minimum 1)",
-		func(value string) bool {
-			i, err := strconv.Atoi(value)
-			if err != nil {
-				return false
-			}
-
-			return i >= 1
-		},
-	)
+	creds, err := Credentials()
 	if err != nil {
 		return nil, err
 	}
-	config.VolumeSize, _ = strconv.Atoi(volumeStr)
-
-	for _, d := range droplet.Values {
-		fmt.Println(d)
+	// A token which is already available is used rather than asked for
+	tokenAvailable := false
+	if seed.APIToken == "" {
+		_, _, err := creds.Get(credentialName)
+		if err != nil && err != credentials.ErrNotFound {
+			return nil, err
+		}
+		tokenAvailable = err == nil
 	}
-	d, err := getTextInput(
-		fmt.Sprintf("Droplet slug (enter for default %v)", defaultDroplet),
-		func(value string) bool {
-			return (len(value) == 0 ||
-				droplet.IsValid(value))
+
+	homeDir, _ := os.UserHomeDir()
+	fields := []seedField{}
+	if !tokenAvailable {
+		fields = append(fields, seedField{
+			key:     "api_token",
+			value:   &seed.APIToken,
+			sources: fmt.Sprintf("--api-token, %v or box auth login --name %v", credentials.EnvToken, credentialName),
+			prompt:  "DigitalOcean API key",
+			validate: func(value string) error {
+				if len(value) == 0 {
+					return fmt.Errorf("An API key is required")
+				}
+				return nil
+			},
+		})
+	}
+	fields = append(fields, []seedField{
+		{
+			key:      "region",
+			value:    &seed.Region,
+			fallback: defaultRegion,
+			prompt:   "Deployment region",
+			choices: func() {
+				for _, r := range region.Values {
+					fmt.Printf("%v	%v\n", r, region.GetName(r))
+				}
+			},
+			validate: validateRegion,
 		},
-	)
-	if err != nil {
-		return nil, err
+		{
+			key:    "volume_size",
+			value:  &volumeStr,
+			prompt: "Volume size in gigabytes (minimum 1)",
+			validate: func(value string) error {
+				i, err := strconv.Atoi(value)
+				if err != nil || i < 1 {
+					return fmt.Errorf("Volume size must be a whole number of gigabytes, at least 1")
+				}
+				return nil
+			},
+		},
+		{
+			key:      "droplet_slug",
+			value:    &seed.DropletSlug,
+			fallback: defaultDroplet,
+			prompt:   "Droplet slug",
+			choices: func() {
+				for _, d := range droplet.Values {
+					fmt.Println(d)
+				}
+			},
+			validate: validateDropletSlug,
+		},
+		{
+			key:      "domain",
+			value:    &seed.Domain,
+			prompt:   "Bare domain name (eg: mysite.com)",
+			validate: validateBareDomain,
+		},
+		{
+			key:      "email",
+			value:    &seed.Email,
+			prompt:   "TLS certificate registration email for use with letsencrypt",
+			validate: validateEmail,
+		},
+	}...)
+	defaultKeyFilename := filepath.Join(homeDir, ".ssh", "id_rsa")
+	if !seed.GenerateKey {
+		fields = append(fields, seedField{
+			key:      "private_key",
+			value:    &seed.PrivateKey,
+			sources:  "--private-key or BOX_PRIVATE_KEY, or generate one with --generate-key",
+			fallback: defaultKeyFilename,
+			prompt:   "Private key full path",
+			validate: validatePrivateKey,
+		})
+	}
+
+	// Validate everything given up front, so that all problems are reported together
+	problems := []string{}
+	for _, field := range fields {
+		if *field.value == "" && !interactive {
+			*field.value = field.fallback
+		}
+		if *field.value == "" {
+			if !interactive {
+				sources := field.sources
+				if sources == "" {
+					sources = seedSources(field.key)
+				}
+				problems = append(problems, fmt.Sprintf("%v: missing, set it with %v", field.key, sources))
+			}
+			continue
+		}
+		if err := field.validate(*field.value); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", field.key, err))
+		}
 	}
-	if len(d) == 0 {
-		d = defaultDroplet
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("Unable to initialize project %v:\n  %v", projectName, strings.Join(problems, "\n  "))
 	}
-	config.DropletSlug = d
 
-	bareDomain, err := getTextInput(
-		"Bare domain name (eg: mysite.com)",
-		func(value string) bool {
-			// Simple regular expression to reject trivial cases, not meant to be thorough
-			matched, err := regexp.Match("^\\w+\\.\\w{1,3}$", []byte(value))
-			if err != nil {
-				panic(err)
-			}
-			return matched
-		},
-	)
-	if err != nil {
-		return nil, err
+	if interactive && !seed.GenerateKey && seed.PrivateKey == "" {
+		// Suggested when there's no default key to fall back to
+		_, err := os.Stat(defaultKeyFilename)
+		seed.GenerateKey, err = getYesNo("Generate a dedicated ed25519 SSH key for the project?", os.IsNotExist(err))
+		if err != nil {
+			return nil, err
+		}
 	}
-	config.BareDomainName = bareDomain
 
-	email, err := getTextInput(
-		"TLS certificate registration email for use with letsencrypt",
-		isEmailValid,
-	)
+	configDir, err := GetConfigDir()
 	if err != nil {
 		return nil, err
 	}
-	config.Email = email
+	if seed.GenerateKey {
+		seed.PrivateKey = filepath.Join(configDir, projectName, keyFileName)
+	}
 
-	homeDir, _ := os.UserHomeDir()
-	defaultKeyFile := filepath.Join(homeDir, ".ssh", "id_rsa")
-	pkFilename, err := getTextInput(
-		fmt.Sprintf("Private key full path (Enter for default %v)", defaultKeyFile),
-		func(value string) bool {
-			filename := value
-			if len(value) == 0 {
-				filename = defaultKeyFile
-			}
-			_, err := os.Stat(filename)
-			if err != nil {
-				fmt.Println("Unable to locate or access keyfile")
-				return false
-			}
+	for _, field := range fields {
+		if *field.value != "" {
+			continue
+		}
 
-			return true
-		},
-	)
-	if err != nil {
-		return nil, err
+		field := field
+		prompt := field.prompt
+		if field.fallback != "" {
+			prompt = fmt.Sprintf("%v (enter for default %v)", prompt, field.fallback)
+		}
+		if field.choices != nil {
+			field.choices()
+		}
+		value, err := getTextInput(prompt, func(value string) error {
+			if len(value) == 0 && field.fallback != "" {
+				return nil
+			}
+			return field.validate(value)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			value = field.fallback
+		}
+		*field.value = value
 	}
-	if pkFilename == "" {
-		pkFilename = defaultKeyFile
+
+	config.Region = seed.Region
+	config.VolumeSize, _ = strconv.Atoi(volumeStr)
+	config.DropletSlug = seed.DropletSlug
+	config.BareDomainName = seed.Domain
+	config.Email = seed.Email
+	config.PrivateKeyFilename = seed.PrivateKey
+
+	created := false
+	if seed.GenerateKey {
+		if err := os.MkdirAll(filepath.Join(configDir, projectName), os.FileMode(0755)); err != nil {
+			return nil, err
+		}
+		// Removed along with the key should anything below fail, since init refuses to run over an existing project
+		defer func() {
+			if !created {
+				os.RemoveAll(filepath.Join(configDir, projectName))
+			}
+		}()
+		if err := generateKey(config.PrivateKeyFilename, projectName, interactive); err != nil {
+			return nil, err
+		}
 	}
-	config.PrivateKeyFilename = pkFilename
 
 	// Load public key
-	pbkFilename := fmt.Sprintf("%v.pub", pkFilename)
+	pbkFilename := fmt.Sprintf("%v.pub", config.PrivateKeyFilename)
 	pbkData, err := ioutil.ReadFile(pbkFilename)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to read public key file %v", pbkFilename)
 	}
 
-	configDir, err := GetConfigDir()
-	if err != nil {
-		return nil, err
-	}
-
-	dataDir := filepath.Join(configDir, projectName, dataDirName)
-	os.MkdirAll(dataDir, os.FileMode(0755))
-
 	configFilePath := filepath.Join(
 		configDir,
 		projectName,
 		configFileName,
 	)
 
-	doSvc := digitalocean.NewService(apiKey)
-	fmt.Printf("Checking for an existing matching SSH public key...")
-	keys, err := sshkeys.GetAll(doSvc)
-	if err != nil {
-		return nil, err
-	}
-	strKey := strings.Trim(string(pbkData), " \n\t")
-	var publicKeyID int
-	for _, key := range keys {
-		if key.PublicKey == strKey {
-			publicKeyID = key.ID
-			break
+	apiToken := seed.APIToken
+	if tokenAvailable {
+		apiToken, _, err = creds.Get(credentialName)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	if publicKeyID == 0 {
-		fmt.Println("Not found")
-		createdKey, err := sshkeys.Create(doSvc, fmt.Sprintf("box-key-%v", strings.ToLower(projectName)), strKey)
+	// The context's key was already registered with its account
+	sharesContextKey := context != nil && context.PrivateKeyFilename == config.PrivateKeyFilename
+	if sharesContextKey && context.PublicKeyID != 0 {
+		config.PublicKeyID = context.PublicKeyID
+	} else {
+		doSvc := digitalocean.NewService(apiToken)
+		fmt.Printf("Checking for an existing matching SSH public key...")
+		keys, err := sshkeys.GetAll(doSvc)
 		if err != nil {
-			fmt.Println("Unable to post new SSH public key to DigitalOcean")
 			return nil, err
 		}
+		strKey := strings.Trim(string(pbkData), " \n\t")
+		var publicKeyID int
+		for _, key := range keys {
+			if key.PublicKey == strKey {
+				publicKeyID = key.ID
+				break
+			}
+		}
 
-		config.PublicKeyID = createdKey.ID
-	} else {
-		fmt.Println("Found")
-		config.PublicKeyID = publicKeyID
+		if publicKeyID == 0 {
+			fmt.Println("Not found")
+			createdKey, err := sshkeys.Create(doSvc, fmt.Sprintf("box-key-%v", strings.ToLower(projectName)), strKey)
+			if err != nil {
+				fmt.Println("Unable to post new SSH public key to DigitalOcean")
+				return nil, err
+			}
+
+			config.PublicKeyID = createdKey.ID
+		} else {
+			fmt.Println("Found")
+			config.PublicKeyID = publicKeyID
+		}
 	}
 
-	configBytes, err := yaml.Marshal(&config)
+	// Only stored once it has been used successfully
+	if !tokenAvailable {
+		name, err := creds.Adopt(credentialName, projectName, apiToken)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to store the API token: %w", err)
+		}
+		config.setCredential(name)
+	}
+
+	if context != nil {
+		if sharesContextKey && context.PublicKeyID != config.PublicKeyID {
+			context.PublicKeyID = config.PublicKeyID
+			if err := contexts.Save(); err != nil {
+				return nil, err
+			}
+		}
+		config.followContext(context)
+	}
+
+	// Created only once nothing else can fail, since init refuses to run over an existing project
+	dataDir := filepath.Join(configDir, projectName, dataDirName)
+	os.MkdirAll(dataDir, os.FileMode(0755))
+
+	configBytes, err := config.Marshal()
 	if err != nil {
 		return nil, err
 	}
@@ -300,6 +594,7 @@ func New(projectName string) (*Config, error) {
 		return nil, fmt.Errorf("Unable to write configuration file %v", configFilePath)
 	}
 
+	created = true
 	fmt.Println("All done!")
 
 	return &config, nil
@@ -323,15 +618,133 @@ func Load(projectName string) (*Config, error) {
 		return nil, fmt.Errorf("Unable to read file at %v, are you sure the project exists?", configFilePath)
 	}
 
-	config := Config{}
-	err = yaml.Unmarshal(configData, &config)
+	configData, migrated, err := migrate(configFilePath, configData)
 	if err != nil {
 		return nil, err
 	}
 
+	config := Config{}
+	err = yaml.UnmarshalStrict(configData, &config)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to process configuration file %v: %w", configFilePath, err)
+	}
+
+	if config.Context != "" {
+		contexts, err := LoadContexts()
+		if err != nil {
+			return nil, err
+		}
+		context, err := contexts.Get(config.Context)
+		if err != nil {
+			return nil, fmt.Errorf("Project %v uses a context which doesn't exist: %w", projectName, err)
+		}
+		config.inherit(context)
+	}
+
+	// Written in full once decoded, so that it holds every field of the current version
+	if migrated {
+		if err := config.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.DigitalOceanAPIKey != "" {
+		config.migrateAPIToken()
+	}
+
 	return &config, nil
 }
 
+// ErrNoAPIToken is returned when a project's API token can't be found
+var ErrNoAPIToken = errors.New("No DigitalOcean API token is available")
+
+// Loaded once, so that an encrypted credential file is only unlocked once
+var credentialStore *credentials.Store
+
+// Credentials returns the credential store shared by all projects
+func Credentials() (*credentials.Store, error) {
+	if credentialStore != nil {
+		return credentialStore, nil
+	}
+
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	credentialStore, err = credentials.Load(configDir)
+
+	return credentialStore, err
+}
+
+func (cfg *Config) setCredential(name string) {
+	// Left empty when it's the default credential, or the one inherited from the context
+	if name == credentials.DefaultName && cfg.Context == "" {
+		name = ""
+	}
+	cfg.Credential = name
+}
+
+// CredentialName returns the name of the credential holding the project's API token
+func (cfg *Config) CredentialName() string {
+	if cfg.Credential == "" {
+		return credentials.DefaultName
+	}
+
+	return cfg.Credential
+}
+
+// migrateAPIToken moves an API token kept in the configuration file into the credential store.  When that
+// isn't possible the token is still used from the file, and moved on a later run.
+func (cfg *Config) migrateAPIToken() {
+	creds, err := Credentials()
+	name := ""
+	if err == nil {
+		name, err = creds.Adopt(cfg.CredentialName(), cfg.ProjectName, cfg.DigitalOceanAPIKey)
+	}
+	if err != nil {
+		fmt.Printf("Unable to move the API token of project %v out of its configuration file: %v\n", cfg.ProjectName, err)
+		return
+	}
+
+	token := cfg.DigitalOceanAPIKey
+	cfg.DigitalOceanAPIKey = ""
+	cfg.setCredential(name)
+	if err := cfg.Save(); err != nil {
+		cfg.DigitalOceanAPIKey = token
+		fmt.Printf("Unable to remove the API token of project %v from its configuration file: %v\n", cfg.ProjectName, err)
+		return
+	}
+	fmt.Printf("Moved the API token of project %v out of its configuration file, into the %v credential store\n", cfg.ProjectName, creds.Source())
+}
+
+// APIToken returns the project's DigitalOcean API token
+func (cfg *Config) APIToken() (string, error) {
+	// Not yet moved out of the configuration file
+	if cfg.DigitalOceanAPIKey != "" && os.Getenv(credentials.EnvToken) == "" {
+		return cfg.DigitalOceanAPIKey, nil
+	}
+
+	creds, err := Credentials()
+	if err != nil {
+		return "", err
+	}
+	token, _, err := creds.Get(cfg.CredentialName())
+	if err == credentials.ErrNotFound {
+		return "", fmt.Errorf(
+			"%w for project %v, run box auth login --name %v or set %v",
+			ErrNoAPIToken,
+			cfg.ProjectName,
+			cfg.CredentialName(),
+			credentials.EnvToken,
+		)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
 // Save saves an existing configuration to disk
 func (cfg *Config) Save() error {
 	configDir, err := GetConfigDir()
@@ -343,7 +756,7 @@ func (cfg *Config) Save() error {
 		cfg.ProjectName,
 		configFileName,
 	)
-	data, err := yaml.Marshal(cfg)
+	data, err := cfg.Marshal()
 	if err != nil {
 		return err
 	}
@@ -351,6 +764,16 @@ func (cfg *Config) Save() error {
 	return err
 }
 
+// Dir returns the full path to the project's configuration directory
+func (cfg *Config) Dir() (string, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(configDir, cfg.ProjectName), nil
+}
+
 // DataDir returns the full path to the data directory which serves as the bind mount root
 func (cfg *Config) DataDir() (string, error) {
 	configDir, err := GetConfigDir()
@@ -373,3 +796,14 @@ func (cfg *Config) ProjectNameHash() string {
 
 	return cfg.projNameHash
 }
+
+// ProjectTag returns the tag of every DigitalOcean resource which box creates for the project.  Tag names
+// may only hold letters, numbers, colons, hyphens and underscores.
+func ProjectTag(projectName string) string {
+	return fmt.Sprintf("box:project:%v", projectName)
+}
+
+// Tag returns the tag of every DigitalOcean resource which box creates for the project
+func (cfg *Config) Tag() string {
+	return ProjectTag(cfg.ProjectName)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"box/credentials"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setenv(t *testing.T, key, value string) {
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// writeProject points the home directory to a new temporary one, holding a project configuration file with
+// the given contents
+func writeProject(t *testing.T, projectName, content string) string {
+	setenv(t, "HOME", t.TempDir())
+	credentialStore = nil
+	t.Cleanup(func() { credentialStore = nil })
+
+	configDir, err := GetConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectDir := filepath.Join(configDir, projectName)
+	if err := os.MkdirAll(projectDir, os.FileMode(0700)); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(projectDir, configFileName)
+	if err := ioutil.WriteFile(filename, []byte(content), os.FileMode(0600)); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
+
+func TestMigrateAPIToken(t *testing.T) {
+	setenv(t, credentials.EnvToken, "")
+	setenv(t, credentials.EnvPassphrase, "correct horse")
+
+	tests := []struct {
+		name           string
+		content        string
+		existing       string
+		wantCredential string
+		wantBackup     string
+	}{
+		{
+			name:    "current version",
+			content: "version: 2\nproject_name: myproject\ndigitalocean_api_key: token-1\n",
+		},
+		{
+			name:       "unversioned",
+			content:    "projectname: myproject\ndigitaloceanapikey: token-1\n",
+			wantBackup: "config.yml.v1.bak",
+		},
+		{
+			name:     "same token already stored",
+			content:  "version: 2\nproject_name: myproject\ndigitalocean_api_key: token-1\n",
+			existing: "token-1",
+		},
+		{
+			name:           "different token already stored",
+			content:        "version: 2\nproject_name: myproject\ndigitalocean_api_key: token-1\n",
+			existing:       "token-2",
+			wantCredential: "myproject",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := writeProject(t, "myproject", test.content)
+			creds, err := Credentials()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := creds.Configure(credentials.SourceFile, ""); err != nil {
+				t.Fatal(err)
+			}
+			if test.existing != "" {
+				if err := creds.Set(credentials.DefaultName, test.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := Load("myproject")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DigitalOceanAPIKey != "" {
+				t.Errorf("the token was left in the configuration")
+			}
+			if cfg.Credential != test.wantCredential {
+				t.Errorf("got credential %q, want %q", cfg.Credential, test.wantCredential)
+			}
+			token, err := cfg.APIToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "token-1" {
+				t.Errorf("got token %v, want token-1", token)
+			}
+
+			saved, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(saved), "token-1") {
+				t.Errorf("the token was left in the configuration file:\n%s", saved)
+			}
+			if test.wantBackup != "" {
+				backup, err := ioutil.ReadFile(filepath.Join(filepath.Dir(filename), test.wantBackup))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(string(backup), "token-1") {
+					t.Errorf("the token was kept in the backup:\n%s", backup)
+				}
+			}
+			if test.existing != "" {
+				if stored, _, _ := creds.Get(credentials.DefaultName); stored != test.existing {
+					t.Errorf("the existing token was replaced with %v", stored)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"box/credentials"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const contextsFileName = "contexts.yml"
+
+// Context is a named account, along with the defaults of projects created against it.  Field names match
+// those of Config, which a project inherits whenever it leaves them empty.
+type Context struct {
+	Name               string `yaml:"-"`
+	Credential         string `yaml:"credential,omitempty"`
+	Region             string `yaml:"region,omitempty"`
+	DropletSlug        string `yaml:"droplet_slug,omitempty"`
+	Email              string `yaml:"email,omitempty"`
+	PrivateKeyFilename string `yaml:"private_key,omitempty"`
+	// ID of the public key once registered with the account, shared by every project using the same key
+	PublicKeyID int `yaml:"public_key_id,omitempty"`
+}
+
+// Validate checks each of the context's values which is set
+func (context *Context) Validate() error {
+	problems := []string{}
+	check := func(value string, validate func(string) error) {
+		if value == "" {
+			return
+		}
+		if err := validate(value); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	check(context.Region, validateRegion)
+	check(context.DropletSlug, validateDropletSlug)
+	check(context.Email, validateEmail)
+	check(context.PrivateKeyFilename, validatePrivateKey)
+	check(context.Credential, credentials.ValidateName)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Context %v is invalid:\n  %v", context.Name, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// Config fields which a project inherits from its context
+var contextFields []string = []string{
+	"Credential",
+	"Region",
+	"DropletSlug",
+	"Email",
+	"PrivateKeyFilename",
+	"PublicKeyID",
+}
+
+// Contexts holds every context, and the one which new projects are created against
+type Contexts struct {
+	Current  string              `yaml:"current,omitempty"`
+	Contexts map[string]*Context `yaml:"contexts,omitempty"`
+}
+
+// LoadContexts loads the contexts shared by all projects
+func LoadContexts() (*Contexts, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	contexts := Contexts{}
+	data, err := ioutil.ReadFile(filepath.Join(configDir, contextsFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := yaml.Unmarshal(data, &contexts); err != nil {
+			return nil, fmt.Errorf("Unable to process %v: %w", contextsFileName, err)
+		}
+	}
+	if contexts.Contexts == nil {
+		contexts.Contexts = map[string]*Context{}
+	}
+	for name, context := range contexts.Contexts {
+		if context == nil {
+			context = &Context{}
+			contexts.Contexts[name] = context
+		}
+		context.Name = name
+	}
+
+	return &contexts, nil
+}
+
+// Save saves the contexts to disk
+func (contexts *Contexts) Save() error {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, os.FileMode(0700)); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(contexts)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(configDir, contextsFileName), data, os.FileMode(0600))
+}
+
+// Get returns the context by name
+func (contexts *Contexts) Get(name string) (*Context, error) {
+	context, ok := contexts.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("No context exists by the name: %v, add it with box context add %v", name, name)
+	}
+
+	return context, nil
+}
+
+// Names returns the names of all contexts, sorted
+func (contexts *Contexts) Names() []string {
+	names := []string{}
+	for name := range contexts.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// inherit fills the project's empty fields from the context, remembering which were filled so that they're
+// not saved with the project
+func (cfg *Config) inherit(context *Context) {
+	cfgValue := reflect.ValueOf(cfg).Elem()
+	contextValue := reflect.ValueOf(context).Elem()
+	cfg.inherited = map[string]interface{}{}
+	for _, field := range contextFields {
+		value := cfgValue.FieldByName(field)
+		if value.IsZero() {
+			inheritedValue := contextValue.FieldByName(field)
+			value.Set(inheritedValue)
+			cfg.inherited[field] = inheritedValue.Interface()
+		}
+	}
+}
+
+// followContext marks the project's values which match the context's as inherited, so that the project
+// follows any later change to the context
+func (cfg *Config) followContext(context *Context) {
+	cfgValue := reflect.ValueOf(cfg).Elem()
+	contextValue := reflect.ValueOf(context).Elem()
+	cfg.inherited = map[string]interface{}{}
+	for _, field := range contextFields {
+		contextFieldValue := contextValue.FieldByName(field).Interface()
+		if cfgValue.FieldByName(field).Interface() == contextFieldValue {
+			cfg.inherited[field] = contextFieldValue
+		}
+	}
+}
+
+// seed returns the context's values as defaults of a new project
+func (context *Context) seed() *Seed {
+	return &Seed{
+		Region:      context.Region,
+		DropletSlug: context.DropletSlug,
+		Email:       context.Email,
+		PrivateKey:  context.PrivateKeyFilename,
+	}
+}
+
+// uninherited returns a copy of the project without the values it inherited from its context, unless they
+// were changed since
+func (cfg *Config) uninherited() *Config {
+	saved := *cfg
+	savedValue := reflect.ValueOf(&saved).Elem()
+	for field, inheritedValue := range cfg.inherited {
+		value := savedValue.FieldByName(field)
+		if value.Interface() == inheritedValue {
+			value.Set(reflect.Zero(value.Type()))
+		}
+	}
+
+	return &saved
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"box/credentials"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Keys which box manages itself, and which can only be read
+var readOnlyKeys map[string]bool = map[string]bool{
+	"version":           true,
+	"project_name":      true,
+	"image_id":          true,
+	"public_key_id":     true,
+	"block_storage_id":  true,
+	"droplet_id":        true,
+	"droplet_public_ip": true,
+	"firewall_id":       true,
+}
+
+// Keys which are never shown, nor set
+var hiddenKeys map[string]bool = map[string]bool{
+	"digitalocean_api_key": true,
+}
+
+// fieldByKey returns the field of the configuration file key
+func (cfg *Config) fieldByKey(key string) (reflect.Value, string, error) {
+	cfgType := reflect.TypeOf(cfg).Elem()
+	for i := 0; i < cfgType.NumField(); i++ {
+		field := cfgType.Field(i)
+		tagKey := strings.SplitN(field.Tag.Get("yaml"), ",", 2)[0]
+		if tagKey != "" && tagKey == key && !hiddenKeys[key] {
+			return reflect.ValueOf(cfg).Elem().Field(i), field.Name, nil
+		}
+	}
+
+	return reflect.Value{}, "", fmt.Errorf("Unknown configuration key \"%v\", one of: %v", key, strings.Join(Keys(), ", "))
+}
+
+// Keys returns every configuration file key which can be read
+func Keys() []string {
+	keys := []string{}
+	cfgType := reflect.TypeOf(Config{})
+	for i := 0; i < cfgType.NumField(); i++ {
+		key := strings.SplitN(cfgType.Field(i).Tag.Get("yaml"), ",", 2)[0]
+		if key != "" && !hiddenKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Get returns the value of the key, and whether it's inherited from the project's context
+func (cfg *Config) Get(key string) (string, bool, error) {
+	value, fieldName, err := cfg.fieldByKey(key)
+	if err != nil {
+		return "", false, err
+	}
+
+	inheritedValue, inherited := cfg.inherited[fieldName]
+	inherited = inherited && value.Interface() == inheritedValue && !value.IsZero()
+
+	return fmt.Sprint(value.Interface()), inherited, nil
+}
+
+// Set changes the value of the key, validating it.  An empty value makes a project inherit the context's.
+func (cfg *Config) Set(key, value string) error {
+	if readOnlyKeys[key] {
+		return fmt.Errorf("%v is managed by box, and can't be set", key)
+	}
+	field, fieldName, err := cfg.fieldByKey(key)
+	if err != nil {
+		return err
+	}
+
+	updated := *cfg
+	updatedField := reflect.ValueOf(&updated).Elem().FieldByName(fieldName)
+	switch field.Kind() {
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%v must be a whole number", key)
+		}
+		updatedField.SetInt(int64(i))
+	case reflect.Slice:
+		return fmt.Errorf("%v is a list, change it with box config edit", key)
+	default:
+		updatedField.SetString(value)
+	}
+
+	if key == "context" && value != "" {
+		contexts, err := LoadContexts()
+		if err != nil {
+			return err
+		}
+		if _, err := contexts.Get(value); err != nil {
+			return err
+		}
+	}
+	if key == "private_key" && value != "" {
+		if err := validatePrivateKey(value); err != nil {
+			return err
+		}
+	}
+	if value == "" && !cfg.inheritable(fieldName) {
+		return fmt.Errorf("%v is required", key)
+	}
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+
+	field.Set(updatedField)
+	// Set explicitly, rather than inherited
+	delete(cfg.inherited, fieldName)
+
+	return nil
+}
+
+// inheritable returns true if the field can be left empty to inherit the context's value
+func (cfg *Config) inheritable(fieldName string) bool {
+	if fieldName == "Credential" {
+		// Empty for the default credential when there's no context
+		return true
+	}
+	if cfg.Context == "" {
+		return false
+	}
+	for _, field := range contextFields {
+		if field == fieldName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the configuration against the rules applied when a project is created.  Values left
+// empty to be inherited from a context aren't checked, nor is the presence of the private key, which may
+// live on another machine.
+func (cfg *Config) Validate() error {
+	problems := []string{}
+	check := func(key, value string, validate func(string) error) {
+		if value == "" {
+			return
+		}
+		if err := validate(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", key, err))
+		}
+	}
+
+	if !ProjectNameRe.Match([]byte(cfg.ProjectName)) {
+		problems = append(problems, fmt.Sprintf("project_name: \"%v\" must only contain lowercase alpha, numbers, or hyphens, between 3 and 20 characters long", cfg.ProjectName))
+	}
+	check("region", cfg.Region, validateRegion)
+	check("droplet_slug", cfg.DropletSlug, validateDropletSlug)
+	check("email", cfg.Email, validateEmail)
+	check("bare_domain_name", cfg.BareDomainName, validateBareDomain)
+	check("credential", cfg.Credential, credentials.ValidateName)
+	for _, filename := range cfg.Provision {
+		if !filepath.IsAbs(filename) {
+			problems = append(problems, fmt.Sprintf("provision: %v must be a full path", filename))
+		}
+	}
+	if cfg.VolumeSize < 1 {
+		problems = append(problems, "volume_size: Volume size must be a whole number of gigabytes, at least 1")
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Configuration of project %v is invalid:\n  %v", cfg.ProjectName, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// Marshal returns the configuration as it's saved, without any values inherited from its context
+func (cfg *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(cfg.uninherited())
+}
+
+// Update returns the configuration replaced by the saved form in data, such as an edited copy of Marshal's
+// output, after validating it
+func (cfg *Config) Update(data []byte) (*Config, error) {
+	updated := Config{}
+	if err := yaml.UnmarshalStrict(data, &updated); err != nil {
+		return nil, err
+	}
+
+	original := cfg.uninherited()
+	originalValue := reflect.ValueOf(original).Elem()
+	updatedValue := reflect.ValueOf(&updated).Elem()
+	for key := range readOnlyKeys {
+		_, fieldName, _ := cfg.fieldByKey(key)
+		if originalValue.FieldByName(fieldName).Interface() != updatedValue.FieldByName(fieldName).Interface() {
+			return nil, fmt.Errorf("%v is managed by box, and can't be changed", key)
+		}
+	}
+	updated.DigitalOceanAPIKey = cfg.DigitalOceanAPIKey
+
+	if updated.PrivateKeyFilename != "" && updated.PrivateKeyFilename != original.PrivateKeyFilename {
+		if err := validatePrivateKey(updated.PrivateKeyFilename); err != nil {
+			return nil, err
+		}
+	}
+	if updated.Context != "" {
+		contexts, err := LoadContexts()
+		if err != nil {
+			return nil, err
+		}
+		context, err := contexts.Get(updated.Context)
+		if err != nil {
+			return nil, err
+		}
+		updated.inherit(context)
+	}
+	if err := updated.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Seed holds the values of a new project's configuration, each of which is otherwise prompted for.  The
+// seed file key of each value also names its init flag and environment variable, eg: droplet_slug is set by
+// --droplet-slug or BOX_DROPLET_SLUG.
+type Seed struct {
+	APIToken    string `yaml:"api_token"`
+	Region      string `yaml:"region"`
+	VolumeSize  int    `yaml:"volume_size"`
+	DropletSlug string `yaml:"droplet_slug"`
+	Domain      string `yaml:"domain"`
+	Email       string `yaml:"email"`
+	PrivateKey  string `yaml:"private_key"`
+	// Generate a dedicated ed25519 key for the project, instead of using private_key
+	GenerateKey bool `yaml:"generate_key"`
+	// Context supplying the account and any values missing from the rest, the current one when empty
+	Context string `yaml:"context"`
+}
+
+// LoadSeed loads a seed file, rejecting any keys it doesn't know
+func LoadSeed(filename string) (*Seed, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read seed file %v: %w", filename, err)
+	}
+
+	seed := Seed{}
+	if err := yaml.UnmarshalStrict(data, &seed); err != nil {
+		return nil, fmt.Errorf("Unable to process seed file %v: %w", filename, err)
+	}
+
+	return &seed, nil
+}
+
+// Merge fills any values missing from the seed with those of the other
+func (seed *Seed) Merge(other *Seed) {
+	fill := func(value *string, otherValue string) {
+		if *value == "" {
+			*value = otherValue
+		}
+	}
+
+	fill(&seed.APIToken, other.APIToken)
+	fill(&seed.Region, other.Region)
+	fill(&seed.DropletSlug, other.DropletSlug)
+	fill(&seed.Domain, other.Domain)
+	fill(&seed.Email, other.Email)
+	fill(&seed.PrivateKey, other.PrivateKey)
+	fill(&seed.Context, other.Context)
+	if seed.VolumeSize == 0 {
+		seed.VolumeSize = other.VolumeSize
+	}
+	seed.GenerateKey = seed.GenerateKey || other.GenerateKey
+}
+
+// seedSources describes where the value of the seed file key can be given
+func seedSources(key string) string {
+	return fmt.Sprintf(
+		"--%v or BOX_%v",
+		strings.ReplaceAll(key, "_", "-"),
+		strings.ToUpper(key),
+	)
+}
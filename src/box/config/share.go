@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// KnownHostsFileName is the name of the file pinning the host key of the project's droplet
+const KnownHostsFileName = "known_hosts"
+
+// KnownHostsFile returns the full path to the file pinning the host key of the project's droplet
+func (cfg *Config) KnownHostsFile() (string, error) {
+	projectDir, err := cfg.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(projectDir, KnownHostsFileName), nil
+}
+
+// Shared returns the configuration as it's shared with teammates.  Values inherited from the project's
+// context are included, while those which belong to this machine or account holder are left out.
+func (cfg *Config) Shared() ([]byte, error) {
+	shared := *cfg
+	shared.inherited = nil
+	shared.DigitalOceanAPIKey = ""
+	shared.Credential = ""
+	shared.Context = ""
+	shared.PrivateKeyFilename = ""
+	shared.PublicKeyID = 0
+
+	return yaml.Marshal(&shared)
+}
+
+// ParseShared returns the configuration shared by a teammate, see Shared
+func ParseShared(data []byte) (*Config, error) {
+	cfg := Config{}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("Unable to process the shared configuration: %w", err)
+	}
+	if cfg.Version != CurrentVersion {
+		return nil, fmt.Errorf(
+			"The shared configuration is version %v, while this build of box uses version %v, both teammates need the same version of box",
+			cfg.Version,
+			CurrentVersion,
+		)
+	}
+
+	return &cfg, nil
+}
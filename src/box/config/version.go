@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// CurrentVersion is the version of the configuration files written by this build
+const CurrentVersion = 2
+
+// Files without a version predate versioning
+const unversioned = 1
+
+// migration upgrades the raw contents of a configuration file by a single version
+type migration func(raw map[string]interface{}) error
+
+// migrations[i] upgrades a file from version i+1 to version i+2
+var migrations []migration = []migration{
+	migrateSnakeCaseKeys,
+}
+
+// Version 1 keys were the lowercased field names
+var snakeCaseKeys map[string]string = map[string]string{
+	"projectname":        "project_name",
+	"digitaloceanapikey": "digitalocean_api_key",
+	"volumesize":         "volume_size",
+	"dropletslug":        "droplet_slug",
+	"baredomainname":     "bare_domain_name",
+	"privatekeyfilename": "private_key",
+	"imageid":            "image_id",
+	"publickeyid":        "public_key_id",
+	"blockstorageid":     "block_storage_id",
+	"dropletid":          "droplet_id",
+	"dropletpublicip":    "droplet_public_ip",
+	"firewallid":         "firewall_id",
+}
+
+func migrateSnakeCaseKeys(raw map[string]interface{}) error {
+	for oldKey, newKey := range snakeCaseKeys {
+		value, ok := raw[oldKey]
+		if !ok {
+			continue
+		}
+		if _, exists := raw[newKey]; exists {
+			return fmt.Errorf("Both %v and %v are present", oldKey, newKey)
+		}
+		raw[newKey] = value
+		delete(raw, oldKey)
+	}
+
+	return nil
+}
+
+// migrate upgrades the contents of the configuration file to the current version, keeping a backup of the
+// file as it was.  It returns the upgraded contents, and whether there was anything to upgrade.
+func migrate(filename string, data []byte) ([]byte, bool, error) {
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, false, fmt.Errorf("Unable to process configuration file %v: %w", filename, err)
+	}
+
+	version := unversioned
+	if value, ok := raw["version"]; ok {
+		if version, ok = value.(int); !ok || version < unversioned {
+			return nil, false, fmt.Errorf("Configuration file %v has an invalid version: %v", filename, value)
+		}
+	}
+	if version > CurrentVersion {
+		return nil, false, fmt.Errorf(
+			"Configuration file %v is version %v, which is newer than this build of box supports (%v), please upgrade box",
+			filename,
+			version,
+			CurrentVersion,
+		)
+	}
+	if version == CurrentVersion {
+		return data, false, nil
+	}
+
+	// The API token is left out of the backup, it stays in the migrated file until it's moved into the
+	// credential store
+	backup := map[string]interface{}{}
+	for key, value := range raw {
+		if key != "digitaloceanapikey" && key != "digitalocean_api_key" {
+			backup[key] = value
+		}
+	}
+	backupData := data
+	if len(backup) != len(raw) {
+		var err error
+		backupData, err = yaml.Marshal(backup)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	backupFilename := fmt.Sprintf("%v.v%v.bak", filename, version)
+	if err := ioutil.WriteFile(backupFilename, backupData, os.FileMode(0600)); err != nil {
+		return nil, false, fmt.Errorf("Unable to back up configuration file %v: %w", filename, err)
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		if err := migrations[v-unversioned](raw); err != nil {
+			return nil, false, fmt.Errorf("Unable to migrate configuration file %v from version %v to %v: %w", filename, v, v+1, err)
+		}
+	}
+	raw["version"] = CurrentVersion
+
+	migrated, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	fmt.Printf("Migrated configuration file %v from version %v to %v, the previous file was kept as %v\n", filename, version, CurrentVersion, backupFilename)
+
+	return migrated, true, nil
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		want         map[string]interface{}
+		wantMigrated bool
+		wantBackup   map[string]interface{}
+		wantErr      bool
+	}{
+		{
+			name:    "current version",
+			content: "version: 2\nproject_name: myproject\n",
+			want:    map[string]interface{}{"version": 2, "project_name": "myproject"},
+		},
+		{
+			name:         "version 1 keys",
+			content:      "projectname: myproject\nvolumesize: 5\nprivatekeyfilename: /keys/id\ndropletid: 42\n",
+			want:         map[string]interface{}{"version": 2, "project_name": "myproject", "volume_size": 5, "private_key": "/keys/id", "droplet_id": 42},
+			wantMigrated: true,
+			wantBackup:   map[string]interface{}{"projectname": "myproject", "volumesize": 5, "privatekeyfilename": "/keys/id", "dropletid": 42},
+		},
+		{
+			name:         "explicit version 1",
+			content:      "version: 1\nprojectname: myproject\n",
+			want:         map[string]interface{}{"version": 2, "project_name": "myproject"},
+			wantMigrated: true,
+			wantBackup:   map[string]interface{}{"version": 1, "projectname": "myproject"},
+		},
+		{
+			name:         "version 1 keys already in snake case",
+			content:      "project_name: myproject\nemail: me@example.com\n",
+			want:         map[string]interface{}{"version": 2, "project_name": "myproject", "email": "me@example.com"},
+			wantMigrated: true,
+			wantBackup:   map[string]interface{}{"project_name": "myproject", "email": "me@example.com"},
+		},
+		{
+			name:         "API token left out of the backup",
+			content:      "projectname: myproject\ndigitaloceanapikey: token\n",
+			want:         map[string]interface{}{"version": 2, "project_name": "myproject", "digitalocean_api_key": "token"},
+			wantMigrated: true,
+			wantBackup:   map[string]interface{}{"projectname": "myproject"},
+		},
+		{
+			name:    "both old and new keys",
+			content: "projectname: one\nproject_name: two\n",
+			wantErr: true,
+		},
+		{
+			name:    "newer version",
+			content: "version: 3\nproject_name: myproject\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			content: "version: two\nproject_name: myproject\n",
+			wantErr: true,
+		},
+		{
+			name:    "version 0",
+			content: "version: 0\nproject_name: myproject\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), configFileName)
+			if err := ioutil.WriteFile(filename, []byte(test.content), os.FileMode(0600)); err != nil {
+				t.Fatal(err)
+			}
+
+			data, migrated, err := migrate(filename, []byte(test.content))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != test.wantMigrated {
+				t.Errorf("got migrated %v, want %v", migrated, test.wantMigrated)
+			}
+
+			got := map[string]interface{}{}
+			if err := yaml.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+
+			backupFilename := filename + ".v1.bak"
+			backupData, err := ioutil.ReadFile(backupFilename)
+			if test.wantBackup == nil {
+				if err == nil {
+					t.Errorf("a backup was written:\n%s", backupData)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			backup := map[string]interface{}{}
+			if err := yaml.Unmarshal(backupData, &backup); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(backup, test.wantBackup) {
+				t.Errorf("got backup %v, want %v", backup, test.wantBackup)
+			}
+		})
+	}
+}
+
+func TestLoadMigrated(t *testing.T) {
+	filename := writeProject(t, "myproject", "projectname: myproject\nvolumesize: 5\nbaredomainname: example.com\n")
+
+	cfg, err := Load("myproject")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != CurrentVersion || cfg.VolumeSize != 5 || cfg.BareDomainName != "example.com" {
+		t.Errorf("got version %v, volume size %v and domain %v", cfg.Version, cfg.VolumeSize, cfg.BareDomainName)
+	}
+
+	// Saved in full, so that loading it again has nothing to migrate
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, migrated, err := migrate(filename, data); err != nil || migrated {
+		t.Errorf("got migrated %v, %v loading the saved file", migrated, err)
+	}
+}
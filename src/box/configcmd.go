@@ -0,0 +1,188 @@
+package main
+
+import (
+	"box/config"
+	"box/manifest"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moby/term"
+)
+
+type ConfigCmd struct {
+	Render ConfigRenderCmd `cmd:"" help:"Print the effective manifest merged from all layers, noting where each value came from"`
+	Get    ConfigGetCmd    `cmd:"" help:"Print the project's configuration, or the value of a single key"`
+	Set    ConfigSetCmd    `cmd:"" help:"Change a value of the project's configuration"`
+	Edit   ConfigEditCmd   `cmd:"" help:"Edit the project's configuration file with $EDITOR, validating it before it's saved"`
+}
+
+type ConfigGetCmd struct {
+	Key     string `arg:"" optional:"" help:"Configuration key, eg: region"`
+	Project string `help:"Project name, defaults to the project in the current directory"`
+}
+
+type ConfigSetCmd struct {
+	Key     string `arg:"" help:"Configuration key, eg: region"`
+	Value   string `arg:"" help:"New value, or an empty string to inherit the value of the project's context"`
+	Project string `help:"Project name, defaults to the project in the current directory"`
+}
+
+type ConfigEditCmd struct {
+	Project string `help:"Project name, defaults to the project in the current directory"`
+}
+
+type ConfigRenderCmd struct {
+	Env string `default:"dev" enum:"dev,prod" help:"Runtime environment whose override is layered over the base manifest"`
+}
+
+func (cmd *ConfigRenderCmd) Run() error {
+	dirName, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	rendered, err := manifest.Render(manifest.LayerFilenames(dirName, cmd.Env)...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(rendered))
+	return nil
+}
+
+// loadConfig loads the configuration of the named project, or of the project in the current directory
+func loadConfig(projectName string) (*config.Config, error) {
+	if projectName == "" {
+		mfst, err := loadManifest(manifest.EnvDev)
+		if err != nil {
+			return nil, err
+		}
+		projectName = mfst.Project
+	}
+
+	return config.Load(projectName)
+}
+
+func (cmd *ConfigGetCmd) Run() error {
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Key != "" {
+		value, _, err := cfg.Get(cmd.Key)
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, key := range config.Keys() {
+		value, inherited, _ := cfg.Get(key)
+		source := ""
+		if inherited {
+			source = fmt.Sprintf("(from context %v)", cfg.Context)
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", key, value, source)
+	}
+	writer.Flush()
+
+	return nil
+}
+
+func (cmd *ConfigSetCmd) Run() error {
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Set(cmd.Key, cmd.Value); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saving configuration of project %v...", cfg.ProjectName)
+	if err := cfg.Save(); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+func (cmd *ConfigEditCmd) Run() error {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return fmt.Errorf("box config edit requires a terminal, use box config set instead")
+	}
+
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+	data, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Edited alongside the configuration file, which is only replaced once the edit is valid
+	projectDir, err := cfg.Dir()
+	if err != nil {
+		return err
+	}
+	editFilename := filepath.Join(projectDir, "config.edit.yml")
+	if err := ioutil.WriteFile(editFilename, data, os.FileMode(0600)); err != nil {
+		return err
+	}
+	defer os.Remove(editFilename)
+
+	for {
+		editCmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("%v \"$1\"", editor), "editor", editFilename)
+		editCmd.Stdin = os.Stdin
+		editCmd.Stdout = os.Stdout
+		editCmd.Stderr = os.Stderr
+		if err := editCmd.Run(); err != nil {
+			return fmt.Errorf("Editor %v failed: %w", editor, err)
+		}
+
+		edited, err := ioutil.ReadFile(editFilename)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, data) {
+			fmt.Println("No changes made")
+			return nil
+		}
+
+		updated, err := cfg.Update(edited)
+		if err == nil {
+			fmt.Printf("Saving configuration of project %v...", cfg.ProjectName)
+			if err := updated.Save(); err != nil {
+				return err
+			}
+			fmt.Println("Done")
+			return nil
+		}
+
+		fmt.Printf("\n%v\n\nEdit again? [Y/n] ", err)
+		answer := ""
+		fmt.Scanln(&answer)
+		if strings.HasPrefix(strings.ToLower(answer), "n") {
+			return fmt.Errorf("Changes discarded")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"box/config"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+type ContextCmd struct {
+	Add ContextAddCmd `cmd:"" help:"Add a context, or change the values of an existing one"`
+	Use ContextUseCmd `cmd:"" help:"Choose the context new projects are created against"`
+	Ls  ContextLsCmd  `cmd:"" help:"List the contexts"`
+}
+
+type ContextAddCmd struct {
+	Name        string `arg:"" help:"Context name"`
+	Credential  string `help:"Name of the credential holding the account's API token, as stored by box auth login --name.  Defaults to the context name"`
+	Region      string `help:"Default deployment region"`
+	DropletSlug string `help:"Default droplet slug"`
+	Email       string `help:"Default TLS certificate registration email"`
+	PrivateKey  string `help:"Default private key full path, whose public key is alongside it with a .pub extension"`
+	Use         bool   `help:"Make it the current context"`
+}
+
+type ContextUseCmd struct {
+	Name string `arg:"" help:"Context name"`
+}
+
+type ContextLsCmd struct {
+}
+
+func (cmd *ContextAddCmd) Run() error {
+	if !config.ProjectNameRe.Match([]byte(cmd.Name)) {
+		return fmt.Errorf("Context names follow the same rules as project names: lowercase alpha, numbers, or hyphens, between 3 and 20 characters long")
+	}
+
+	contexts, err := config.LoadContexts()
+	if err != nil {
+		return err
+	}
+
+	context, exists := contexts.Contexts[cmd.Name]
+	if !exists {
+		context = &config.Context{Name: cmd.Name, Credential: cmd.Name}
+	}
+	set := func(value *string, given string) {
+		if given != "" {
+			*value = given
+		}
+	}
+	set(&context.Credential, cmd.Credential)
+	set(&context.Region, cmd.Region)
+	set(&context.DropletSlug, cmd.DropletSlug)
+	set(&context.Email, cmd.Email)
+	if cmd.PrivateKey != "" && cmd.PrivateKey != context.PrivateKeyFilename {
+		context.PrivateKeyFilename = cmd.PrivateKey
+		// The new key is registered with the account by the next project created against the context
+		context.PublicKeyID = 0
+	}
+	if err := context.Validate(); err != nil {
+		return err
+	}
+
+	contexts.Contexts[cmd.Name] = context
+	if cmd.Use {
+		contexts.Current = cmd.Name
+	}
+
+	if exists {
+		fmt.Printf("Updating context %v...", cmd.Name)
+	} else {
+		fmt.Printf("Adding context %v...", cmd.Name)
+	}
+	if err := contexts.Save(); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	creds, err := config.Credentials()
+	if err != nil {
+		return err
+	}
+	if _, _, err := creds.Get(context.Credential); err != nil {
+		fmt.Printf("Store the account's API token with: box auth login --name %v\n", context.Credential)
+	}
+
+	return nil
+}
+
+func (cmd *ContextUseCmd) Run() error {
+	contexts, err := config.LoadContexts()
+	if err != nil {
+		return err
+	}
+	if _, err := contexts.Get(cmd.Name); err != nil {
+		return err
+	}
+
+	contexts.Current = cmd.Name
+	if err := contexts.Save(); err != nil {
+		return err
+	}
+	fmt.Printf("New projects are created against context %v\n", cmd.Name)
+
+	return nil
+}
+
+func (cmd *ContextLsCmd) Run() error {
+	contexts, err := config.LoadContexts()
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "CURRENT\tNAME\tCREDENTIAL\tREGION\tDROPLET SLUG\tEMAIL\tPRIVATE KEY")
+	for _, name := range contexts.Names() {
+		context := contexts.Contexts[name]
+		current := ""
+		if name == contexts.Current {
+			current = "*"
+		}
+		fmt.Fprintf(
+			writer,
+			"%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			current,
+			name,
+			context.Credential,
+			context.Region,
+			context.DropletSlug,
+			context.Email,
+			context.PrivateKeyFilename,
+		)
+	}
+	writer.Flush()
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Environment variable holding a token, which takes precedence over any stored one
+const EnvToken = "DIGITALOCEAN_TOKEN"
+
+// Name of the credential shared by projects which don't name their own
+const DefaultName = "default"
+
+const settingsFileName = "credentials.yml"
+
+// Sources a token can be kept in
+const (
+	SourceEnv     = "env"
+	SourceKeyring = "keyring"
+	SourceHelper  = "helper"
+	SourceFile    = "file"
+)
+
+// ErrNotFound is returned when no token is stored under a name
+var ErrNotFound = errors.New("credential not found")
+
+// NameRe matches valid credential names, which are passed to helpers and other commands as arguments
+var NameRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9._@-]{0,63}$")
+
+// ValidateName returns an error if the credential name is invalid
+func ValidateName(name string) error {
+	if !NameRe.MatchString(name) {
+		return fmt.Errorf("Credential name \"%v\" must only contain letters, numbers, or any of ._@- and be at most 64 characters long", name)
+	}
+
+	return nil
+}
+
+// backend keeps tokens by credential name
+type backend interface {
+	get(name string) (string, error)
+	store(name, token string) error
+	erase(name string) error
+}
+
+type settings struct {
+	Source string `yaml:"source,omitempty"`
+	Helper string `yaml:"helper,omitempty"`
+}
+
+// Store holds the DigitalOcean API tokens shared by all projects, in whichever source was chosen
+type Store struct {
+	dir      string
+	settings settings
+	backend  backend
+}
+
+// Load loads the credential settings kept in the configuration directory
+func Load(configDir string) (*Store, error) {
+	store := &Store{dir: configDir}
+
+	data, err := ioutil.ReadFile(filepath.Join(configDir, settingsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &store.settings); err != nil {
+		return nil, fmt.Errorf("Unable to process credential settings: %w", err)
+	}
+
+	return store, nil
+}
+
+// Source returns the source tokens are stored in, or an empty string if none was chosen yet
+func (s *Store) Source() string {
+	return s.settings.Source
+}
+
+// Helper returns the credential helper command, when tokens are stored by one
+func (s *Store) Helper() string {
+	return s.settings.Helper
+}
+
+// Configure chooses the source tokens are stored in.  Tokens already stored elsewhere are not moved.
+func (s *Store) Configure(source, helper string) error {
+	switch source {
+	case SourceHelper:
+		if helper == "" {
+			return fmt.Errorf("A credential helper command is required to store tokens with a helper")
+		}
+	case SourceKeyring, SourceFile:
+		helper = ""
+	default:
+		return fmt.Errorf("Unknown credential source \"%v\", one of: keyring, helper, file", source)
+	}
+
+	s.settings = settings{Source: source, Helper: helper}
+	s.backend = nil
+	if err := os.MkdirAll(s.dir, os.FileMode(0700)); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(&s.settings)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.dir, settingsFileName), data, os.FileMode(0600))
+}
+
+// defaultSource returns the source used when none was chosen, the OS keyring when it's available
+func defaultSource() string {
+	if _, err := exec.LookPath(secretToolCommand); err == nil {
+		return SourceKeyring
+	}
+
+	return SourceFile
+}
+
+func (s *Store) getBackend() (backend, error) {
+	if s.backend != nil {
+		return s.backend, nil
+	}
+
+	switch s.settings.Source {
+	case SourceKeyring:
+		s.backend = &keyring{}
+	case SourceHelper:
+		s.backend = &helper{command: s.settings.Helper}
+	case SourceFile:
+		s.backend = &encryptedFile{filename: filepath.Join(s.dir, encryptedFileName)}
+	default:
+		return nil, ErrNotFound
+	}
+
+	return s.backend, nil
+}
+
+// stored returns the token stored under the credential name, ignoring the environment variable
+func (s *Store) stored(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	be, err := s.getBackend()
+	if err != nil {
+		return "", err
+	}
+
+	return be.get(name)
+}
+
+// Get returns the token of the credential along with the source it was read from.  The environment
+// variable takes precedence over any stored token.
+func (s *Store) Get(name string) (string, string, error) {
+	if token := os.Getenv(EnvToken); token != "" {
+		return token, SourceEnv, nil
+	}
+
+	token, err := s.stored(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	return token, s.settings.Source, nil
+}
+
+// Set stores the token under the credential name, choosing the default source if none was chosen yet
+func (s *Store) Set(name, token string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if s.settings.Source == "" {
+		if err := s.Configure(defaultSource(), ""); err != nil {
+			return err
+		}
+	}
+
+	be, err := s.getBackend()
+	if err != nil {
+		return err
+	}
+
+	return be.store(name, token)
+}
+
+// Erase removes the token stored under the credential name
+func (s *Store) Erase(name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	be, err := s.getBackend()
+	if err != nil {
+		return err
+	}
+
+	return be.erase(name)
+}
+
+// Adopt stores the token of a project under the credential name, returning the name it's kept under.
+// Projects share a credential unless their token differs from it, in which case the project's name is used.
+func (s *Store) Adopt(name, projectName, token string) (string, error) {
+	existing, err := s.stored(name)
+	switch {
+	case err == nil && existing == token:
+		return name, nil
+	case err == nil:
+		name = projectName
+	case err != ErrNotFound:
+		return "", err
+	}
+
+	if err := s.Set(name, token); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
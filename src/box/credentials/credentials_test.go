@@ -0,0 +1,272 @@
+package credentials
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Keeps tokens as files named after the credential, in the directory FAKE_KEYRING_DIR.  With
+// FAKE_KEYRING_FAIL set, every command fails without any output.
+const fakeSecretTool = `#!/bin/sh
+[ -n "$FAKE_KEYRING_FAIL" ] && exit 1
+eval name=\${$#}
+item="$FAKE_KEYRING_DIR/$name"
+case "$1" in
+lookup) [ -f "$item" ] || exit 1; cat "$item" ;;
+store) cat > "$item" ;;
+clear) rm -f "$item" ;;
+esac
+`
+
+// Keeps tokens like the fake secret-tool, following the credential helper protocol
+const fakeHelper = `#!/bin/sh
+[ -n "$FAKE_KEYRING_FAIL" ] && exit 1
+item="$FAKE_KEYRING_DIR/$2"
+case "$1" in
+get) [ -f "$item" ] && cat "$item" ;;
+store) cat > "$item" ;;
+erase) rm -f "$item" ;;
+esac
+`
+
+func setenv(t *testing.T, key, value string) {
+	previous, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// fakeCommands puts the fake secret-tool on the PATH, returning the command which runs the fake helper
+func fakeCommands(t *testing.T) string {
+	binDir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(binDir, secretToolCommand), []byte(fakeSecretTool), os.FileMode(0755)); err != nil {
+		t.Fatal(err)
+	}
+	helperFilename := filepath.Join(binDir, "box-helper")
+	if err := ioutil.WriteFile(helperFilename, []byte(fakeHelper), os.FileMode(0755)); err != nil {
+		t.Fatal(err)
+	}
+
+	setenv(t, "PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	setenv(t, "FAKE_KEYRING_DIR", t.TempDir())
+	setenv(t, "FAKE_KEYRING_FAIL", "")
+	setenv(t, EnvToken, "")
+	setenv(t, EnvPassphrase, "correct horse")
+
+	return helperFilename
+}
+
+func TestStoreSources(t *testing.T) {
+	helperCommand := fakeCommands(t)
+
+	tests := []struct {
+		name   string
+		source string
+		helper string
+	}{
+		{name: "keyring", source: SourceKeyring},
+		{name: "helper", source: SourceHelper, helper: helperCommand},
+		{name: "encrypted file", source: SourceFile},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, "FAKE_KEYRING_DIR", t.TempDir())
+			store, err := Load(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Configure(test.source, test.helper); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := store.Get("work"); err != ErrNotFound {
+				t.Fatalf("expected ErrNotFound before storing, got %v", err)
+			}
+			if err := store.Set("work", "token-1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Set("work", "token-2"); err != nil {
+				t.Fatal(err)
+			}
+			token, source, err := store.Get("work")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "token-2" || source != test.source {
+				t.Errorf("got %v from %v, want token-2 from %v", token, source, test.source)
+			}
+
+			// Loaded again, so that the encrypted file is read back from disk
+			reloaded, err := Load(store.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token, _, err := reloaded.Get("work"); err != nil || token != "token-2" {
+				t.Errorf("got %v, %v after reloading, want token-2", token, err)
+			}
+
+			if err := reloaded.Erase("work"); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := reloaded.Get("work"); err != ErrNotFound {
+				t.Errorf("expected ErrNotFound after erasing, got %v", err)
+			}
+			if err := reloaded.Erase("work"); err != nil {
+				t.Errorf("erasing a missing credential failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestSilentFailures(t *testing.T) {
+	helperCommand := fakeCommands(t)
+	setenv(t, "FAKE_KEYRING_FAIL", "1")
+
+	tests := []struct {
+		name     string
+		backend  backend
+		action   func(be backend) error
+		notFound bool
+	}{
+		{
+			name:     "keyring lookup",
+			backend:  &keyring{},
+			action:   func(be backend) error { _, err := be.get("work"); return err },
+			notFound: true,
+		},
+		{
+			name:    "keyring store",
+			backend: &keyring{},
+			action:  func(be backend) error { return be.store("work", "token") },
+		},
+		{
+			name:    "keyring clear",
+			backend: &keyring{},
+			action:  func(be backend) error { return be.erase("work") },
+		},
+		{
+			name:     "helper get",
+			backend:  &helper{command: helperCommand},
+			action:   func(be backend) error { _, err := be.get("work"); return err },
+			notFound: true,
+		},
+		{
+			name:    "helper store",
+			backend: &helper{command: helperCommand},
+			action:  func(be backend) error { return be.store("work", "token") },
+		},
+		{
+			name:    "helper erase",
+			backend: &helper{command: helperCommand},
+			action:  func(be backend) error { return be.erase("work") },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.action(test.backend)
+			if test.notFound {
+				if err != ErrNotFound {
+					t.Errorf("expected ErrNotFound, got %v", err)
+				}
+				return
+			}
+			if err == nil || err == ErrNotFound {
+				t.Errorf("expected a failure, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedFilePassphrase(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		wantErr    bool
+	}{
+		{name: "same passphrase", passphrase: "correct horse"},
+		{name: "wrong passphrase", passphrase: "battery staple", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), encryptedFileName)
+			setenv(t, EnvPassphrase, "correct horse")
+			if err := (&encryptedFile{filename: filename}).store("work", "token"); err != nil {
+				t.Fatal(err)
+			}
+
+			setenv(t, EnvPassphrase, test.passphrase)
+			token, err := (&encryptedFile{filename: filename}).get("work")
+			if test.wantErr {
+				if err == nil || err == ErrNotFound {
+					t.Fatalf("expected a passphrase error, got %v, %v", token, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != "token" {
+				t.Errorf("got %v, want token", token)
+			}
+		})
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	fakeCommands(t)
+
+	tests := []struct {
+		name     string
+		existing string
+		token    string
+		want     string
+	}{
+		{name: "no stored token", token: "token-1", want: DefaultName},
+		{name: "same token", existing: "token-1", token: "token-1", want: DefaultName},
+		{name: "different token", existing: "token-1", token: "token-2", want: "myproject"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, "FAKE_KEYRING_DIR", t.TempDir())
+			store, err := Load(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Configure(SourceKeyring, ""); err != nil {
+				t.Fatal(err)
+			}
+			if test.existing != "" {
+				if err := store.Set(DefaultName, test.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			name, err := store.Adopt(DefaultName, "myproject", test.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != test.want {
+				t.Fatalf("adopted as %v, want %v", name, test.want)
+			}
+			if token, _, err := store.Get(name); err != nil || token != test.token {
+				t.Errorf("got %v, %v under %v, want %v", token, err, name, test.token)
+			}
+			if test.existing != "" {
+				if token, _, _ := store.Get(DefaultName); token != test.existing {
+					t.Errorf("the existing token was replaced with %v", token)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package credentials
+
+import (
+	"box/crypt"
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/moby/term"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const encryptedFileName = "credentials.enc"
+
+// Environment variable holding the passphrase of the encrypted file, which is otherwise prompted for
+const EnvPassphrase = "BOX_PASSPHRASE"
+
+// Sealed with the key to tell a wrong passphrase apart from a corrupt token
+const passphraseCheck = "box.do"
+
+type encryptedFileData struct {
+	Salt   string            `yaml:"salt"`
+	Check  string            `yaml:"check"`
+	Tokens map[string]string `yaml:"tokens"`
+}
+
+// encryptedFile keeps tokens in a file, encrypted with a key derived from a passphrase
+type encryptedFile struct {
+	filename string
+	data     *encryptedFileData
+	key      *[32]byte
+}
+
+// readPassphrase reads the passphrase from the environment, or prompts for it without echo
+func readPassphrase(prompt string) (string, error) {
+	if passphrase := os.Getenv(EnvPassphrase); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("A passphrase is required to use the encrypted credential file, set %v", EnvPassphrase)
+	}
+	state, err := term.SaveState(fd)
+	if err != nil {
+		return "", err
+	}
+	if err := term.DisableEcho(fd, state); err != nil {
+		return "", err
+	}
+	defer term.RestoreTerminal(fd, state)
+
+	fmt.Print(prompt)
+	passphrase, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	passphrase = strings.TrimSuffix(passphrase, "\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("The passphrase must not be empty")
+	}
+
+	return passphrase, nil
+}
+
+func (f *encryptedFile) seal(value string) (string, error) {
+	sealed, err := crypt.Seal([]byte(value), f.key)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (f *encryptedFile) open(encoded string) (string, bool) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+	value, ok := crypt.Open(sealed, f.key)
+
+	return string(value), ok
+}
+
+// unlock loads the file and derives its key, creating both when the file doesn't exist yet
+func (f *encryptedFile) unlock() error {
+	if f.key != nil {
+		return nil
+	}
+
+	data := encryptedFileData{}
+	content, err := ioutil.ReadFile(f.filename)
+	switch {
+	case os.IsNotExist(err):
+		passphrase, err := readPassphrase("New passphrase for the credential file: ")
+		if err != nil {
+			return err
+		}
+		salt, err := crypt.NewSalt()
+		if err != nil {
+			return err
+		}
+		if f.key, err = crypt.DeriveKey(passphrase, salt); err != nil {
+			return err
+		}
+		data.Salt = base64.StdEncoding.EncodeToString(salt)
+		if data.Check, err = f.seal(passphraseCheck); err != nil {
+			return err
+		}
+		data.Tokens = map[string]string{}
+	case err != nil:
+		return err
+	default:
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return fmt.Errorf("Unable to process credential file %v: %w", f.filename, err)
+		}
+		salt, err := base64.StdEncoding.DecodeString(data.Salt)
+		if err != nil {
+			return fmt.Errorf("Credential file %v is corrupt: %w", f.filename, err)
+		}
+		passphrase, err := readPassphrase("Passphrase for the credential file: ")
+		if err != nil {
+			return err
+		}
+		if f.key, err = crypt.DeriveKey(passphrase, salt); err != nil {
+			return err
+		}
+		if check, ok := f.open(data.Check); !ok || check != passphraseCheck {
+			f.key = nil
+			return fmt.Errorf("The passphrase of credential file %v is incorrect", f.filename)
+		}
+		if data.Tokens == nil {
+			data.Tokens = map[string]string{}
+		}
+	}
+	f.data = &data
+
+	return nil
+}
+
+func (f *encryptedFile) save() error {
+	content, err := yaml.Marshal(f.data)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(f.filename, content, os.FileMode(0600))
+}
+
+func (f *encryptedFile) get(name string) (string, error) {
+	if _, err := os.Stat(f.filename); os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err := f.unlock(); err != nil {
+		return "", err
+	}
+
+	encoded, ok := f.data.Tokens[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	token, ok := f.open(encoded)
+	if !ok {
+		return "", fmt.Errorf("Credential %v in %v is corrupt", name, f.filename)
+	}
+
+	return token, nil
+}
+
+func (f *encryptedFile) store(name, token string) error {
+	if err := f.unlock(); err != nil {
+		return err
+	}
+
+	sealed, err := f.seal(token)
+	if err != nil {
+		return err
+	}
+	f.data.Tokens[name] = sealed
+
+	return f.save()
+}
+
+func (f *encryptedFile) erase(name string) error {
+	if _, err := os.Stat(f.filename); os.IsNotExist(err) {
+		return nil
+	}
+	if err := f.unlock(); err != nil {
+		return err
+	}
+	delete(f.data.Tokens, name)
+
+	return f.save()
+}
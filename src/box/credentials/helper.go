@@ -0,0 +1,58 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// helper keeps tokens with an external command, which is run through the shell with an action (get,
+// store or erase) and the credential name as arguments.  The token is read from standard input to store it,
+// and written to standard output to get it, where no output means no token is stored.  Failing without any
+// output also does.
+type helper struct {
+	command string
+}
+
+func (h *helper) run(stdin, action, name string) (string, error) {
+	// Passed as positional parameters, so that the shell only interprets the helper command itself
+	cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("%v \"$@\"", h.command), "sh", action, name)
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok && action == "get" && stdout.Len() == 0 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("Credential helper %v failed: %v %v", action, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+func (h *helper) get(name string) (string, error) {
+	token, err := h.run("", "get", name)
+	if err != nil {
+		return "", err
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrNotFound
+	}
+
+	return token, nil
+}
+
+func (h *helper) store(name, token string) error {
+	_, err := h.run(token, "store", name)
+	return err
+}
+
+func (h *helper) erase(name string) error {
+	_, err := h.run("", "erase", name)
+	return err
+}
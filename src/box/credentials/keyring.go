@@ -0,0 +1,64 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// libsecret's command line client, which talks to the OS keyring over the Secret Service D-Bus API
+const secretToolCommand = "secret-tool"
+
+// Attribute identifying box's items in the keyring
+const keyringService = "box.do"
+
+// keyring keeps tokens in the OS keyring
+type keyring struct {
+}
+
+func (k *keyring) run(stdin string, args ...string) (string, error) {
+	cmd := exec.Command(secretToolCommand, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok && stderr.Len() == 0 && args[0] == "lookup" {
+			// Lookups of missing items fail without saying anything
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("Keyring %v failed: %v %v", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+func (k *keyring) get(name string) (string, error) {
+	token, err := k.run("", "lookup", "service", keyringService, "name", name)
+	if err != nil {
+		return "", err
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrNotFound
+	}
+
+	return token, nil
+}
+
+func (k *keyring) store(name, token string) error {
+	label := fmt.Sprintf("box.do DigitalOcean API token (%v)", name)
+	_, err := k.run(token, "store", "--label", label, "service", keyringService, "name", name)
+
+	return err
+}
+
+func (k *keyring) erase(name string) error {
+	// Clearing a missing item succeeds
+	_, err := k.run("", "clear", "service", keyringService, "name", name)
+
+	return err
+}
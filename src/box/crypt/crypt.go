@@ -0,0 +1,55 @@
+package crypt
+
+import (
+	"crypto/rand"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const saltSize = 16
+const nonceSize = 24
+
+// NewSalt returns a random salt, to derive a key with
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// DeriveKey derives the key which seals data from the passphrase and salt
+func DeriveKey(passphrase string, salt []byte) (*[32]byte, error) {
+	derived, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	key := [32]byte{}
+	copy(key[:], derived)
+
+	return &key, nil
+}
+
+// Seal encrypts and authenticates data with the key, preceded by the random nonce it was sealed with
+func Seal(data []byte, key *[32]byte) ([]byte, error) {
+	nonce := [nonceSize]byte{}
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+
+	return secretbox.Seal(nonce[:], data, &nonce, key), nil
+}
+
+// Open decrypts data sealed with the key, returning false if it was sealed with another key or is corrupt
+func Open(sealed []byte, key *[32]byte) ([]byte, bool) {
+	if len(sealed) < nonceSize {
+		return nil, false
+	}
+	nonce := [nonceSize]byte{}
+	copy(nonce[:], sealed[:nonceSize])
+
+	return secretbox.Open(nil, sealed[nonceSize:], &nonce, key)
+}
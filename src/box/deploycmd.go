@@ -0,0 +1,103 @@
+package main
+
+import (
+	"box/config"
+	"box/manifest"
+	"box/runtime"
+	"box/secrets"
+	"fmt"
+	"net"
+	"time"
+)
+
+type DeployCmd struct {
+	Force          bool          `default:"false" help:"Rebuild and push images even if their inputs are unchanged"`
+	StartupTimeout time.Duration `default:"5m" help:"Time allowed for all services to start and become ready"`
+	Profile        []string      `help:"Also deploy services selected by this profile, may be repeated"`
+}
+
+func (cmd *DeployCmd) Run() error {
+	fmt.Println("Loading run manifest")
+	mfst, err := loadManifest(manifest.EnvProd)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Loading project configuration")
+	cfg, err := config.Load(mfst.Project)
+	if err != nil {
+		return err
+	}
+	if cfg.DropletPublicIP == "" {
+		return fmt.Errorf("Project %v has no droplet yet, create it with box mkremote first", cfg.ProjectName)
+	}
+	secretStore, err := secrets.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	// Building and pushing don't touch the project's local containers, so they're allowed while another project
+	// is running
+	rt, err := runtime.NewReadOnly(mfst)
+	if err != nil {
+		return err
+	}
+	rt.Config = cfg
+	rt.Production = true
+	rt.Profiles = cmd.Profile
+
+	_, err = rt.Build(cmd.Force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print("Connecting to the droplet...")
+	conn, err := connectDroplet(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	fmt.Println("Done")
+
+	remote, err := runtime.NewRemote(mfst, cfg, conn)
+	if err != nil {
+		return err
+	}
+	remote.StartupTimeout = cmd.StartupTimeout
+	remote.Profiles = cmd.Profile
+
+	// The droplet's registry is only reachable through a tunnel, which an engine outside this machine can't use
+	local, err := rt.EngineIsLocal()
+	if err != nil {
+		return err
+	}
+	registryHost := ""
+	if local {
+		listener, err := conn.Forward(fmt.Sprintf("127.0.0.1:%v", runtime.RegistryPort))
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+		// The engine trusts a plain HTTP registry on the loopback address
+		registryHost = fmt.Sprintf("127.0.0.1:%v", listener.Addr().(*net.TCPAddr).Port)
+	} else {
+		fmt.Println("The docker engine doesn't run on this machine, so changed images are copied to the droplet whole")
+	}
+	_, err = rt.Push(remote, registryHost, cmd.Force)
+	if err != nil {
+		return err
+	}
+
+	err = pushSecrets(conn, secretStore, cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	err = remote.Start()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Deploy complete!")
+	return nil
+}
@@ -5,22 +5,17 @@ import (
 	"box/manifest"
 	"box/runtime"
 	"fmt"
-	"os"
-	"path/filepath"
+	"time"
 )
 
 type DevCmd struct {
+	StartupTimeout time.Duration `default:"5m" help:"Time allowed for all services to start and become ready"`
+	Profile        []string      `help:"Also start services selected by this profile, may be repeated"`
 }
 
 func (cmd *DevCmd) Run() error {
-	dirName, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-
-	manifestFilename := filepath.Join(dirName, "box.yml")
 	fmt.Println("Loading run manifest")
-	mfst, err := manifest.NewManifest(manifestFilename)
+	mfst, err := loadManifest(manifest.EnvDev)
 	if err != nil {
 		return err
 	}
@@ -37,6 +32,8 @@ func (cmd *DevCmd) Run() error {
 		return err
 	}
 
+	rt.StartupTimeout = cmd.StartupTimeout
+	rt.Profiles = cmd.Profile
 	err = rt.Start()
 
 	return err
@@ -0,0 +1,239 @@
+package export
+
+import (
+	"box/manifest"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// App Platform app spec, only the parts a manifest can be translated into
+type appSpec struct {
+	Name     string         `yaml:"name"`
+	Services []appComponent `yaml:"services,omitempty"`
+	Workers  []appComponent `yaml:"workers,omitempty"`
+}
+
+type appComponent struct {
+	Name           string          `yaml:"name"`
+	Image          *appImage       `yaml:"image,omitempty"`
+	DockerfilePath string          `yaml:"dockerfile_path,omitempty"`
+	SourceDir      string          `yaml:"source_dir,omitempty"`
+	RunCommand     string          `yaml:"run_command,omitempty"`
+	HTTPPort       int             `yaml:"http_port,omitempty"`
+	InternalPorts  []int           `yaml:"internal_ports,omitempty"`
+	InstanceCount  int             `yaml:"instance_count,omitempty"`
+	Routes         []appRoute      `yaml:"routes,omitempty"`
+	Envs           []appEnv        `yaml:"envs,omitempty"`
+	HealthCheck    *appHealthCheck `yaml:"health_check,omitempty"`
+}
+
+type appImage struct {
+	RegistryType string `yaml:"registry_type"`
+	Registry     string `yaml:"registry,omitempty"`
+	Repository   string `yaml:"repository"`
+	Tag          string `yaml:"tag,omitempty"`
+}
+
+type appRoute struct {
+	Path               string `yaml:"path"`
+	PreservePathPrefix bool   `yaml:"preserve_path_prefix,omitempty"`
+}
+
+type appEnv struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value,omitempty"`
+	Scope string `yaml:"scope"`
+	Type  string `yaml:"type"`
+}
+
+type appHealthCheck struct {
+	HTTPPath            string `yaml:"http_path,omitempty"`
+	Port                int    `yaml:"port,omitempty"`
+	InitialDelaySeconds int    `yaml:"initial_delay_seconds,omitempty"`
+	PeriodSeconds       int    `yaml:"period_seconds,omitempty"`
+	TimeoutSeconds      int    `yaml:"timeout_seconds,omitempty"`
+	FailureThreshold    int    `yaml:"failure_threshold,omitempty"`
+}
+
+// Registries App Platform pulls images from, by registry host
+var appRegistryTypes = map[string]string{
+	"":                          "DOCKER_HUB",
+	"docker.io":                 "DOCKER_HUB",
+	"registry.digitalocean.com": "DOCR",
+	"ghcr.io":                   "GHCR",
+}
+
+// appPlatformImage splits an image reference into an App Platform image source, or returns nil if App
+// Platform can't pull from its registry
+func appPlatformImage(ref string) *appImage {
+	tag := ""
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, tag = ref[:i], ref[i+1:]
+	}
+
+	parts := strings.Split(ref, "/")
+	host := ""
+	if len(parts) > 1 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		host, parts = parts[0], parts[1:]
+	}
+
+	registryType, ok := appRegistryTypes[host]
+	if !ok {
+		return nil
+	}
+
+	image := &appImage{RegistryType: registryType, Tag: tag}
+	switch {
+	case registryType == "DOCR":
+		// The app's own registry is always used, so only the repository is given
+		image.Repository = strings.Join(parts[1:], "/")
+	case len(parts) == 1:
+		image.Registry, image.Repository = "library", parts[0]
+	default:
+		image.Registry, image.Repository = parts[0], strings.Join(parts[1:], "/")
+	}
+
+	return image
+}
+
+// appPlatform exports the project as an App Platform app spec
+func (ex *exporter) appPlatform() ([]byte, error) {
+	spec := appSpec{Name: ex.mfst.Project}
+	secrets := []string{}
+
+	for _, svc := range ex.services {
+		lose := func(format string, args ...interface{}) {
+			ex.lose(svc.Name, format, args...)
+		}
+		component := appComponent{Name: svc.Name}
+
+		switch {
+		case isLocalImage(svc) && ex.opts.Registry == "":
+			// Without a registry, App Platform builds the image from the repository
+			component.SourceDir = path.Clean(svc.Build.Context)
+			component.DockerfilePath = path.Join(svc.Build.Context, svc.Build.Dockerfile)
+			lose("built from source, add the git repository holding %v to the spec", svc.Build.Context)
+			for _, key := range sortedKeys(svc.Build.Args) {
+				component.Envs = append(component.Envs, appEnv{Key: key, Value: svc.Build.Args[key], Scope: "BUILD_TIME", Type: "GENERAL"})
+			}
+			if svc.Build.Target != "" || len(svc.Build.Secrets) > 0 || svc.Build.Platform != "" {
+				lose("build target, secrets and platform are not supported")
+			}
+		default:
+			image := ex.image(svc)
+			if component.Image = appPlatformImage(image); component.Image == nil {
+				lose("image %v can't be pulled by App Platform, only Docker Hub, DOCR and GHCR are supported", image)
+			}
+		}
+		if databaseImageRe.MatchString(svc.Image) {
+			lose("consider a managed database in place of %v", svc.Image)
+		}
+
+		if len(svc.Entrypoint) > 0 {
+			lose("entrypoint is not supported")
+		}
+		if len(svc.Command) > 0 {
+			component.RunCommand = svc.Command.String()
+		}
+
+		for _, key := range sortedKeys(svc.Environment) {
+			value := svc.Environment[key]
+			if secretRef(value) != "" {
+				component.Envs = append(component.Envs, appEnv{Key: key, Scope: "RUN_TIME", Type: "SECRET"})
+				secrets = append(secrets, fmt.Sprintf("%v.%v", svc.Name, key))
+				continue
+			}
+			if strings.Contains(value, "${") {
+				lose("environment %v refers to other variables, which are not substituted", key)
+			}
+			component.Envs = append(component.Envs, appEnv{Key: key, Value: value, Scope: "RUN_TIME", Type: "GENERAL"})
+		}
+
+		if svc.Routing.Path.Pattern != "" {
+			component.HTTPPort = svc.Routing.Port
+			route := appRoute{Path: svc.Routing.Path.Pattern, PreservePathPrefix: svc.Routing.Path.Pattern != "/"}
+			switch svc.Routing.Path.Type {
+			case manifest.PathTypeExact:
+				lose("routing %v matches exactly, App Platform routes match by prefix", route.Path)
+				component.Routes = append(component.Routes, route)
+			case manifest.PathTypeRegex:
+				lose("routing regex %v is not supported, add a route by prefix instead", route.Path)
+			default:
+				component.Routes = append(component.Routes, route)
+			}
+		}
+
+		for _, port := range containerPorts(svc) {
+			if port.HostPort != 0 {
+				lose("port %v/%v is published on the host, App Platform only accepts HTTP requests from outside the app", port.Port, port.Protocol)
+			}
+			if port.Protocol != "tcp" {
+				lose("%v port %v is not supported", port.Protocol, port.Port)
+				continue
+			}
+			if port.Port != component.HTTPPort {
+				component.InternalPorts = append(component.InternalPorts, port.Port)
+			}
+		}
+
+		if replicas := svc.GetReplicas(); replicas > 1 {
+			component.InstanceCount = replicas
+		}
+		if svc.Memory != "" || svc.CPUs != 0 {
+			lose("memory and cpu limits are not supported, choose an instance_size_slug instead")
+		}
+
+		if hc := svc.HealthCheck; hc != nil {
+			check := &appHealthCheck{
+				InitialDelaySeconds: seconds(hc.StartPeriod),
+				PeriodSeconds:       seconds(hc.Interval),
+				TimeoutSeconds:      seconds(hc.Timeout),
+				FailureThreshold:    hc.Retries,
+			}
+			switch {
+			case hc.HTTP != nil:
+				check.HTTPPath, check.Port = hc.HTTP.Path, hc.HTTP.Port
+				component.HealthCheck = check
+			case hc.TCP != 0:
+				check.Port = hc.TCP
+				component.HealthCheck = check
+			default:
+				lose("healthcheck commands are not supported, only http and tcp checks are")
+			}
+		}
+
+		if len(svc.Volumes) > 0 || len(svc.Tmpfs) > 0 {
+			lose("volumes are not supported, the filesystem is lost whenever the component is redeployed")
+		}
+		if len(svc.DependsOn) > 0 {
+			lose("depends_on is not supported, components start in any order")
+		}
+		if svc.User != "" || svc.WorkingDir != "" || svc.StopGracePeriod != "" {
+			lose("user, working_dir and stop_grace_period are not supported")
+		}
+		if svc.Restart == manifest.RestartNo || strings.HasPrefix(svc.Restart, manifest.RestartOnFailure) {
+			lose("restart %v is not supported, components are always restarted", svc.Restart)
+		}
+
+		// Components which other components can't reach run as workers
+		if component.HTTPPort == 0 && len(component.InternalPorts) == 0 {
+			lose("exported as a worker since it declares no ports, make it a service with internal_ports if other components connect to it")
+			spec.Workers = append(spec.Workers, component)
+			continue
+		}
+		spec.Services = append(spec.Services, component)
+	}
+
+	if len(secrets) > 0 {
+		ex.lose("", "secret values must be set in App Platform for %v", strings.Join(secrets, ", "))
+	}
+	if len(ex.mfst.Volumes) > 0 {
+		ex.lose("", "volumes are not supported, move their data to a managed database or Spaces")
+	}
+	if len(ex.mfst.StaticRoutes.Paths) > 0 {
+		ex.lose("", "static_routes are not exported, serve %v as an App Platform static site", ex.mfst.StaticRoutes.Webroot)
+	}
+
+	return marshal(spec)
+}
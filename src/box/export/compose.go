@@ -0,0 +1,247 @@
+package export
+
+import (
+	"box/manifest"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// Compose file, only the parts a manifest can be translated into
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]composeService `yaml:"services"`
+	Volumes  map[string]struct{}       `yaml:"volumes,omitempty"`
+	Secrets  map[string]composeSecret  `yaml:"secrets,omitempty"`
+}
+
+type composeService struct {
+	Image           string                         `yaml:"image,omitempty"`
+	Build           *composeBuild                  `yaml:"build,omitempty"`
+	Entrypoint      []string                       `yaml:"entrypoint,omitempty"`
+	Command         []string                       `yaml:"command,omitempty"`
+	Environment     map[string]string              `yaml:"environment,omitempty"`
+	Ports           []string                       `yaml:"ports,omitempty"`
+	Volumes         []string                       `yaml:"volumes,omitempty"`
+	Tmpfs           []string                       `yaml:"tmpfs,omitempty"`
+	DependsOn       map[string]manifest.Dependency `yaml:"depends_on,omitempty"`
+	Healthcheck     *composeHealthcheck            `yaml:"healthcheck,omitempty"`
+	Labels          map[string]string              `yaml:"labels,omitempty"`
+	Networks        map[string]composeNetwork      `yaml:"networks,omitempty"`
+	User            string                         `yaml:"user,omitempty"`
+	WorkingDir      string                         `yaml:"working_dir,omitempty"`
+	StopGracePeriod string                         `yaml:"stop_grace_period,omitempty"`
+	Restart         string                         `yaml:"restart,omitempty"`
+	MemLimit        string                         `yaml:"mem_limit,omitempty"`
+	CPUs            float64                        `yaml:"cpus,omitempty"`
+	Deploy          *composeDeploy                 `yaml:"deploy,omitempty"`
+}
+
+type composeBuild struct {
+	Context    string            `yaml:"context"`
+	Dockerfile string            `yaml:"dockerfile,omitempty"`
+	Args       map[string]string `yaml:"args,omitempty"`
+	Target     string            `yaml:"target,omitempty"`
+	CacheFrom  []string          `yaml:"cache_from,omitempty"`
+	Platforms  []string          `yaml:"platforms,omitempty"`
+	Secrets    []string          `yaml:"secrets,omitempty"`
+}
+
+type composeSecret struct {
+	File        string `yaml:"file,omitempty"`
+	Environment string `yaml:"environment,omitempty"`
+}
+
+type composeHealthcheck struct {
+	Test        []string `yaml:"test"`
+	Interval    string   `yaml:"interval,omitempty"`
+	Timeout     string   `yaml:"timeout,omitempty"`
+	Retries     int      `yaml:"retries,omitempty"`
+	StartPeriod string   `yaml:"start_period,omitempty"`
+}
+
+type composeNetwork struct {
+	Aliases []string `yaml:"aliases"`
+}
+
+type composeDeploy struct {
+	Replicas int `yaml:"replicas"`
+}
+
+// Directory bind mounts under the project's data directory (@/) are placed in
+const composeDataDir = "./data"
+
+// Traefik matchers, by routing path type
+var traefikMatchers = map[string]string{
+	manifest.PathTypePrefix: "PathPrefix",
+	manifest.PathTypeExact:  "Path",
+	manifest.PathTypeRegex:  "PathRegexp",
+}
+
+// composeEscape escapes variables in a value, which compose would otherwise substitute from the shell
+// rather than leave for the container
+func composeEscape(values []string) []string {
+	escaped := []string{}
+	for _, value := range values {
+		escaped = append(escaped, strings.ReplaceAll(value, "$", "$$"))
+	}
+
+	return escaped
+}
+
+// composeEnvironment replaces secret references with the environment variable of the same name, which
+// compose substitutes from the shell
+func composeEnvironment(svc *manifest.Service) map[string]string {
+	if len(svc.Environment) == 0 {
+		return nil
+	}
+
+	environment := map[string]string{}
+	for key, value := range svc.Environment {
+		if name := manifest.SecretRef(value); name != "" {
+			value = fmt.Sprintf("${%v}", name)
+		}
+		environment[key] = value
+	}
+
+	return environment
+}
+
+// composePort binds the port mapping to localhost when it doesn't give a host IP, since compose binds to
+// all interfaces by default
+func composePort(spec string) string {
+	switch strings.Count(strings.SplitN(spec, "/", 2)[0], ":") {
+	case 0:
+		return "127.0.0.1::" + spec
+	case 1:
+		return "127.0.0.1:" + spec
+	}
+
+	return spec
+}
+
+// compose exports the project as a compose file
+func (ex *exporter) compose() ([]byte, error) {
+	file := composeFile{
+		Name:     ex.mfst.Project,
+		Services: map[string]composeService{},
+		Secrets:  map[string]composeSecret{},
+	}
+	routed := false
+
+	if len(ex.mfst.Volumes) > 0 {
+		file.Volumes = map[string]struct{}{}
+	}
+	for name, volume := range ex.mfst.Volumes {
+		file.Volumes[name] = struct{}{}
+		if volume.BlockStorage {
+			ex.lose("", "volume %v is placed on block storage in production, which compose doesn't do", name)
+		}
+	}
+
+	for _, svc := range ex.services {
+		lose := func(format string, args ...interface{}) {
+			ex.lose(svc.Name, format, args...)
+		}
+
+		service := composeService{
+			Entrypoint:      composeEscape(svc.Entrypoint),
+			Command:         composeEscape(svc.Command),
+			Environment:     composeEnvironment(svc),
+			Tmpfs:           svc.Tmpfs,
+			DependsOn:       svc.DependsOn,
+			User:            svc.User,
+			WorkingDir:      svc.WorkingDir,
+			StopGracePeriod: svc.StopGracePeriod,
+			Restart:         svc.Restart,
+			MemLimit:        svc.Memory,
+			CPUs:            svc.CPUs,
+		}
+
+		if isLocalImage(svc) {
+			bi := svc.Build
+			service.Build = &composeBuild{
+				Context:    bi.Context,
+				Dockerfile: bi.Dockerfile,
+				Args:       bi.Args,
+				Target:     bi.Target,
+				CacheFrom:  bi.CacheFrom,
+			}
+			if bi.Platform != "" {
+				service.Build.Platforms = []string{bi.Platform}
+			}
+			for _, secret := range bi.Secrets {
+				file.Secrets[secret.ID] = composeSecret{File: secret.Src, Environment: secret.Env}
+				service.Build.Secrets = append(service.Build.Secrets, secret.ID)
+			}
+			// Compose names the image itself, unless it's to be pushed
+			if ex.opts.Registry != "" {
+				service.Image = ex.image(svc)
+			}
+		} else {
+			service.Image = svc.Image
+		}
+
+		if names := svc.GetSecrets(); len(names) > 0 {
+			lose("secret references to %v are substituted from the shell's environment, since the secret store isn't exported", strings.Join(names, ", "))
+		}
+
+		for _, spec := range svc.Ports {
+			service.Ports = append(service.Ports, composePort(spec))
+		}
+
+		for _, spec := range svc.Volumes {
+			vm, _ := manifest.ParseVolume(spec)
+			if vm.Type == mount.TypeBind && strings.HasPrefix(vm.Source, manifest.LocalImagePrefix) {
+				source := fmt.Sprintf("%v/%v", composeDataDir, vm.Source[len(manifest.LocalImagePrefix):])
+				lose("bind mount %v is placed in %v rather than the project's data directory", vm.Source, source)
+				spec = strings.Replace(spec, vm.Source, source, 1)
+			}
+			service.Volumes = append(service.Volumes, spec)
+		}
+
+		if hc := svc.HealthCheck; hc != nil {
+			service.Healthcheck = &composeHealthcheck{
+				Test:        composeEscape([]string{"CMD-SHELL", hc.ShellCommand()}),
+				Interval:    hc.Interval,
+				Timeout:     hc.Timeout,
+				Retries:     hc.Retries,
+				StartPeriod: hc.StartPeriod,
+			}
+		}
+
+		// Other services reach the service by its hostname rather than its name
+		if svc.Hostname != "" && svc.Hostname != svc.Name {
+			service.Networks = map[string]composeNetwork{"default": {Aliases: []string{svc.Hostname}}}
+		}
+
+		if replicas := svc.GetReplicas(); replicas > 1 {
+			service.Deploy = &composeDeploy{Replicas: replicas}
+		}
+
+		if routing := svc.Routing; routing.Path.Pattern != "" {
+			routed = true
+			service.Labels = map[string]string{
+				"traefik.enable": "true",
+				fmt.Sprintf("traefik.http.routers.%v.rule", svc.Name): fmt.Sprintf(
+					"%v(`%v`)",
+					traefikMatchers[routing.Path.Type],
+					routing.Path.Pattern,
+				),
+				fmt.Sprintf("traefik.http.services.%v.loadbalancer.server.port", svc.Name): fmt.Sprint(routing.Port),
+			}
+		}
+
+		file.Services[svc.Name] = service
+	}
+
+	if routed {
+		ex.lose("", "routing is expressed as traefik labels, add a traefik service to route requests")
+	}
+	if len(ex.mfst.StaticRoutes.Paths) > 0 {
+		ex.lose("", "static_routes are not exported, serve %v from a web server service", ex.mfst.StaticRoutes.Webroot)
+	}
+
+	return marshal(file)
+}
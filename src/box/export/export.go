@@ -0,0 +1,220 @@
+package export
+
+import (
+	"box/manifest"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats a project can be exported to
+const (
+	FormatAppPlatform = "app-platform"
+	FormatK8s         = "k8s"
+	FormatCompose     = "compose"
+)
+
+// Loss is a feature of the manifest which doesn't map cleanly onto the export format
+type Loss struct {
+	// Service is empty for project wide features
+	Service string
+	Message string
+}
+
+func (l Loss) String() string {
+	if l.Service == "" {
+		return l.Message
+	}
+
+	return fmt.Sprintf("%v: %v", l.Service, l.Message)
+}
+
+type Options struct {
+	// Runtime environment and profiles which select the services exported
+	Env      string
+	Profiles []string
+	// Registry to which locally built images are pushed, eg: registry.digitalocean.com/myregistry
+	Registry string
+}
+
+type exporter struct {
+	mfst     *manifest.Manifest
+	opts     Options
+	services []*manifest.Service
+	losses   []Loss
+}
+
+// A value which refers to a single environment variable, which is expected to hold a secret
+var envRefRe *regexp.Regexp = regexp.MustCompile(`^\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}$`)
+
+// secretRef returns the name of the secret or environment variable the value refers to, or an empty string
+// if it holds a plain value
+func secretRef(value string) string {
+	if name := manifest.SecretRef(value); name != "" {
+		return name
+	}
+	if match := envRefRe.FindStringSubmatch(value); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
+
+// Images commonly run as a project's database, which are better replaced by a managed database
+var databaseImageRe *regexp.Regexp = regexp.MustCompile(`^(docker\.io/)?(library/)?(postgres|mysql|mariadb|redis|valkey|mongo)(:|$)`)
+
+// Export translates the manifest into the format, returning the result along with everything which
+// doesn't map cleanly
+func Export(mfst *manifest.Manifest, format string, opts Options) ([]byte, []Loss, error) {
+	ex := &exporter{mfst: mfst, opts: opts}
+
+	names := []string{}
+	for name := range mfst.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		service := mfst.Services[name]
+		if reason := mfst.ExcludedReason(service, opts.Env, opts.Profiles); reason != "" {
+			ex.lose(name, "not exported, %v", reason)
+			continue
+		}
+		ex.services = append(ex.services, service)
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case FormatAppPlatform:
+		data, err = ex.appPlatform()
+	case FormatK8s:
+		data, err = ex.k8s()
+	case FormatCompose:
+		data, err = ex.compose()
+	default:
+		return nil, nil, fmt.Errorf("Export format %v is invalid, must be one of %v, %v or %v", format, FormatAppPlatform, FormatK8s, FormatCompose)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, ex.losses, nil
+}
+
+// lose records a feature which doesn't map cleanly
+func (ex *exporter) lose(service, format string, args ...interface{}) {
+	ex.losses = append(ex.losses, Loss{Service: service, Message: fmt.Sprintf(format, args...)})
+}
+
+// isLocalImage returns true if the service's image is built by the project
+func isLocalImage(svc *manifest.Service) bool {
+	return strings.HasPrefix(svc.Image, manifest.LocalImagePrefix)
+}
+
+// image returns the image reference the service runs, with locally built images pushed to the registry
+func (ex *exporter) image(svc *manifest.Service) string {
+	if !isLocalImage(svc) {
+		return svc.Image
+	}
+
+	name := svc.Image[len(manifest.LocalImagePrefix):]
+	if ex.opts.Registry == "" {
+		ex.lose(svc.Name, "image %v is built locally, push it to a registry and export with --registry", svc.Image)
+		return fmt.Sprintf("%v:latest", name)
+	}
+
+	return fmt.Sprintf("%v/%v:latest", strings.TrimSuffix(ex.opts.Registry, "/"), name)
+}
+
+// containerPort is a port the service listens on inside its container
+type containerPort struct {
+	Port     int
+	Protocol string
+	// HostPort is set if the port is published on a public interface of the host
+	HostPort int
+}
+
+// containerPorts returns the ports the service is known to listen on, from its routing, port mappings and
+// healthcheck, in that order
+func containerPorts(svc *manifest.Service) []containerPort {
+	ports := []containerPort{}
+	add := func(port containerPort) {
+		for i, existing := range ports {
+			if existing.Port == port.Port && existing.Protocol == port.Protocol {
+				if port.HostPort != 0 {
+					ports[i].HostPort = port.HostPort
+				}
+				return
+			}
+		}
+		ports = append(ports, port)
+	}
+
+	if svc.Routing.Port != 0 {
+		add(containerPort{Port: svc.Routing.Port, Protocol: "tcp"})
+	}
+
+	for _, spec := range svc.Ports {
+		// Already validated
+		mappings, _ := nat.ParsePortSpec(spec)
+		for _, mapping := range mappings {
+			port := containerPort{Port: mapping.Port.Int(), Protocol: mapping.Port.Proto()}
+			if hostIP := mapping.Binding.HostIP; hostIP != "" && !strings.HasPrefix(hostIP, "127.") && hostIP != "::1" {
+				fmt.Sscan(mapping.Binding.HostPort, &port.HostPort)
+			}
+			add(port)
+		}
+	}
+
+	if hc := svc.HealthCheck; hc != nil {
+		switch {
+		case hc.HTTP != nil:
+			add(containerPort{Port: hc.HTTP.Port, Protocol: "tcp"})
+		case hc.TCP != 0:
+			add(containerPort{Port: hc.TCP, Protocol: "tcp"})
+		}
+	}
+
+	return ports
+}
+
+// seconds returns a duration in whole seconds, rounded up, or 0 if it isn't set
+func seconds(value string) int {
+	// Already validated
+	d, _ := time.ParseDuration(value)
+
+	return int(math.Ceil(d.Seconds()))
+}
+
+// sortedKeys returns the keys of the map, sorted
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// marshal encodes each document in turn, separated as a YAML stream
+func marshal(docs ...interface{}) ([]byte, error) {
+	out := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	encoder.Close()
+
+	return out.Bytes(), nil
+}
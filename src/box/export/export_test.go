@@ -0,0 +1,263 @@
+package export
+
+import (
+	"box/manifest"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// export exports a project holding the single service named web, returning the documents it was exported as
+func export(t *testing.T, svc *manifest.Service, format string) ([]interface{}, []Loss) {
+	svc.Name = "web"
+	mfst := &manifest.Manifest{
+		Project:  "test",
+		Services: map[string]*manifest.Service{"web": svc},
+	}
+
+	data, losses, err := Export(mfst, format, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := []interface{}{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, losses
+}
+
+// lookup returns the value at the dotted path, where numbers index lists, or nil if there's none
+func lookup(value interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+
+	return value
+}
+
+// checkExport verifies the values at each path of the exported documents, the first path element being
+// the index of the document, and that a loss containing wantLoss was recorded
+func checkExport(t *testing.T, docs []interface{}, losses []Loss, want map[string]interface{}, wantLoss string) {
+	for path, wantValue := range want {
+		if got := lookup(docs, path); !reflect.DeepEqual(got, wantValue) {
+			t.Errorf("got %#v at %v, want %#v", got, path, wantValue)
+		}
+	}
+
+	if wantLoss == "" {
+		return
+	}
+	for _, loss := range losses {
+		if strings.Contains(loss.Message, wantLoss) {
+			return
+		}
+	}
+	t.Errorf("no loss contains %q: %v", wantLoss, losses)
+}
+
+func TestExportSecrets(t *testing.T) {
+	service := func() *manifest.Service {
+		return &manifest.Service{
+			Image: "nginx",
+			Environment: map[string]string{
+				"DB_PASSWORD": "secret:db_password",
+				"API_KEY":     "${API_KEY}",
+				"MODE":        "production",
+			},
+			Routing: manifest.Routing{Path: manifest.Path{Pattern: "/", Type: manifest.PathTypePrefix}, Port: 80},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		want     map[string]interface{}
+		wantLoss string
+	}{
+		{
+			name:   "compose",
+			format: FormatCompose,
+			want: map[string]interface{}{
+				"0.services.web.environment.DB_PASSWORD": "${db_password}",
+				"0.services.web.environment.API_KEY":     "${API_KEY}",
+				"0.services.web.environment.MODE":        "production",
+				"0.secrets":                              nil,
+			},
+			wantLoss: "secret references to db_password are substituted from the shell's environment",
+		},
+		{
+			name:   "k8s",
+			format: FormatK8s,
+			want: map[string]interface{}{
+				"0.spec.template.spec.containers.0.env.0.name":                        "API_KEY",
+				"0.spec.template.spec.containers.0.env.0.valueFrom.secretKeyRef.name": "test-secrets",
+				"0.spec.template.spec.containers.0.env.0.valueFrom.secretKeyRef.key":  "API_KEY",
+				"0.spec.template.spec.containers.0.env.1.name":                        "DB_PASSWORD",
+				"0.spec.template.spec.containers.0.env.1.valueFrom.secretKeyRef.key":  "db_password",
+				"0.spec.template.spec.containers.0.env.2.value":                       "production",
+			},
+			wantLoss: "kubectl create secret generic test-secrets --from-literal=API_KEY=... --from-literal=db_password=...",
+		},
+		{
+			name:   "app platform",
+			format: FormatAppPlatform,
+			want: map[string]interface{}{
+				"0.services.0.envs.0.key":   "API_KEY",
+				"0.services.0.envs.0.type":  "SECRET",
+				"0.services.0.envs.0.value": nil,
+				"0.services.0.envs.1.key":   "DB_PASSWORD",
+				"0.services.0.envs.1.type":  "SECRET",
+				"0.services.0.envs.1.value": nil,
+				"0.services.0.envs.2.value": "production",
+				"0.services.0.envs.2.type":  "GENERAL",
+			},
+			wantLoss: "secret values must be set in App Platform for web.API_KEY, web.DB_PASSWORD",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			docs, losses := export(t, service(), test.format)
+			checkExport(t, docs, losses, test.want, test.wantLoss)
+		})
+	}
+}
+
+func TestExportHealthChecks(t *testing.T) {
+	httpCheck := &manifest.HealthCheck{
+		HTTP:     &manifest.HTTPCheck{Path: "/health", Port: 8080},
+		Interval: "10s",
+		Timeout:  "1500ms",
+		Retries:  3,
+	}
+	tcpCheck := &manifest.HealthCheck{TCP: 5432, StartPeriod: "30s"}
+	commandCheck := &manifest.HealthCheck{Command: "test -f $HOME/ready"}
+
+	tests := []struct {
+		name     string
+		format   string
+		check    *manifest.HealthCheck
+		want     map[string]interface{}
+		wantLoss string
+	}{
+		{
+			name:   "compose http",
+			format: FormatCompose,
+			check:  httpCheck,
+			want: map[string]interface{}{
+				"0.services.web.healthcheck.test": []interface{}{
+					"CMD-SHELL",
+					"wget -q -O /dev/null http://127.0.0.1:8080/health || curl -fsS -o /dev/null http://127.0.0.1:8080/health",
+				},
+				"0.services.web.healthcheck.interval": "10s",
+				"0.services.web.healthcheck.timeout":  "1500ms",
+				"0.services.web.healthcheck.retries":  3,
+			},
+		},
+		{
+			name:   "compose command escaped",
+			format: FormatCompose,
+			check:  commandCheck,
+			want: map[string]interface{}{
+				"0.services.web.healthcheck.test.1": "test -f $$HOME/ready",
+			},
+		},
+		{
+			name:   "k8s http",
+			format: FormatK8s,
+			check:  httpCheck,
+			want: map[string]interface{}{
+				"0.spec.template.spec.containers.0.readinessProbe.httpGet.path":     "/health",
+				"0.spec.template.spec.containers.0.readinessProbe.httpGet.port":     8080,
+				"0.spec.template.spec.containers.0.readinessProbe.periodSeconds":    10,
+				"0.spec.template.spec.containers.0.readinessProbe.timeoutSeconds":   2,
+				"0.spec.template.spec.containers.0.readinessProbe.failureThreshold": 3,
+				"0.spec.template.spec.containers.0.ports.0.containerPort":           8080,
+			},
+		},
+		{
+			name:   "k8s tcp",
+			format: FormatK8s,
+			check:  tcpCheck,
+			want: map[string]interface{}{
+				"0.spec.template.spec.containers.0.readinessProbe.tcpSocket.port":      5432,
+				"0.spec.template.spec.containers.0.readinessProbe.initialDelaySeconds": 30,
+				"0.spec.template.spec.containers.0.readinessProbe.periodSeconds":       nil,
+			},
+		},
+		{
+			name:   "k8s command",
+			format: FormatK8s,
+			check:  commandCheck,
+			want: map[string]interface{}{
+				"0.spec.template.spec.containers.0.readinessProbe.exec.command": []interface{}{"/bin/sh", "-c", "test -f $HOME/ready"},
+			},
+		},
+		{
+			name:   "app platform http",
+			format: FormatAppPlatform,
+			check:  httpCheck,
+			want: map[string]interface{}{
+				"0.services.0.health_check.http_path":         "/health",
+				"0.services.0.health_check.port":              8080,
+				"0.services.0.health_check.period_seconds":    10,
+				"0.services.0.health_check.timeout_seconds":   2,
+				"0.services.0.health_check.failure_threshold": 3,
+			},
+		},
+		{
+			name:   "app platform tcp",
+			format: FormatAppPlatform,
+			check:  tcpCheck,
+			want: map[string]interface{}{
+				"0.services.0.health_check.port":                  5432,
+				"0.services.0.health_check.http_path":             nil,
+				"0.services.0.health_check.initial_delay_seconds": 30,
+			},
+		},
+		{
+			name:   "app platform command",
+			format: FormatAppPlatform,
+			check:  commandCheck,
+			want: map[string]interface{}{
+				"0.workers.0.name":         "web",
+				"0.workers.0.health_check": nil,
+			},
+			wantLoss: "healthcheck commands are not supported",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			docs, losses := export(t, &manifest.Service{Image: "postgres", HealthCheck: test.check}, test.format)
+			checkExport(t, docs, losses, test.want, test.wantLoss)
+		})
+	}
+}
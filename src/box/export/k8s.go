@@ -0,0 +1,350 @@
+package export
+
+import (
+	"box/manifest"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	units "github.com/docker/go-units"
+)
+
+// object is a Kubernetes object, or a part of one
+type object map[string]interface{}
+
+// Storage requested for each named volume, there's no way to know how much a volume needs
+const k8sVolumeSize = "1Gi"
+
+// Storage class for volumes placed on block storage in production, the default on DigitalOcean Kubernetes
+const k8sBlockStorageClass = "do-block-storage"
+
+// k8sPathTypes maps routing path types onto ingress path types
+var k8sPathTypes = map[string]string{
+	manifest.PathTypePrefix: "Prefix",
+	manifest.PathTypeExact:  "Exact",
+	manifest.PathTypeRegex:  "ImplementationSpecific",
+}
+
+func (ex *exporter) k8sMetadata(name string) object {
+	return object{
+		"name": name,
+		"labels": object{
+			"app.kubernetes.io/name":    name,
+			"app.kubernetes.io/part-of": ex.mfst.Project,
+		},
+	}
+}
+
+// k8sSecretName is the name of the secret holding the values of environment variable references
+func (ex *exporter) k8sSecretName() string {
+	return fmt.Sprintf("%v-secrets", ex.mfst.Project)
+}
+
+// k8sProbe returns a readiness probe for the healthcheck
+func k8sProbe(hc *manifest.HealthCheck) object {
+	probe := object{}
+	switch {
+	case hc.HTTP != nil:
+		probe["httpGet"] = object{"path": hc.HTTP.Path, "port": hc.HTTP.Port}
+	case hc.TCP != 0:
+		probe["tcpSocket"] = object{"port": hc.TCP}
+	default:
+		probe["exec"] = object{"command": []string{"/bin/sh", "-c", hc.Command}}
+	}
+
+	settings := map[string]int{
+		"initialDelaySeconds": seconds(hc.StartPeriod),
+		"periodSeconds":       seconds(hc.Interval),
+		"timeoutSeconds":      seconds(hc.Timeout),
+		"failureThreshold":    hc.Retries,
+	}
+	for key, value := range settings {
+		if value > 0 {
+			probe[key] = value
+		}
+	}
+
+	return probe
+}
+
+// k8sSecurityContext returns the security context running the container as the user, or nil if the user
+// isn't numeric
+func k8sSecurityContext(user string) object {
+	parts := strings.SplitN(user, ":", 2)
+	uid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil
+	}
+
+	context := object{"runAsUser": uid}
+	if len(parts) == 2 {
+		gid, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil
+		}
+		context["runAsGroup"] = gid
+	}
+
+	return context
+}
+
+// k8sService exports a single service as a deployment, along with the services which reach it
+func (ex *exporter) k8sService(svc *manifest.Service, secrets map[string]bool) []object {
+	lose := func(format string, args ...interface{}) {
+		ex.lose(svc.Name, format, args...)
+	}
+	objects := []object{}
+
+	container := object{
+		"name":  svc.Name,
+		"image": ex.image(svc),
+	}
+	if len(svc.Entrypoint) > 0 {
+		container["command"] = []string(svc.Entrypoint)
+	}
+	if len(svc.Command) > 0 {
+		container["args"] = []string(svc.Command)
+	}
+	if svc.WorkingDir != "" {
+		container["workingDir"] = svc.WorkingDir
+	}
+	if svc.User != "" {
+		if context := k8sSecurityContext(svc.User); context != nil {
+			container["securityContext"] = context
+		} else {
+			lose("user %v must be numeric to run as it", svc.User)
+		}
+	}
+
+	env := []object{}
+	for _, key := range sortedKeys(svc.Environment) {
+		value := svc.Environment[key]
+		if name := secretRef(value); name != "" {
+			secrets[name] = true
+			env = append(env, object{
+				"name": key,
+				"valueFrom": object{
+					"secretKeyRef": object{"name": ex.k8sSecretName(), "key": name},
+				},
+			})
+			continue
+		}
+		if strings.Contains(value, "${") {
+			lose("environment %v refers to other variables, which are not substituted", key)
+		}
+		env = append(env, object{"name": key, "value": value})
+	}
+	if len(env) > 0 {
+		container["env"] = env
+	}
+
+	limits := object{}
+	if svc.Memory != "" {
+		// Already validated
+		memory, _ := units.RAMInBytes(svc.Memory)
+		limits["memory"] = strconv.FormatInt(memory, 10)
+	}
+	if svc.CPUs > 0 {
+		limits["cpu"] = fmt.Sprintf("%vm", int(svc.CPUs*1000))
+	}
+	if len(limits) > 0 {
+		container["resources"] = object{"limits": limits}
+	}
+
+	if svc.HealthCheck != nil {
+		container["readinessProbe"] = k8sProbe(svc.HealthCheck)
+	}
+
+	ports := containerPorts(svc)
+	servicePorts := []object{}
+	publicPorts := []object{}
+	podPorts := []object{}
+	for _, port := range ports {
+		protocol := strings.ToUpper(port.Protocol)
+		name := fmt.Sprintf("%v-%v", port.Protocol, port.Port)
+		podPorts = append(podPorts, object{"containerPort": port.Port, "protocol": protocol, "name": name})
+		servicePorts = append(servicePorts, object{"port": port.Port, "targetPort": name, "protocol": protocol, "name": name})
+		if port.HostPort != 0 {
+			publicPorts = append(publicPorts, object{"port": port.HostPort, "targetPort": name, "protocol": protocol, "name": name})
+		}
+	}
+	if len(podPorts) > 0 {
+		container["ports"] = podPorts
+	}
+
+	volumes := []object{}
+	mounts := []object{}
+	for i, spec := range svc.Volumes {
+		vm, _ := manifest.ParseVolume(spec)
+		if vm.Type == mount.TypeBind {
+			lose("bind mount %v is not supported, use a named volume or bake the files into the image", spec)
+			continue
+		}
+		name := fmt.Sprintf("volume-%v", i)
+		volumes = append(volumes, object{"name": name, "persistentVolumeClaim": object{"claimName": vm.Source}})
+		volumeMount := object{"name": name, "mountPath": vm.Target}
+		if vm.ReadOnly {
+			volumeMount["readOnly"] = true
+		}
+		mounts = append(mounts, volumeMount)
+		if svc.GetReplicas() > 1 {
+			lose("volume %v is shared by %v replicas, which requires a storage class supporting ReadWriteMany", vm.Source, svc.GetReplicas())
+		}
+	}
+	for i, spec := range svc.Tmpfs {
+		target := strings.SplitN(spec, ":", 2)[0]
+		emptyDir := object{"medium": "Memory"}
+		for _, option := range strings.Split(strings.TrimPrefix(spec, target+":"), ",") {
+			if strings.HasPrefix(option, "size=") {
+				// Already validated
+				size, _ := units.RAMInBytes(strings.TrimPrefix(option, "size="))
+				emptyDir["sizeLimit"] = strconv.FormatInt(size, 10)
+			}
+		}
+		name := fmt.Sprintf("tmpfs-%v", i)
+		volumes = append(volumes, object{"name": name, "emptyDir": emptyDir})
+		mounts = append(mounts, object{"name": name, "mountPath": target})
+	}
+	if len(mounts) > 0 {
+		container["volumeMounts"] = mounts
+	}
+
+	podSpec := object{"containers": []object{container}}
+	if len(volumes) > 0 {
+		podSpec["volumes"] = volumes
+	}
+	if svc.StopGracePeriod != "" {
+		podSpec["terminationGracePeriodSeconds"] = seconds(svc.StopGracePeriod)
+	}
+
+	objects = append(objects, object{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   ex.k8sMetadata(svc.Name),
+		"spec": object{
+			"replicas": svc.GetReplicas(),
+			"selector": object{"matchLabels": object{"app.kubernetes.io/name": svc.Name}},
+			"template": object{
+				"metadata": object{"labels": ex.k8sMetadata(svc.Name)["labels"]},
+				"spec":     podSpec,
+			},
+		},
+	})
+
+	// Other services reach the service by its hostname
+	if len(servicePorts) > 0 {
+		objects = append(objects, object{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   ex.k8sMetadata(svc.GetHostname()),
+			"spec": object{
+				"selector": object{"app.kubernetes.io/name": svc.Name},
+				"ports":    servicePorts,
+			},
+		})
+	} else {
+		lose("no service was created since it declares no ports, add one if other services connect to it")
+	}
+	if len(publicPorts) > 0 {
+		objects = append(objects, object{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   ex.k8sMetadata(fmt.Sprintf("%v-public", svc.GetHostname())),
+			"spec": object{
+				"type":     "LoadBalancer",
+				"selector": object{"app.kubernetes.io/name": svc.Name},
+				"ports":    publicPorts,
+			},
+		})
+	}
+
+	if len(svc.DependsOn) > 0 {
+		lose("depends_on is not supported, containers start in any order")
+	}
+	if svc.Restart == manifest.RestartNo || strings.HasPrefix(svc.Restart, manifest.RestartOnFailure) {
+		lose("restart %v is not supported, deployments always restart their containers", svc.Restart)
+	}
+
+	return objects
+}
+
+// k8s exports the project as Kubernetes objects
+func (ex *exporter) k8s() ([]byte, error) {
+	docs := []interface{}{}
+	secrets := map[string]bool{}
+	paths := []object{}
+
+	volumeNames := []string{}
+	for name := range ex.mfst.Volumes {
+		volumeNames = append(volumeNames, name)
+	}
+	sort.Strings(volumeNames)
+	for _, name := range volumeNames {
+		spec := object{
+			"accessModes": []string{"ReadWriteOnce"},
+			"resources":   object{"requests": object{"storage": k8sVolumeSize}},
+		}
+		if ex.mfst.Volumes[name].BlockStorage {
+			spec["storageClassName"] = k8sBlockStorageClass
+		}
+		docs = append(docs, object{
+			"apiVersion": "v1",
+			"kind":       "PersistentVolumeClaim",
+			"metadata":   ex.k8sMetadata(name),
+			"spec":       spec,
+		})
+	}
+	if len(ex.mfst.Volumes) > 0 {
+		ex.lose("", "volumes request %v each, adjust this to the data they hold", k8sVolumeSize)
+	}
+
+	for _, svc := range ex.services {
+		for _, obj := range ex.k8sService(svc, secrets) {
+			docs = append(docs, obj)
+		}
+
+		if svc.Routing.Path.Pattern == "" {
+			continue
+		}
+		if svc.Routing.Path.Type == manifest.PathTypeRegex {
+			ex.lose(svc.Name, "routing regex %v depends on the ingress controller supporting regular expressions", svc.Routing.Path.Pattern)
+		}
+		paths = append(paths, object{
+			"path":     svc.Routing.Path.Pattern,
+			"pathType": k8sPathTypes[svc.Routing.Path.Type],
+			"backend": object{
+				"service": object{
+					"name": svc.GetHostname(),
+					"port": object{"number": svc.Routing.Port},
+				},
+			},
+		})
+	}
+
+	if len(paths) > 0 {
+		docs = append(docs, object{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "Ingress",
+			"metadata":   ex.k8sMetadata(ex.mfst.Project),
+			"spec": object{
+				"rules": []object{{"http": object{"paths": paths}}},
+			},
+		})
+	}
+
+	if len(secrets) > 0 {
+		keys := []string{}
+		for key := range secrets {
+			keys = append(keys, fmt.Sprintf("--from-literal=%v=...", key))
+		}
+		sort.Strings(keys)
+		ex.lose("", "create the secret referenced by the environment with: kubectl create secret generic %v %v", ex.k8sSecretName(), strings.Join(keys, " "))
+	}
+	if len(ex.mfst.StaticRoutes.Paths) > 0 {
+		ex.lose("", "static_routes are not exported, serve %v from a web server or object storage", ex.mfst.StaticRoutes.Webroot)
+	}
+
+	return marshal(docs...)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"box/export"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+type ExportCmd struct {
+	Format   string   `required:"" enum:"app-platform,k8s,compose" help:"Format to export to, one of app-platform, k8s or compose"`
+	Env      string   `default:"prod" enum:"dev,prod" help:"Runtime environment whose services and override are exported"`
+	Profile  []string `help:"Profiles whose services are exported, may be repeated"`
+	Registry string   `help:"Registry the project's locally built images are pushed to, eg: registry.digitalocean.com/myregistry"`
+	Output   string   `short:"o" help:"File to write, defaults to standard output"`
+}
+
+func (cmd *ExportCmd) Run() error {
+	mfst, err := loadManifest(cmd.Env)
+	if err != nil {
+		return err
+	}
+
+	data, losses, err := export.Export(mfst, cmd.Format, export.Options{
+		Env:      cmd.Env,
+		Profiles: cmd.Profile,
+		Registry: cmd.Registry,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The report goes to stderr when the export is written to stdout, so it can be piped
+	report := os.Stderr
+	if cmd.Output == "" {
+		os.Stdout.Write(data)
+	} else {
+		report = os.Stdout
+		fmt.Printf("Writing %v export to %v...", cmd.Format, cmd.Output)
+		if err := ioutil.WriteFile(cmd.Output, data, 0644); err != nil {
+			return err
+		}
+		fmt.Println("Done")
+	}
+
+	if len(losses) > 0 {
+		fmt.Fprintf(report, "\nThe following don't map cleanly to %v:\n", cmd.Format)
+		for _, loss := range losses {
+			fmt.Fprintf(report, "  - %v\n", loss)
+		}
+	}
+
+	return nil
+}
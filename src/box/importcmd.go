@@ -0,0 +1,152 @@
+package main
+
+import (
+	"box/compose"
+	"box/manifest"
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/moby/term"
+)
+
+type ImportCmd struct {
+	Compose ImportComposeCmd `cmd:"" help:"Convert a docker-compose file into a manifest"`
+}
+
+type ImportComposeCmd struct {
+	File     string `arg:"" optional:"" help:"Compose file to import, defaults to compose.yaml or docker-compose.yml in the current directory"`
+	Project  string `help:"Project name, defaults to the compose project name or the directory name"`
+	Output   string `short:"o" default:"box.yml" help:"Manifest file to write"`
+	Force    bool   `help:"Overwrite the manifest if it already exists"`
+	NoPrompt bool   `help:"Don't ask how to route services whose routing can't be inferred from their labels"`
+}
+
+// newRoutingPrompt returns a prompt which asks on the terminal whether to route HTTP requests to a service
+func newRoutingPrompt() compose.RoutingPrompt {
+	reader := bufio.NewReader(os.Stdin)
+	readLine := func(prompt string) (string, error) {
+		fmt.Print(prompt)
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(input), nil
+	}
+
+	return func(service string, ports []int) (*manifest.Routing, error) {
+		portNames := []string{}
+		for _, port := range ports {
+			portNames = append(portNames, strconv.Itoa(port))
+		}
+		fmt.Printf("\nService %v listens on port %v\n", service, strings.Join(portNames, ", "))
+
+		var pattern string
+		for {
+			var err error
+			pattern, err = readLine("Path prefix to route HTTP requests to it, eg: /api (leave empty to not route it)\n>")
+			if err != nil {
+				return nil, err
+			}
+			if pattern == "" {
+				return nil, nil
+			}
+			if strings.HasPrefix(pattern, "/") {
+				break
+			}
+			fmt.Println("The path prefix must begin with /")
+		}
+
+		port := ports[0]
+		for len(ports) > 1 {
+			input, err := readLine("Port it serves HTTP on\n>")
+			if err != nil {
+				return nil, err
+			}
+			port, err = strconv.Atoi(input)
+			if err == nil && port > 0 && port <= 65535 {
+				break
+			}
+			fmt.Println("The port must be a number between 1 and 65535")
+		}
+
+		return &manifest.Routing{
+			Path: manifest.Path{Pattern: pattern, Type: manifest.PathTypePrefix},
+			Port: port,
+		}, nil
+	}
+}
+
+// findComposeFile returns the first of the default compose files present in the current directory
+func findComposeFile() (string, error) {
+	for _, filename := range compose.DefaultFilenames {
+		if _, err := os.Stat(filename); err == nil {
+			return filename, nil
+		}
+	}
+
+	return "", fmt.Errorf("Unable to locate a compose file, looked for %v", strings.Join(compose.DefaultFilenames, ", "))
+}
+
+func (cmd *ImportComposeCmd) Run() error {
+	filename := cmd.File
+	if filename == "" {
+		var err error
+		filename, err = findComposeFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	output, err := filepath.Abs(cmd.Output)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(output); err == nil && !cmd.Force {
+		return fmt.Errorf("%v already exists, use --force to overwrite it", cmd.Output)
+	}
+
+	opts := compose.Options{
+		Project:   cmd.Project,
+		OutputDir: filepath.Dir(output),
+	}
+	if !cmd.NoPrompt && term.IsTerminal(os.Stdin.Fd()) {
+		opts.Prompt = newRoutingPrompt()
+	}
+
+	result, err := compose.Convert(filename, opts)
+	if err != nil {
+		return err
+	}
+
+	data, err := result.Manifest.Marshal()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nWriting %v services to %v...", len(result.Manifest.Services), cmd.Output)
+	if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	if len(result.Losses) > 0 {
+		fmt.Printf("\nThe following couldn't be imported:\n")
+		for _, loss := range result.Losses {
+			fmt.Printf("  - %v\n", loss)
+		}
+	}
+
+	// Build contexts are resolved against the current directory, so the manifest can only be checked here
+	if dir, err := os.Getwd(); err == nil && dir == opts.OutputDir {
+		if _, err := manifest.NewManifest(output); err != nil {
+			fmt.Printf("\nThe imported manifest needs fixing before it can be run:\n%v\n", err)
+		}
+	}
+
+	return nil
+}
@@ -2,14 +2,48 @@ package main
 
 import (
 	"box/config"
+	"os"
+
+	"github.com/moby/term"
 )
 
 type InitCmd struct {
-	Name string `arg help="Project name"`
+	Name        string `arg help="Project name"`
+	Seed        string `help:"YAML file holding any of the values below by their underscored names, eg: api_token" type:"existingfile"`
+	APIToken    string `name:"api-token" help:"DigitalOcean API token, stored in the credential store.  Not needed when DIGITALOCEAN_TOKEN is set or box auth login was run"`
+	Region      string `env:"BOX_REGION" help:"Deployment region"`
+	VolumeSize  int    `env:"BOX_VOLUME_SIZE" help:"Volume size in gigabytes"`
+	DropletSlug string `env:"BOX_DROPLET_SLUG" help:"Droplet slug"`
+	Domain      string `env:"BOX_DOMAIN" help:"Bare domain name, eg: mysite.com"`
+	Email       string `env:"BOX_EMAIL" help:"TLS certificate registration email for use with letsencrypt"`
+	PrivateKey  string `env:"BOX_PRIVATE_KEY" help:"Private key full path, whose public key is alongside it with a .pub extension"`
+	GenerateKey bool   `env:"BOX_GENERATE_KEY" help:"Generate a dedicated ed25519 key for the project under its configuration directory, instead of using --private-key"`
+	Context     string `env:"BOX_CONTEXT" help:"Context whose account the project is created against, and whose values are used for any not given.  Defaults to the current context"`
 }
 
+// Values are taken from flags, then environment variables, then the seed file.  Anything still missing is
+// prompted for when attached to a terminal.
 func (cmd *InitCmd) Run() error {
-	_, err := config.New(cmd.Name)
+	seed := &config.Seed{
+		APIToken:    cmd.APIToken,
+		Region:      cmd.Region,
+		VolumeSize:  cmd.VolumeSize,
+		DropletSlug: cmd.DropletSlug,
+		Domain:      cmd.Domain,
+		Email:       cmd.Email,
+		PrivateKey:  cmd.PrivateKey,
+		GenerateKey: cmd.GenerateKey,
+		Context:     cmd.Context,
+	}
+	if cmd.Seed != "" {
+		fileSeed, err := config.LoadSeed(cmd.Seed)
+		if err != nil {
+			return err
+		}
+		seed.Merge(fileSeed)
+	}
+
+	_, err := config.New(cmd.Name, seed, term.IsTerminal(os.Stdin.Fd()))
 	if err != nil {
 		return err
 	}
@@ -15,6 +15,20 @@ var cli struct {
 	Dev      DevCmd        `cmd help="Run box project in development mode"`
 	Shutdown ShutdownCmd   `cmd help="Shut down the current project"`
 	Build    BuildCmd      `cmd help="Build the current project"`
+	Deploy   DeployCmd     `cmd:"" help:"Build the current project and deploy it to its droplet, pushing only changed images"`
+	Volume   VolumeCmd     `cmd:"" help:"Manage the current project's named volumes"`
+	Scale    ScaleCmd      `cmd:"" help:"Change the number of running replicas of services in the current project"`
+	Status   StatusCmd     `cmd:"" help:"Show the state of the current project's services"`
+	Config   ConfigCmd     `cmd:"" help:"Inspect the current project's manifest, and manage its configuration"`
+	Validate ValidateCmd   `cmd:"" help:"Check the current project's manifest for errors"`
+	Import   ImportCmd     `cmd:"" help:"Create a manifest from another project format"`
+	Export   ExportCmd     `cmd:"" help:"Translate the current project into another platform's format"`
+	Secrets  SecretsCmd    `cmd:"" help:"Manage the current project's encrypted secrets"`
+	Auth     AuthCmd       `cmd:"" help:"Manage the DigitalOcean API tokens used by all projects"`
+	Context  ContextCmd    `cmd:"" help:"Manage the accounts and defaults shared by projects"`
+	Adopt    AdoptCmd      `cmd:"" help:"Rebuild a project's lost configuration from the DigitalOcean resources tagged with it"`
+	Project  ProjectCmd    `cmd:"" help:"Share projects with teammates"`
+	Access   AccessCmd     `cmd:"" help:"Manage team members' SSH access to a project's droplet"`
 }
 
 func main() {
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Arguments which don't need quoting when joined into a command line
+var shellSafeRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z0-9_@%+=:,./-]+$")
+
+// Command is a command or entrypoint override.  In YAML it may be expressed either as a list of
+// arguments, or as a single string which is split into arguments using shell quoting rules (without
+// any variable expansion).
+type Command []string
+
+// UnmarshalYAML accepts both the list and string forms of a command
+func (cmd *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	args := []string{}
+	if err := unmarshal(&args); err == nil {
+		*cmd = args
+		return nil
+	}
+
+	var line string
+	if err := unmarshal(&line); err != nil {
+		return err
+	}
+
+	args, err := splitCommand(line)
+	if err != nil {
+		return err
+	}
+	*cmd = args
+
+	return nil
+}
+
+// String returns the command as a single command line, quoting arguments where needed
+func (cmd Command) String() string {
+	quoted := []string{}
+	for _, arg := range cmd {
+		if !shellSafeRe.Match([]byte(arg)) {
+			arg = fmt.Sprintf("'%v'", strings.ReplaceAll(arg, "'", `'\''`))
+		}
+		quoted = append(quoted, arg)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// splitCommand splits a command line into arguments, honouring single quotes, double quotes and
+// backslash escapes
+func splitCommand(line string) ([]string, error) {
+	args := []string{}
+	current := strings.Builder{}
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("Unterminated quote or escape in command: %v", line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Conditions which a dependency must satisfy before a dependent service is started
+const (
+	ConditionServiceStarted               = "service_started"
+	ConditionServiceHealthy               = "service_healthy"
+	ConditionServiceCompletedSuccessfully = "service_completed_successfully"
+)
+
+// Dependency describes the condition under which a service dependency is considered satisfied
+type Dependency struct {
+	Condition string `yaml:"condition,omitempty"`
+}
+
+// Dependencies maps a service name to its dependency condition.  In YAML it may be expressed either as a
+// list of service names (each implying service_started), or as a map of service names to conditions.
+type Dependencies map[string]Dependency
+
+// UnmarshalYAML accepts both the list and map forms of depends_on
+func (deps *Dependencies) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names := []string{}
+	if err := unmarshal(&names); err == nil {
+		*deps = Dependencies{}
+		for _, name := range names {
+			(*deps)[name] = Dependency{Condition: ConditionServiceStarted}
+		}
+		return nil
+	}
+
+	m := map[string]Dependency{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for name, dep := range m {
+		if dep.Condition == "" {
+			dep.Condition = ConditionServiceStarted
+		}
+		m[name] = dep
+	}
+	*deps = m
+
+	return nil
+}
+
+// Names returns the names of all dependencies in lexical order
+func (deps Dependencies) Names() []string {
+	names := []string{}
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func validateDependency(service, name string, dep Dependency, services map[string]*Service) error {
+	depService, ok := services[name]
+	if !ok {
+		return fmt.Errorf("Service: %v\nDepends on unknown service \"%v\"", service, name)
+	}
+	if name == service {
+		return fmt.Errorf("Service: %v\nA service cannot depend on itself", service)
+	}
+
+	switch dep.Condition {
+	case ConditionServiceStarted, ConditionServiceCompletedSuccessfully:
+	case ConditionServiceHealthy:
+		if depService.HealthCheck == nil {
+			return fmt.Errorf(
+				"Service: %v\nDepends on \"%v\" being healthy, but \"%v\" has no healthcheck",
+				service,
+				name,
+				name,
+			)
+		}
+	default:
+		return fmt.Errorf(
+			"Service: %v\nDependency condition \"%v\" is invalid, must be one of %v, %v or %v",
+			service,
+			dep.Condition,
+			ConditionServiceStarted,
+			ConditionServiceHealthy,
+			ConditionServiceCompletedSuccessfully,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Position is a location within a manifest file
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) String() string {
+	filename := p.Filename
+	if dir, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(dir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			filename = rel
+		}
+	}
+
+	return fmt.Sprintf("%v:%v:%v", filename, p.Line, p.Column)
+}
+
+// Error is a single problem found in a manifest, at the position of the offending value
+type Error struct {
+	Position Position
+	Message  string
+}
+
+func (e *Error) Error() string {
+	// Indent continuation lines so each error stands apart
+	return fmt.Sprintf("%v: %v", e.Position, strings.ReplaceAll(e.Message, "\n", "\n    "))
+}
+
+// Errors holds every problem found in a manifest
+type Errors []*Error
+
+func (errs Errors) Error() string {
+	lines := []string{}
+	for _, err := range errs {
+		lines = append(lines, err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
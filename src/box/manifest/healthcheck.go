@@ -0,0 +1,127 @@
+package manifest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// HTTPCheck probes an HTTP path on a port inside the container, succeeding on any 2xx or 3xx response
+type HTTPCheck struct {
+	Path string `yaml:"path,omitempty"`
+	Port int    `yaml:"port,omitempty"`
+}
+
+// HealthCheck describes how the health of a service container is determined.  Exactly one of command,
+// http or tcp must be specified.  HTTP and TCP checks are performed from inside the container, so the
+// image must provide wget or curl (HTTP), or nc (TCP).
+type HealthCheck struct {
+	Command     string     `yaml:"command,omitempty"`
+	HTTP        *HTTPCheck `yaml:"http,omitempty"`
+	TCP         int        `yaml:"tcp,omitempty"`
+	Interval    string     `yaml:"interval,omitempty"`
+	Timeout     string     `yaml:"timeout,omitempty"`
+	Retries     int        `yaml:"retries,omitempty"`
+	StartPeriod string     `yaml:"start_period,omitempty"`
+}
+
+// ShellCommand returns the check as a shell command run inside the container
+func (hc *HealthCheck) ShellCommand() string {
+	switch {
+	case hc.HTTP != nil:
+		url := fmt.Sprintf("http://127.0.0.1:%v%v", hc.HTTP.Port, hc.HTTP.Path)
+		return fmt.Sprintf("wget -q -O /dev/null %v || curl -fsS -o /dev/null %v", url, url)
+	case hc.TCP != 0:
+		return fmt.Sprintf("nc -z 127.0.0.1 %v", hc.TCP)
+	}
+
+	return hc.Command
+}
+
+// getTest returns the docker healthcheck test, in CMD-SHELL form
+func (hc *HealthCheck) getTest() []string {
+	return []string{"CMD-SHELL", hc.ShellCommand()}
+}
+
+// GetHealthConfig returns the docker healthcheck configuration, or nil if there is none (in which case
+// any healthcheck defined by the image applies)
+func (svc *Service) GetHealthConfig() *container.HealthConfig {
+	hc := svc.HealthCheck
+	if hc == nil {
+		return nil
+	}
+
+	// Durations have already been validated
+	interval, _ := parseOptionalDuration(hc.Interval)
+	timeout, _ := parseOptionalDuration(hc.Timeout)
+	startPeriod, _ := parseOptionalDuration(hc.StartPeriod)
+
+	return &container.HealthConfig{
+		Test:        hc.getTest(),
+		Interval:    interval,
+		Timeout:     timeout,
+		StartPeriod: startPeriod,
+		Retries:     hc.Retries,
+	}
+}
+
+// parseOptionalDuration parses a duration string such as "10s", where an empty string yields zero
+func parseOptionalDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func validateHealthCheck(service string, hc *HealthCheck) error {
+	if hc == nil {
+		return nil
+	}
+
+	checks := 0
+	if hc.Command != "" {
+		checks++
+	}
+	if hc.HTTP != nil {
+		checks++
+		if hc.HTTP.Port < 1 || hc.HTTP.Port > 65535 {
+			return fmt.Errorf("Service: %v\nHealthcheck HTTP port %v is invalid", service, hc.HTTP.Port)
+		}
+		if hc.HTTP.Path == "" || hc.HTTP.Path[0] != '/' {
+			return fmt.Errorf("Service: %v\nHealthcheck HTTP path must begin with /", service)
+		}
+	}
+	if hc.TCP != 0 {
+		checks++
+		if hc.TCP < 1 || hc.TCP > 65535 {
+			return fmt.Errorf("Service: %v\nHealthcheck TCP port %v is invalid", service, hc.TCP)
+		}
+	}
+	if checks != 1 {
+		return fmt.Errorf("Service: %v\nHealthcheck must specify exactly one of command, http or tcp", service)
+	}
+
+	durations := map[string]string{
+		"interval":     hc.Interval,
+		"timeout":      hc.Timeout,
+		"start_period": hc.StartPeriod,
+	}
+	for name, value := range durations {
+		d, err := parseOptionalDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("Service: %v\nHealthcheck %v \"%v\" is not a valid duration.  Eg: 10s", service, name, value)
+		}
+		// The engine rejects non-zero durations under a millisecond
+		if d > 0 && d < time.Millisecond {
+			return fmt.Errorf("Service: %v\nHealthcheck %v must be at least 1ms", service, name)
+		}
+	}
+
+	if hc.Retries < 0 {
+		return fmt.Errorf("Service: %v\nHealthcheck retries must not be negative", service)
+	}
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Filename of the base manifest, overrides for a runtime environment are named box.<env>.yml
+const BaseFilename = "box.yml"
+
+const includeKey = "include"
+
+// Tag which removes a key inherited from an earlier layer, rather than overriding it
+const resetTag = "!reset"
+
+// Keys by which lists of mappings are merged, the first key present in every item is used
+var mergeKeys = []string{"id", "name", "pattern"}
+
+// LayerFilenames returns the manifest files which make up the manifest for the runtime environment in the
+// directory, base first.  The environment override is only included if it exists.
+func LayerFilenames(dir, env string) []string {
+	filenames := []string{filepath.Join(dir, BaseFilename)}
+
+	override := filepath.Join(dir, fmt.Sprintf("box.%v.yml", env))
+	if _, err := os.Stat(override); err == nil {
+		filenames = append(filenames, override)
+	}
+
+	return filenames
+}
+
+// layerLoader loads manifest layers and their includes, keeping track of the file each node came from
+type layerLoader struct {
+	origins map[*yaml.Node]string
+	loading map[string]bool
+	hash    hash.Hash
+}
+
+func newLayerLoader() *layerLoader {
+	return &layerLoader{
+		origins: map[*yaml.Node]string{},
+		loading: map[string]bool{},
+		hash:    sha256.New(),
+	}
+}
+
+// recordOrigin marks every node in the tree as having come from the file
+func (ld *layerLoader) recordOrigin(node *yaml.Node, filename string) {
+	ld.origins[node] = filename
+	for _, child := range node.Content {
+		ld.recordOrigin(child, filename)
+	}
+}
+
+// loadFile loads a single manifest file, with any files it includes merged underneath it
+func (ld *layerLoader) loadFile(filename string) (*yaml.Node, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if ld.loading[filename] {
+		return nil, fmt.Errorf("Manifest %v includes itself", filename)
+	}
+	ld.loading[filename] = true
+	defer delete(ld.loading, filename)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if _, ok := err.(*os.PathError); ok {
+			return nil, fmt.Errorf("Unable to locate manifest at %v", filename)
+		}
+		return nil, err
+	}
+	fmt.Fprintf(ld.hash, "%v\x00", len(data))
+	ld.hash.Write(data)
+
+	doc := yaml.Node{}
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to process YAML in %v: %w", filename, err)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("Manifest %v must be a mapping at the top level", filename)
+	}
+	ld.recordOrigin(root, filename)
+
+	includes := []string{}
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value != includeKey {
+			continue
+		}
+
+		value := root.Content[i+1]
+		if err := value.Decode(&includes); err != nil {
+			var include string
+			if err := value.Decode(&include); err != nil {
+				return nil, fmt.Errorf("Manifest %v: include must be a filename or a list of filenames", filename)
+			}
+			includes = []string{include}
+		}
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+		break
+	}
+
+	// Included files are layered in order underneath the including file
+	var merged *yaml.Node
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		included, err := ld.loadFile(include)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeNodes(merged, included)
+	}
+
+	return mergeNodes(merged, root), nil
+}
+
+// load loads each of the files in order, each one layered over the ones before it
+func (ld *layerLoader) load(filenames []string) (*yaml.Node, error) {
+	var merged *yaml.Node
+	for _, filename := range filenames {
+		layer, err := ld.loadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeNodes(merged, layer)
+	}
+	removeResets(merged)
+
+	return merged, nil
+}
+
+// removeResets removes keys tagged !reset which had nothing to reset
+func removeResets(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		content := []*yaml.Node{}
+		for i := 0; i < len(node.Content); i += 2 {
+			if node.Content[i+1].Tag != resetTag {
+				content = append(content, node.Content[i], node.Content[i+1])
+			}
+		}
+		node.Content = content
+	}
+
+	for _, child := range node.Content {
+		removeResets(child)
+	}
+}
+
+// mappingValue returns the value of the key in a mapping node, or nil if it isn't present
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// mappingKey returns the key node in a mapping node, or nil if it isn't present
+func mappingKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+
+	return nil
+}
+
+// sequenceItem returns the item at the index of a sequence node, or nil if there isn't one
+func sequenceItem(node *yaml.Node, index int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+		return nil
+	}
+
+	return node.Content[index]
+}
+
+// sequenceMergeKey returns the key by which two lists of mappings can be merged, or an empty string if
+// the lists must be replaced instead
+func sequenceMergeKey(base, override *yaml.Node) string {
+	items := append(append([]*yaml.Node{}, base.Content...), override.Content...)
+	for _, key := range mergeKeys {
+		found := true
+		for _, item := range items {
+			if item.Kind != yaml.MappingNode {
+				return ""
+			}
+			if value := mappingValue(item, key); value == nil || value.Kind != yaml.ScalarNode {
+				found = false
+				break
+			}
+		}
+		if found {
+			return key
+		}
+	}
+
+	return ""
+}
+
+// mergeNodes layers override over base.  Mappings are merged key by key, and a key tagged !reset is
+// removed.  Lists of mappings which share an id, name or pattern key are merged item by item, with new
+// items appended.  Anything else in override replaces the value in base.
+func mergeNodes(base, override *yaml.Node) *yaml.Node {
+	if base == nil {
+		return override
+	}
+
+	switch {
+	case base.Kind == yaml.MappingNode && override.Kind == yaml.MappingNode:
+		for i := 0; i < len(override.Content); i += 2 {
+			key, value := override.Content[i], override.Content[i+1]
+
+			found := false
+			for j := 0; j < len(base.Content); j += 2 {
+				if base.Content[j].Value != key.Value {
+					continue
+				}
+				found = true
+				if value.Tag == resetTag {
+					base.Content = append(base.Content[:j], base.Content[j+2:]...)
+				} else {
+					base.Content[j+1] = mergeNodes(base.Content[j+1], value)
+				}
+				break
+			}
+
+			// Resets are kept until all layers are merged, since an include may be layered before the
+			// file it resets a key in
+			if !found {
+				base.Content = append(base.Content, key, value)
+			}
+		}
+		return base
+
+	case base.Kind == yaml.SequenceNode && override.Kind == yaml.SequenceNode:
+		mergeKey := sequenceMergeKey(base, override)
+		if mergeKey == "" {
+			return override
+		}
+
+		for _, item := range override.Content {
+			id := mappingValue(item, mergeKey).Value
+			found := false
+			for j, baseItem := range base.Content {
+				if mappingValue(baseItem, mergeKey).Value == id {
+					base.Content[j] = mergeNodes(baseItem, item)
+					found = true
+					break
+				}
+			}
+			if !found {
+				base.Content = append(base.Content, item)
+			}
+		}
+		return base
+	}
+
+	return override
+}
+
+// sum returns a hash of every file loaded
+func (ld *layerLoader) sum() string {
+	return fmt.Sprintf("%x", ld.hash.Sum(nil))[:12]
+}
+
+// annotateOrigins comments every value in the tree with the file and line it was defined at
+func (ld *layerLoader) annotateOrigins(node *yaml.Node, baseDir string) {
+	origin := func(n *yaml.Node) string {
+		filename := ld.origins[n]
+		if rel, err := filepath.Rel(baseDir, filename); err == nil {
+			filename = rel
+		}
+		return fmt.Sprintf("%v:%v", filename, n.Line)
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind == yaml.ScalarNode || len(value.Content) == 0 {
+				value.LineComment = origin(value)
+			} else {
+				key.LineComment = origin(key)
+				ld.annotateOrigins(value, baseDir)
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind == yaml.ScalarNode {
+				item.LineComment = origin(item)
+			} else {
+				ld.annotateOrigins(item, baseDir)
+			}
+		}
+	}
+}
+
+// Render returns the effective manifest, merged from the files in order, with every value commented with
+// the file and line it was defined at.  The result isn't validated.
+func Render(filenames ...string) ([]byte, error) {
+	ld := newLayerLoader()
+	merged, err := ld.load(filenames)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := filepath.Dir(filenames[0])
+	if abs, err := filepath.Abs(baseDir); err == nil {
+		baseDir = abs
+	}
+	// Comments from the source files would be misleading once merged
+	clearComments(merged)
+	ld.annotateOrigins(merged, baseDir)
+
+	out := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(merged); err != nil {
+		return nil, err
+	}
+	encoder.Close()
+
+	return out.Bytes(), nil
+}
+
+// clearComments removes all comments from the tree
+func clearComments(node *yaml.Node) {
+	node.HeadComment = ""
+	node.LineComment = ""
+	node.FootComment = ""
+	for _, child := range node.Content {
+		clearComments(child)
+	}
+}
@@ -0,0 +1,330 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYAML returns the plain value of a YAML document, for comparing merged trees
+func decodeYAML(t *testing.T, node *yaml.Node) interface{} {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+func parseYAML(t *testing.T, content string) *yaml.Node {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	return doc.Content[0]
+}
+
+// writeFiles writes the files by name into a new temporary directory, returning it
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), os.FileMode(0644)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestMergeNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		override string
+		want     string
+	}{
+		{
+			name:     "mappings merged key by key",
+			base:     "a: 1\nb: {c: 2, d: 3}\n",
+			override: "b: {d: 4, e: 5}\nf: 6\n",
+			want:     "a: 1\nb: {c: 2, d: 4, e: 5}\nf: 6\n",
+		},
+		{
+			name:     "scalar replaced",
+			base:     "a: 1\n",
+			override: "a: two\n",
+			want:     "a: two\n",
+		},
+		{
+			name:     "mapping replaced by scalar",
+			base:     "a: {b: 1}\n",
+			override: "a: 2\n",
+			want:     "a: 2\n",
+		},
+		{
+			name:     "list of scalars replaced",
+			base:     "a: [1, 2]\n",
+			override: "a: [3]\n",
+			want:     "a: [3]\n",
+		},
+		{
+			name:     "list merged by name",
+			base:     "a: [{name: x, v: 1}, {name: y, v: 2}]\n",
+			override: "a: [{name: y, v: 3}, {name: z, v: 4}]\n",
+			want:     "a: [{name: x, v: 1}, {name: y, v: 3}, {name: z, v: 4}]\n",
+		},
+		{
+			name:     "list merged by the first key every item has",
+			base:     "a: [{id: 1, name: x}]\n",
+			override: "a: [{id: 1, name: y}]\n",
+			want:     "a: [{id: 1, name: y}]\n",
+		},
+		{
+			name:     "list merged by pattern",
+			base:     "a: [{pattern: /api, port: 80}]\n",
+			override: "a: [{pattern: /api, port: 8080}]\n",
+			want:     "a: [{pattern: /api, port: 8080}]\n",
+		},
+		{
+			name:     "list without a shared key replaced",
+			base:     "a: [{name: x}]\n",
+			override: "a: [{other: y}]\n",
+			want:     "a: [{other: y}]\n",
+		},
+		{
+			name:     "reset removes an inherited key",
+			base:     "a: 1\nb: {c: 2, d: 3}\n",
+			override: "b:\n  c: !reset\n",
+			want:     "a: 1\nb: {d: 3}\n",
+		},
+		{
+			name:     "reset with nothing to reset",
+			base:     "a: 1\n",
+			override: "b: !reset\n",
+			want:     "a: 1\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := mergeNodes(parseYAML(t, test.base), parseYAML(t, test.override))
+			removeResets(merged)
+
+			got := decodeYAML(t, merged)
+			want := decodeYAML(t, parseYAML(t, test.want))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadIncludes(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		load    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "single include underneath the including file",
+			files: map[string]string{
+				"box.yml":    "include: common.yml\na: base\n",
+				"common.yml": "a: common\nb: common\n",
+			},
+			load: []string{"box.yml"},
+			want: "a: base\nb: common\n",
+		},
+		{
+			name: "includes layered in order",
+			files: map[string]string{
+				"box.yml": "include: [one.yml, two.yml]\n",
+				"one.yml": "a: one\nb: one\n",
+				"two.yml": "b: two\n",
+			},
+			load: []string{"box.yml"},
+			want: "a: one\nb: two\n",
+		},
+		{
+			name: "override layered over the base",
+			files: map[string]string{
+				"box.yml":      "a: base\nb: {c: 1, d: 2}\n",
+				"box.prod.yml": "b:\n  d: !reset\n",
+			},
+			load: []string{"box.yml", "box.prod.yml"},
+			want: "a: base\nb: {c: 1}\n",
+		},
+		{
+			name: "override resets a key from an include",
+			files: map[string]string{
+				"box.yml":      "include: common.yml\n",
+				"common.yml":   "a: 1\nb: 2\n",
+				"box.prod.yml": "a: !reset\n",
+			},
+			load: []string{"box.yml", "box.prod.yml"},
+			want: "b: 2\n",
+		},
+		{
+			name: "same file included twice",
+			files: map[string]string{
+				"box.yml":    "include: [one.yml, two.yml]\n",
+				"one.yml":    "include: common.yml\n",
+				"two.yml":    "include: common.yml\n",
+				"common.yml": "a: 1\n",
+			},
+			load: []string{"box.yml"},
+			want: "a: 1\n",
+		},
+		{
+			name:    "includes itself",
+			files:   map[string]string{"box.yml": "include: box.yml\n"},
+			load:    []string{"box.yml"},
+			wantErr: "includes itself",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"box.yml": "include: one.yml\n",
+				"one.yml": "include: two.yml\n",
+				"two.yml": "include: one.yml\n",
+			},
+			load:    []string{"box.yml"},
+			wantErr: "includes itself",
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"box.yml": "include: missing.yml\n"},
+			load:    []string{"box.yml"},
+			wantErr: "Unable to locate manifest",
+		},
+		{
+			name:    "invalid include",
+			files:   map[string]string{"box.yml": "include: {a: 1}\n"},
+			load:    []string{"box.yml"},
+			wantErr: "include must be a filename or a list of filenames",
+		},
+		{
+			name:    "not a mapping",
+			files:   map[string]string{"box.yml": "- a\n"},
+			load:    []string{"box.yml"},
+			wantErr: "must be a mapping at the top level",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			filenames := []string{}
+			for _, name := range test.load {
+				filenames = append(filenames, filepath.Join(dir, name))
+			}
+
+			merged, err := newLayerLoader().load(filenames)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := decodeYAML(t, merged)
+			want := decodeYAML(t, parseYAML(t, test.want))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		load     []string
+		want     []string
+		wantFile string
+		wantLine int
+	}{
+		{
+			name: "typo suggested",
+			files: map[string]string{
+				"box.yml": "project: test\nservices:\n  web:\n    imgae: nginx\n",
+			},
+			load:     []string{"box.yml"},
+			want:     []string{"Unknown field imgae in services.web, did you mean image?"},
+			wantFile: "box.yml",
+			wantLine: 4,
+		},
+		{
+			name: "unknown top level field",
+			files: map[string]string{
+				"box.yml": "project: test\nfrobnicate: true\n",
+			},
+			load:     []string{"box.yml"},
+			want:     []string{"Unknown field frobnicate"},
+			wantFile: "box.yml",
+			wantLine: 2,
+		},
+		{
+			name: "positioned in the included file",
+			files: map[string]string{
+				"box.yml":    "include: common.yml\nproject: test\n",
+				"common.yml": "services:\n  web:\n    image: nginx\n    replica: 2\n",
+			},
+			load:     []string{"box.yml"},
+			want:     []string{"Unknown field replica in services.web, did you mean replicas?"},
+			wantFile: "common.yml",
+			wantLine: 4,
+		},
+		{
+			name: "every error collected",
+			files: map[string]string{
+				"box.yml": "project: test\nservices:\n  web:\n    image: nginx\n    hostnme: web\n    replicas: many\n",
+			},
+			load: []string{"box.yml"},
+			want: []string{
+				"Unknown field hostnme in services.web, did you mean hostname?",
+				"services.web.replicas must be of type int, got many",
+			},
+			wantFile: "box.yml",
+			wantLine: 5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeFiles(t, test.files)
+			filenames := []string{}
+			for _, name := range test.load {
+				filenames = append(filenames, filepath.Join(dir, name))
+			}
+
+			_, err := NewManifest(filenames...)
+			errs, ok := err.(Errors)
+			if !ok {
+				t.Fatalf("expected manifest errors, got %v", err)
+			}
+			if len(errs) != len(test.want) {
+				t.Fatalf("got %v errors, want %v:\n%v", len(errs), len(test.want), errs)
+			}
+			for i, want := range test.want {
+				if errs[i].Message != want {
+					t.Errorf("got %q, want %q", errs[i].Message, want)
+				}
+			}
+			if position := errs[0].Position; filepath.Base(position.Filename) != test.wantFile || position.Line != test.wantLine {
+				t.Errorf("positioned at %v:%v, want %v:%v", position.Filename, position.Line, test.wantFile, test.wantLine)
+			}
+		})
+	}
+}
@@ -2,40 +2,60 @@ package manifest
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
-
-	"gopkg.in/yaml.v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
+	"gopkg.in/yaml.v3"
 )
 
 type Build struct {
-	Dockerfile string `yaml:"dockerfile"`
-	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile,omitempty"`
+	Context    string `yaml:"context,omitempty"`
 }
 
 type Path struct {
-	Pattern string `yaml:"pattern"`
-	Type    string `yaml:"type"`
+	Pattern string `yaml:"pattern,omitempty"`
+	Type    string `yaml:"type,omitempty"`
 }
 
 type Routing struct {
-	Path Path `yaml:"path"`
-	Port int  `yaml:"port"`
+	Path Path `yaml:"path,omitempty"`
+	Port int  `yaml:"port,omitempty"`
 }
 
 type Manifest struct {
-	Project    string              `yaml:"project"`
-	Services   map[string]*Service `yaml:"services"`
-	RuntimeEnv string              `yaml:"runtime_env"`
+	Project      string              `yaml:"project,omitempty"`
+	Services     map[string]*Service `yaml:"services,omitempty"`
+	Volumes      map[string]*Volume  `yaml:"volumes,omitempty"`
+	RuntimeEnv   EnvSelector         `yaml:"runtime_env,omitempty"`
+	StaticRoutes StaticRoutes        `yaml:"static_routes,omitempty"`
+	Hash         string              `yaml:"-"`
+}
+
+// sortedKeys returns the keys of a map keyed by name, sorted
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
 var hostnameRe *regexp.Regexp = regexp.MustCompile("^([a-z]+){3,20}$")
-var portsMappingRe *regexp.Regexp = regexp.MustCompile("^\\d+:\\d+$")
 
-// Trivial rejector for non bind mount style volumes
-var volumeMappingRe *regexp.Regexp = regexp.MustCompile("^[/.@][^:]*:/[^:]*$")
+var buildArgRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+var buildTargetRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]*$")
+var platformRe *regexp.Regexp = regexp.MustCompile("^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$")
 
 func validateHostname(service, hostname string) error {
 	if hostname == "" {
@@ -53,32 +73,11 @@ func validateHostname(service, hostname string) error {
 	return nil
 }
 
-func validatePort(service, port string) error {
-	if !portsMappingRe.Match([]byte(port)) {
-		return fmt.Errorf(
-			"Service: %v\nPort mapping %v is incorrect, mappings must be in the form of <host_port>:<container_port>",
-			service,
-			port,
-		)
-	}
-
-	return nil
-}
-
-func validateVolume(service, volume string) error {
-	if !volumeMappingRe.Match([]byte(volume)) {
-		return fmt.Errorf(
-			"Service: %v\nVolume \"%v\" is invalid.  Only bind mount volumes are supported.  Eg: /var/log/mylogs:/var/log/something",
-			service,
-			volume,
-		)
-	}
-
-	return nil
-}
-
 func validateBuildInfo(service string, bi *BuildInfo) error {
 	if bi.Context == "" && bi.Dockerfile == "" {
+		if len(bi.Args) > 0 || bi.Target != "" || len(bi.CacheFrom) > 0 || len(bi.Secrets) > 0 || bi.Platform != "" {
+			return fmt.Errorf("Service: %v\nBuild options require a build context", service)
+		}
 		return nil
 	}
 
@@ -113,9 +112,137 @@ func validateBuildInfo(service string, bi *BuildInfo) error {
 		)
 	}
 
+	for key := range bi.Args {
+		if !buildArgRe.Match([]byte(key)) {
+			return fmt.Errorf("Service: %v\nBuild arg \"%v\" is not a valid name", service, key)
+		}
+	}
+
+	if bi.Target != "" && !buildTargetRe.Match([]byte(bi.Target)) {
+		return fmt.Errorf("Service: %v\nBuild target \"%v\" is not a valid stage name", service, bi.Target)
+	}
+
+	for _, image := range bi.CacheFrom {
+		if image == "" {
+			return fmt.Errorf("Service: %v\nBuild cache_from entries must not be empty", service)
+		}
+	}
+
+	if bi.Platform != "" && !platformRe.Match([]byte(bi.Platform)) {
+		return fmt.Errorf(
+			"Service: %v\nBuild platform \"%v\" is invalid, platforms must be in the form of <os>/<arch>[/<variant>]. Eg: linux/amd64",
+			service,
+			bi.Platform,
+		)
+	}
+
+	secretIDs := map[string]bool{}
+	for _, secret := range bi.Secrets {
+		if secret.ID == "" {
+			return fmt.Errorf("Service: %v\nBuild secrets must specify an id", service)
+		}
+		if secretIDs[secret.ID] {
+			return fmt.Errorf("Service: %v\nBuild secret \"%v\" is declared more than once", service, secret.ID)
+		}
+		secretIDs[secret.ID] = true
+
+		if (secret.Src == "") == (secret.Env == "") {
+			return fmt.Errorf("Service: %v\nBuild secret \"%v\" must specify exactly one of src or env", service, secret.ID)
+		}
+
+		if secret.Src != "" {
+			srcPath, err := secret.GetSrcPath(dir)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(srcPath); err != nil {
+				return fmt.Errorf("Service: %v\nUnable to locate build secret \"%v\": %w", service, secret.ID, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// Restart policies supported by the docker engine
+const (
+	RestartNo            = "no"
+	RestartAlways        = "always"
+	RestartOnFailure     = "on-failure"
+	RestartUnlessStopped = "unless-stopped"
+)
+
+// The engine refuses memory limits lower than this
+const minMemoryBytes = 6 * 1024 * 1024
+
+// parseRestartPolicy parses a restart policy in the form of no, always, unless-stopped or on-failure[:max-retries]
+func parseRestartPolicy(policy string) (container.RestartPolicy, error) {
+	parts := strings.SplitN(policy, ":", 2)
+	restartPolicy := container.RestartPolicy{Name: parts[0]}
+
+	switch parts[0] {
+	case RestartNo, RestartAlways, RestartUnlessStopped:
+		if len(parts) > 1 {
+			return restartPolicy, fmt.Errorf("Restart policy %v does not accept a retry count", parts[0])
+		}
+	case RestartOnFailure:
+		if len(parts) > 1 {
+			retries, err := strconv.Atoi(parts[1])
+			if err != nil || retries < 0 {
+				return restartPolicy, fmt.Errorf("Restart policy retry count \"%v\" must be a non-negative integer", parts[1])
+			}
+			restartPolicy.MaximumRetryCount = retries
+		}
+	default:
+		return restartPolicy, fmt.Errorf(
+			"Restart policy \"%v\" is invalid, must be one of %v, %v, %v or %v[:<max_retries>]",
+			policy,
+			RestartNo,
+			RestartAlways,
+			RestartUnlessStopped,
+			RestartOnFailure,
+		)
+	}
+
+	return restartPolicy, nil
+}
+
+// validateResources returns the first invalid resource setting, along with the key of the field it's set by
+func validateResources(service string, svc *Service) (string, error) {
+	if svc.Memory != "" {
+		memory, err := units.RAMInBytes(svc.Memory)
+		if err != nil {
+			return "memory", fmt.Errorf("Service: %v\nMemory limit \"%v\" is invalid.  Eg: 512m, 1g", service, svc.Memory)
+		}
+		if memory < minMemoryBytes {
+			return "memory", fmt.Errorf("Service: %v\nMemory limit must be at least 6m", service)
+		}
+	}
+
+	if svc.CPUs < 0 {
+		return "cpus", fmt.Errorf("Service: %v\nCPU limit must not be negative", service)
+	}
+
+	if svc.Restart != "" {
+		if _, err := parseRestartPolicy(svc.Restart); err != nil {
+			return "restart", fmt.Errorf("Service: %v\n%w", service, err)
+		}
+	}
+
+	if svc.StopGracePeriod != "" {
+		d, err := time.ParseDuration(svc.StopGracePeriod)
+		if err != nil || d < 0 {
+			return "stop_grace_period", fmt.Errorf("Service: %v\nStop grace period \"%v\" is not a valid duration.  Eg: 30s", service, svc.StopGracePeriod)
+		}
+	}
+
+	if svc.WorkingDir != "" && !strings.HasPrefix(svc.WorkingDir, "/") {
+		return "working_dir", fmt.Errorf("Service: %v\nWorking directory \"%v\" must be an absolute path", service, svc.WorkingDir)
+	}
+
+	return "", nil
+}
+
 func validateImage(serviceName, image string) error {
 	if image == "" {
 		return fmt.Errorf("Service: %v\nMust specify an image name", serviceName)
@@ -124,58 +251,144 @@ func validateImage(serviceName, image string) error {
 	return nil
 }
 
-// NewManifest loads, validates, and returns a pointer to the Manifest structure.  Any failure in
-// loading, parsing, or validating will result in an error.
-func NewManifest(filename string) (*Manifest, error) {
-	data, err := ioutil.ReadFile(filename)
+// NewManifest loads, validates, and returns a pointer to the Manifest structure.  Each file is layered
+// over the ones before it, see LayerFilenames.  Any failure in loading, parsing, or validating will
+// result in an error.  Problems with the manifest's contents are all collected, and returned as Errors.
+func NewManifest(filenames ...string) (*Manifest, error) {
+	ld := newLayerLoader()
+	merged, err := ld.load(filenames)
 	if err != nil {
-		if _, ok := err.(*os.PathError); ok {
-			return nil, fmt.Errorf("Unable to locate manifest at %v", filename)
-		}
 		return nil, err
 	}
 
+	// Catch unknown fields and values of the wrong type before decoding, which would skip the former and
+	// stop at the first of the latter
+	if errs := ld.checkNode(merged, reflect.TypeOf(Manifest{}), ""); len(errs) > 0 {
+		return nil, errs
+	}
+
 	mfst := Manifest{}
-	err = yaml.Unmarshal(data, &mfst)
+	err = merged.Decode(&mfst)
 	if err != nil {
-		return nil, fmt.Errorf("Unable to process YAML in %v", filename)
+		return nil, fmt.Errorf("Unable to process manifest YAML: %w", err)
+	}
+	mfst.Hash = ld.sum()
+
+	errs := Errors{}
+	// nodeError records a failed validation at the position of the node, or of the manifest without one
+	nodeError := func(node *yaml.Node, err error) {
+		if err == nil {
+			return
+		}
+		if node == nil {
+			node = merged
+		}
+		errs = append(errs, &Error{Position: ld.position(node), Message: err.Error()})
+	}
+
+	if mfst.Project == "" {
+		nodeError(nil, fmt.Errorf("A project name is required"))
+	}
+
+	nodeError(mappingKey(merged, "runtime_env"), validateEnvSelector(mfst.RuntimeEnv))
+
+	// Validate volumes
+	volumesNode := mappingValue(merged, "volumes")
+	for _, volumeName := range sortedKeys(mfst.Volumes) {
+		nodeError(mappingKey(volumesNode, volumeName), validateVolumeName(volumeName))
+		if mfst.Volumes[volumeName] == nil {
+			mfst.Volumes[volumeName] = &Volume{}
+		}
 	}
 
 	// Validate services
-	for serviceName, service := range mfst.Services {
-		// Validate hostname
-		if err := validateHostname(serviceName, service.Hostname); err != nil {
-			return nil, err
+	servicesNode := mappingValue(merged, "services")
+	for _, serviceName := range sortedKeys(mfst.Services) {
+		service := mfst.Services[serviceName]
+		service.Name = serviceName
+
+		// Errors are positioned at the field or list item they concern, or at the service when it's absent
+		serviceKey := mappingKey(servicesNode, serviceName)
+		serviceNode := mappingValue(servicesNode, serviceName)
+		field := func(key string) *yaml.Node {
+			if node := mappingKey(serviceNode, key); node != nil {
+				return node
+			}
+			return serviceKey
+		}
+		item := func(key string, index int) *yaml.Node {
+			if node := sequenceItem(mappingValue(serviceNode, key), index); node != nil {
+				return node
+			}
+			return field(key)
 		}
+		// entry finds a value of a field which may be either a mapping or a list
+		entry := func(key, name string) *yaml.Node {
+			value := mappingValue(serviceNode, key)
+			if node := mappingKey(value, name); node != nil {
+				return node
+			}
+			if value != nil && value.Kind == yaml.SequenceNode {
+				for _, node := range value.Content {
+					if node.Value == name || strings.HasPrefix(node.Value, name+"=") {
+						return node
+					}
+				}
+			}
+			return field(key)
+		}
+
+		nodeError(field("hostname"), validateHostname(serviceName, service.Hostname))
 
 		if service.Hostname != "" && (service.Routing.Path.Pattern != "" ||
 			service.Routing.Port != 0) {
-			return nil, fmt.Errorf("Service %v - Cannot specify a hostname and a routing configuration, since routings rely on dynamically assigned hostnames", serviceName)
+			nodeError(field("hostname"), fmt.Errorf("Service %v - Cannot specify a hostname and a routing configuration, since routings rely on dynamically assigned hostnames", serviceName))
 		}
 
-		for _, port := range service.Ports {
-			if err = validatePort(serviceName, port); err != nil {
-				return nil, err
-			}
+		if err := validateEnvSelector(service.RuntimeEnv); err != nil {
+			nodeError(field("runtime_env"), fmt.Errorf("Service: %v\n%w", serviceName, err))
+		}
+
+		nodeError(field("routing"), validateRouting(serviceName, &service.Routing))
+
+		for _, key := range sortedKeys(service.Environment) {
+			nodeError(entry("environment", key), validateEnvironmentValue(serviceName, key, service.Environment[key]))
 		}
 
-		for _, volume := range service.Volumes {
-			if err = validateVolume(serviceName, volume); err != nil {
-				return nil, err
+		portsValid := true
+		for i, port := range service.Ports {
+			if err := validatePort(serviceName, port); err != nil {
+				nodeError(item("ports", i), err)
+				portsValid = false
 			}
 		}
 
-		err = validateBuildInfo(serviceName, &service.Build)
-		if err != nil {
-			return nil, err
+		for i, volume := range service.Volumes {
+			nodeError(item("volumes", i), validateVolume(serviceName, volume, mfst.Volumes))
 		}
 
-		err = validateImage(serviceName, service.Image)
-		if err != nil {
-			return nil, err
+		for i, tmpfs := range service.Tmpfs {
+			nodeError(item("tmpfs", i), validateTmpfs(serviceName, tmpfs))
 		}
 
-		service.Name = serviceName
+		nodeError(field("build"), validateBuildInfo(serviceName, &service.Build))
+		nodeError(field("image"), validateImage(serviceName, service.Image))
+		resourceKey, err := validateResources(serviceName, service)
+		nodeError(field(resourceKey), err)
+		nodeError(field("healthcheck"), validateHealthCheck(serviceName, service.HealthCheck))
+
+		for _, name := range service.DependsOn.Names() {
+			nodeError(entry("depends_on", name), validateDependency(serviceName, name, service.DependsOn[name], mfst.Services))
+		}
+
+		// Relies on the ports being valid
+		if portsValid {
+			nodeError(field("replicas"), service.validateManifestReplicas())
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return &mfst, nil
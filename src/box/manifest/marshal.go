@@ -0,0 +1,20 @@
+package manifest
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal returns the manifest as YAML, leaving out any unset fields
+func (mfst *Manifest) Marshal() ([]byte, error) {
+	out := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(mfst); err != nil {
+		return nil, err
+	}
+	encoder.Close()
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,136 @@
+package manifest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/docker/go-connections/nat"
+)
+
+// Host IP to which ports are bound when the mapping doesn't specify one
+const defaultHostIP = "127.0.0.1"
+
+// PublicPort is a host port, or range of host ports, published on a non-loopback interface
+type PublicPort struct {
+	Protocol string
+	Ports    string
+}
+
+// parsePort parses a port mapping in the form of [host_ip:][host_port[-range]:]container_port[-range][/protocol].
+// Unless a host IP is given, ports are bound to localhost only.
+func parsePort(spec string) ([]nat.PortMapping, error) {
+	mappings, err := nat.ParsePortSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range mappings {
+		proto := mappings[i].Port.Proto()
+		if proto != "tcp" && proto != "udp" {
+			return nil, fmt.Errorf("Protocol %v is not supported, only tcp and udp are", proto)
+		}
+
+		if mappings[i].Binding.HostIP == "" {
+			// We don't bind to all interfaces unless asked to, since other outside connections
+			// should be tunneled through port 22
+			mappings[i].Binding.HostIP = defaultHostIP
+		}
+	}
+
+	return mappings, nil
+}
+
+// isPublicBinding returns true if the host IP isn't a loopback address
+func isPublicBinding(hostIP string) bool {
+	ip := net.ParseIP(hostIP)
+	return ip != nil && !ip.IsLoopback()
+}
+
+func validatePort(service, port string) error {
+	if _, err := parsePort(port); err != nil {
+		return fmt.Errorf(
+			"Service: %v\nPort mapping %v is incorrect (%v), mappings must be in the form of [<host_ip>:][<host_port>:]<container_port>[/<protocol>].  Eg: 8080:80, 0.0.0.0:3478:3478/udp, 5000-5010:5000-5010",
+			service,
+			port,
+			err,
+		)
+	}
+
+	return nil
+}
+
+func (svc *Service) GetContainerPortSet() nat.PortSet {
+	portSet := nat.PortSet{}
+
+	for _, portStr := range svc.Ports {
+		// Already validated
+		mappings, _ := parsePort(portStr)
+		for _, mapping := range mappings {
+			portSet[mapping.Port] = struct{}{}
+		}
+	}
+
+	return portSet
+}
+
+func (svc *Service) GetHostPortMap() nat.PortMap {
+	portMap := nat.PortMap{}
+
+	for _, portStr := range svc.Ports {
+		// Already validated
+		mappings, _ := parsePort(portStr)
+		for _, mapping := range mappings {
+			portMap[mapping.Port] = append(portMap[mapping.Port], mapping.Binding)
+		}
+	}
+
+	return portMap
+}
+
+// GetPublicPorts returns the host ports which the service publishes on non-loopback interfaces, and must
+// therefore be reachable from outside the host.  Dynamically allocated host ports are not included.
+func (svc *Service) GetPublicPorts() []PublicPort {
+	publicPorts := []PublicPort{}
+
+	for _, portStr := range svc.Ports {
+		// Already validated
+		mappings, _ := parsePort(portStr)
+		if len(mappings) == 0 || !isPublicBinding(mappings[0].Binding.HostIP) {
+			continue
+		}
+
+		first := mappings[0].Binding.HostPort
+		last := mappings[len(mappings)-1].Binding.HostPort
+		var ports string
+		switch {
+		case first == "":
+			continue
+		case strings.Contains(first, "-") || first == last:
+			ports = first
+		default:
+			ports = fmt.Sprintf("%v-%v", first, last)
+		}
+
+		publicPorts = append(publicPorts, PublicPort{
+			Protocol: mappings[0].Port.Proto(),
+			Ports:    ports,
+		})
+	}
+
+	return publicPorts
+}
+
+// GetPublicPorts returns the public ports of the services in the manifest which run in the environment with the
+// active profiles
+func (mfst *Manifest) GetPublicPorts(env string, profiles []string) []PublicPort {
+	publicPorts := []PublicPort{}
+	for _, service := range mfst.Services {
+		if mfst.ExcludedReason(service, env, profiles) != "" {
+			continue
+		}
+		publicPorts = append(publicPorts, service.GetPublicPorts()...)
+	}
+
+	return publicPorts
+}
@@ -0,0 +1,61 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Routing path match types
+const (
+	PathTypePrefix = "prefix"
+	PathTypeExact  = "exact"
+	PathTypeRegex  = "regex"
+)
+
+// StaticPath maps requests matching the pattern to a location under the webroot
+type StaticPath struct {
+	Pattern  string `yaml:"pattern,omitempty"`
+	Type     string `yaml:"type,omitempty"`
+	Location string `yaml:"location,omitempty"`
+}
+
+// StaticRoutes are paths served by the router from files under the webroot, rather than by a service
+type StaticRoutes struct {
+	Webroot string       `yaml:"webroot,omitempty"`
+	Paths   []StaticPath `yaml:"paths,omitempty"`
+}
+
+func validateRouting(service string, routing *Routing) error {
+	if routing.Path.Pattern == "" {
+		if routing.Port != 0 || routing.Path.Type != "" {
+			return fmt.Errorf("Service: %v\nA routing requires a path pattern", service)
+		}
+		return nil
+	}
+
+	switch routing.Path.Type {
+	case PathTypePrefix, PathTypeExact:
+		if routing.Path.Pattern[0] != '/' {
+			return fmt.Errorf("Service: %v\nRouting pattern \"%v\" must begin with /", service, routing.Path.Pattern)
+		}
+	case PathTypeRegex:
+		if _, err := regexp.Compile(routing.Path.Pattern); err != nil {
+			return fmt.Errorf("Service: %v\nRouting pattern \"%v\" is not a valid regular expression: %w", service, routing.Path.Pattern, err)
+		}
+	default:
+		return fmt.Errorf(
+			"Service: %v\nRouting path type \"%v\" is invalid, must be one of %v, %v or %v",
+			service,
+			routing.Path.Type,
+			PathTypePrefix,
+			PathTypeExact,
+			PathTypeRegex,
+		)
+	}
+
+	if routing.Port < 1 || routing.Port > 65535 {
+		return fmt.Errorf("Service: %v\nRouting port must be the port the service listens on inside the container", service)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Runtime environments, any other runtime_env name is a profile
+const (
+	EnvDev  = "dev"
+	EnvProd = "prod"
+)
+
+var profileNameRe *regexp.Regexp = regexp.MustCompile("^[a-z][a-z0-9_-]{0,31}$")
+
+// EnvSelector is the set of runtime environments and profiles in which a service runs.  In YAML it may be
+// expressed either as a single name or as a list of names.  An empty selector matches everywhere.
+type EnvSelector []string
+
+// UnmarshalYAML accepts both the list and single name forms of a selector
+func (sel *EnvSelector) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	names := []string{}
+	if err := unmarshal(&names); err == nil {
+		*sel = names
+		return nil
+	}
+
+	var name string
+	if err := unmarshal(&name); err != nil {
+		return err
+	}
+	*sel = EnvSelector{name}
+
+	return nil
+}
+
+// Matches returns true if the selector includes the environment or any of the active profiles
+func (sel EnvSelector) Matches(env string, profiles []string) bool {
+	if len(sel) == 0 {
+		return true
+	}
+
+	for _, name := range sel {
+		if name == env {
+			return true
+		}
+		for _, profile := range profiles {
+			if name == profile {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (sel EnvSelector) String() string {
+	return strings.Join(sel, ", ")
+}
+
+func validateEnvSelector(sel EnvSelector) error {
+	for _, name := range sel {
+		if !profileNameRe.Match([]byte(name)) {
+			return fmt.Errorf(
+				"runtime_env \"%v\" is invalid, must be %v, %v or a profile name consisting of lowercase letters, digits, - and _",
+				name,
+				EnvDev,
+				EnvProd,
+			)
+		}
+	}
+
+	return nil
+}
+
+// GetEnvSelector returns the runtime environments and profiles of the service, falling back to the
+// manifest's default
+func (mfst *Manifest) GetEnvSelector(service *Service) EnvSelector {
+	if len(service.RuntimeEnv) > 0 {
+		return service.RuntimeEnv
+	}
+
+	return mfst.RuntimeEnv
+}
+
+// Profiles returns the names of all profiles selected by services in the manifest, sorted
+func (mfst *Manifest) Profiles() []string {
+	seen := map[string]bool{}
+	profiles := []string{}
+	for _, service := range mfst.Services {
+		for _, name := range mfst.GetEnvSelector(service) {
+			if name != EnvDev && name != EnvProd && seen[name] == false {
+				seen[name] = true
+				profiles = append(profiles, name)
+			}
+		}
+	}
+	sort.Strings(profiles)
+
+	return profiles
+}
+
+// ExcludedReason returns why the service doesn't run in the environment with the active profiles, or an
+// empty string if it does run
+func (mfst *Manifest) ExcludedReason(service *Service, env string, profiles []string) string {
+	sel := mfst.GetEnvSelector(service)
+	if sel.Matches(env, profiles) {
+		return ""
+	}
+
+	active := env
+	if len(profiles) > 0 {
+		active = fmt.Sprintf("%v with profiles %v", env, strings.Join(profiles, ", "))
+	}
+
+	return fmt.Sprintf("runtime_env [%v] excludes %v", sel, active)
+}
@@ -0,0 +1,119 @@
+package manifest
+
+//go:generate go run ../tools/schemagen ../../../schema/box.schema.json
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const schemaID = "https://raw.githubusercontent.com/hashibuto/box.do/master/schema/box.schema.json"
+
+// Values accepted by string fields which are limited to a fixed set, by "Type.field"
+var schemaEnums = map[string][]string{
+	"Path.type":       {PathTypePrefix, PathTypeExact, PathTypeRegex},
+	"StaticPath.type": {PathTypePrefix, PathTypeExact, PathTypeRegex},
+	"Service.restart": {RestartNo, RestartAlways, RestartOnFailure, RestartUnlessStopped},
+	"Dependency.condition": {
+		ConditionServiceStarted,
+		ConditionServiceHealthy,
+		ConditionServiceCompletedSuccessfully,
+	},
+}
+
+// Bounds of integer fields which are limited to a range, by "Type.field"
+var schemaRanges = map[string][2]int{
+	"Service.replicas": {1, MaxReplicas},
+}
+
+type schemaObject map[string]interface{}
+
+func stringOrListSchema() schemaObject {
+	return schemaObject{
+		"oneOf": []schemaObject{
+			{"type": "string"},
+			{"type": "array", "items": schemaObject{"type": "string"}},
+		},
+	}
+}
+
+// typeSchema returns the JSON Schema for values decoded into the type
+func typeSchema(t reflect.Type) schemaObject {
+	switch t {
+	case reflect.TypeOf(Command{}), reflect.TypeOf(EnvSelector{}):
+		return stringOrListSchema()
+	case reflect.TypeOf(Dependencies{}):
+		return schemaObject{
+			"oneOf": []schemaObject{
+				{"type": "array", "items": schemaObject{"type": "string"}},
+				{"type": "object", "additionalProperties": typeSchema(reflect.TypeOf(Dependency{}))},
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaObject{
+			"oneOf": []schemaObject{
+				typeSchema(t.Elem()),
+				{"type": "null"},
+			},
+		}
+	case reflect.Struct:
+		properties := schemaObject{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+
+			property := typeSchema(field.Type)
+			if enum, ok := schemaEnums[t.Name()+"."+name]; ok {
+				property = schemaObject{"type": "string", "enum": enum}
+			}
+			if bounds, ok := schemaRanges[t.Name()+"."+name]; ok {
+				property = schemaObject{"type": "integer", "minimum": bounds[0], "maximum": bounds[1]}
+			}
+			properties[name] = property
+		}
+		return schemaObject{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return schemaObject{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Slice:
+		return schemaObject{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Bool:
+		return schemaObject{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint16:
+		return schemaObject{"type": "integer"}
+	case reflect.Float64:
+		return schemaObject{"type": "number"}
+	}
+
+	// Unquoted numbers and booleans are accepted as strings
+	return schemaObject{"type": []string{"string", "number", "boolean"}}
+}
+
+// JSONSchema returns a JSON Schema describing a manifest file, generated from the manifest structures
+func JSONSchema() ([]byte, error) {
+	schema := typeSchema(reflect.TypeOf(Manifest{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["$id"] = schemaID
+	schema["title"] = "box.do manifest"
+
+	// Any manifest file may include others
+	properties := schema["properties"].(schemaObject)
+	properties[includeKey] = stringOrListSchema()
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
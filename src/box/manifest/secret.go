@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An environment value of secret:NAME is replaced by the project secret NAME when the container is created
+const SecretRefPrefix = "secret:"
+
+var secretNameRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// SecretRef returns the name of the secret the environment value refers to, or an empty string if it
+// isn't a reference
+func SecretRef(value string) string {
+	if !strings.HasPrefix(value, SecretRefPrefix) {
+		return ""
+	}
+
+	return value[len(SecretRefPrefix):]
+}
+
+func validateEnvironmentValue(service, key, value string) error {
+	if name := SecretRef(value); name != "" || value == SecretRefPrefix {
+		if !secretNameRe.Match([]byte(name)) {
+			return fmt.Errorf("Service: %v\nEnvironment %v refers to secret \"%v\", secret names must contain only alphanumerics and underscores, and must not begin with a digit", service, key, name)
+		}
+	}
+
+	return nil
+}
+
+// GetSecrets returns the names of the secrets referred to by the service's environment, sorted
+func (svc *Service) GetSecrets() []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, value := range svc.Environment {
+		if name := SecretRef(value); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -2,32 +2,90 @@ package manifest
 
 import (
 	"fmt"
+	"math"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/docker/docker/api/types/mount"
-	"github.com/docker/go-connections/nat"
+	"github.com/docker/docker/api/types/container"
+	units "github.com/docker/go-units"
 )
 
 const LocalImagePrefix = "@/"
 
+// BuildSecret exposes a file or environment variable to the build, without it being stored in the image
+type BuildSecret struct {
+	ID  string `yaml:"id,omitempty"`
+	Src string `yaml:"src,omitempty"`
+	Env string `yaml:"env,omitempty"`
+}
+
 type BuildInfo struct {
-	Context    string `yaml:"context"`
-	Dockerfile string `yaml:"dockerfile"`
+	Context    string            `yaml:"context,omitempty"`
+	Dockerfile string            `yaml:"dockerfile,omitempty"`
+	Args       map[string]string `yaml:"args,omitempty"`
+	Target     string            `yaml:"target,omitempty"`
+	CacheFrom  []string          `yaml:"cache_from,omitempty"`
+	Secrets    []BuildSecret     `yaml:"secrets,omitempty"`
+	Platform   string            `yaml:"platform,omitempty"`
+}
+
+// GetSrcPath returns the absolute path of the file the secret is read from, relative to the project directory dir
+func (secret *BuildSecret) GetSrcPath(dir string) (string, error) {
+	srcPath := secret.Src
+	if strings.HasPrefix(srcPath, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("manifest.GetSrcPath: %w", err)
+		}
+		srcPath = filepath.Join(homeDir, srcPath[2:])
+	}
+	if !filepath.IsAbs(srcPath) {
+		srcPath = filepath.Join(dir, srcPath)
+	}
+
+	return srcPath, nil
+}
+
+// GetArgs returns the build args in the form expected by the docker engine
+func (bi *BuildInfo) GetArgs() map[string]*string {
+	args := map[string]*string{}
+	for key, value := range bi.Args {
+		value := value
+		args[key] = &value
+	}
+
+	return args
 }
 
 type Service struct {
-	Name        string
-	Hostname    string            `yaml:"hostname"`
-	Routing     Routing           `yaml:"routing"`
-	Environment map[string]string `yaml:"environment"`
-	Volumes     []string          `yaml:"volumes"`
-	Image       string            `yaml:"image"`
-	DependsOn   []string          `yaml:"depends_on"`
-	Ports       []string          `yaml:"ports"`
-	Build       BuildInfo         `yaml:"build"`
+	Name            string            `yaml:"-"`
+	Hostname        string            `yaml:"hostname,omitempty"`
+	Routing         Routing           `yaml:"routing,omitempty"`
+	Environment     map[string]string `yaml:"environment,omitempty"`
+	Volumes         []string          `yaml:"volumes,omitempty"`
+	Tmpfs           []string          `yaml:"tmpfs,omitempty"`
+	Image           string            `yaml:"image,omitempty"`
+	DependsOn       Dependencies      `yaml:"depends_on,omitempty"`
+	HealthCheck     *HealthCheck      `yaml:"healthcheck,omitempty"`
+	Ports           []string          `yaml:"ports,omitempty"`
+	Build           BuildInfo         `yaml:"build,omitempty"`
+	Memory          string            `yaml:"memory,omitempty"`
+	CPUs            float64           `yaml:"cpus,omitempty"`
+	Restart         string            `yaml:"restart,omitempty"`
+	Command         Command           `yaml:"command,omitempty"`
+	Entrypoint      Command           `yaml:"entrypoint,omitempty"`
+	User            string            `yaml:"user,omitempty"`
+	WorkingDir      string            `yaml:"working_dir,omitempty"`
+	StopGracePeriod string            `yaml:"stop_grace_period,omitempty"`
+	Replicas        *int              `yaml:"replicas,omitempty"`
+	RuntimeEnv      EnvSelector       `yaml:"runtime_env,omitempty"`
 }
 
+// Upper bound on the number of containers run for a single service
+const MaxReplicas = 32
+
 func (svc *Service) GetHostname() string {
 	if svc.Hostname != "" {
 		return svc.Hostname
@@ -36,13 +94,22 @@ func (svc *Service) GetHostname() string {
 	return svc.Name
 }
 
-func (svc *Service) GetEnv() []string {
+// GetEnv returns the environment in the form expected by the docker engine, with each secret reference
+// replaced by the value returned by resolveSecret
+func (svc *Service) GetEnv(resolveSecret func(name string) (string, error)) ([]string, error) {
 	envVars := []string{}
 	for key, value := range svc.Environment {
+		if name := SecretRef(value); name != "" {
+			secret, err := resolveSecret(name)
+			if err != nil {
+				return nil, fmt.Errorf("Service: %v\n%w", svc.Name, err)
+			}
+			value = secret
+		}
 		envVars = append(envVars, fmt.Sprintf("%v=%v", key, value))
 	}
 
-	return envVars
+	return envVars, nil
 }
 
 // GetImage returns the image, replacing any local reference with a unique project identifier
@@ -61,54 +128,84 @@ func (svc *Service) GetImage(uniqueSuffix string) string {
 	return imgStr
 }
 
-func (svc *Service) GetContainerPortSet() nat.PortSet {
-	portSet := nat.PortSet{}
+// GetResources returns the memory and CPU limits for the service container
+func (svc *Service) GetResources() container.Resources {
+	resources := container.Resources{}
+	if svc.Memory != "" {
+		// Already validated
+		resources.Memory, _ = units.RAMInBytes(svc.Memory)
+	}
+	if svc.CPUs > 0 {
+		resources.NanoCPUs = int64(svc.CPUs * 1e9)
+	}
+
+	return resources
+}
 
-	for _, portStr := range svc.Ports {
-		portParts := strings.Split(portStr, ":")
-		containerPort := portParts[1]
-		portSet[nat.Port(fmt.Sprintf("%v/tcp", containerPort))] = struct{}{}
+// GetRestartPolicy returns the restart policy for the service container, falling back to defaultPolicy
+// when the service doesn't specify one
+func (svc *Service) GetRestartPolicy(defaultPolicy string) container.RestartPolicy {
+	policy := svc.Restart
+	if policy == "" {
+		policy = defaultPolicy
 	}
 
-	return portSet
+	// Already validated
+	restartPolicy, _ := parseRestartPolicy(policy)
+	return restartPolicy
 }
 
-func (svc *Service) GetHostPortMap() nat.PortMap {
-	portMap := nat.PortMap{}
-
-	for _, portStr := range svc.Ports {
-		portParts := strings.Split(portStr, ":")
-		hostPort := portParts[0]
-		containerPort := portParts[1]
-		portMap[nat.Port(fmt.Sprintf("%v/tcp", containerPort))] = []nat.PortBinding{
-			{
-				// All bindings are to localhost.  We don't allow binding to
-				// 0.0.0.0 since all additional outside connections should be tunneled through
-				// port 22
-				HostIP:   "127.0.0.1",
-				HostPort: hostPort,
-			},
-		}
+// GetStopTimeout returns the number of seconds the container is given to stop gracefully before being
+// killed, or nil for the engine default.  The engine counts whole seconds, so a part of a second is rounded
+// up rather than leaving no time at all.
+func (svc *Service) GetStopTimeout() *int {
+	if svc.StopGracePeriod == "" {
+		return nil
+	}
+
+	// Already validated
+	d, _ := time.ParseDuration(svc.StopGracePeriod)
+	seconds := int(math.Ceil(d.Seconds()))
+	return &seconds
+}
+
+// GetReplicas returns the number of containers to run for the service
+func (svc *Service) GetReplicas() int {
+	if svc.Replicas == nil {
+		return 1
 	}
 
-	return portMap
+	return *svc.Replicas
 }
 
-func (svc *Service) GetHostMounts(dataDir string) []mount.Mount {
-	mounts := []mount.Mount{}
-	for _, volume := range svc.Volumes {
-		volumeParts := strings.Split(volume, ":")
-		hostPath := volumeParts[0]
-		if strings.HasPrefix(hostPath, LocalImagePrefix) {
-			hostPath = filepath.Join(dataDir, hostPath[len(LocalImagePrefix):])
+// validateManifestReplicas verifies the number of replicas declared in the manifest.  A running service can be
+// scaled down to none, but a service declared with none would leave its dependents waiting on it.
+func (svc *Service) validateManifestReplicas() error {
+	if svc.Replicas != nil && (*svc.Replicas < 1 || *svc.Replicas > MaxReplicas) {
+		return fmt.Errorf("Service: %v\nReplicas must be between 1 and %v, scale a running service down to 0 with box scale, or exclude it with runtime_env", svc.Name, MaxReplicas)
+	}
+
+	return svc.ValidateReplicas(svc.GetReplicas())
+}
+
+// ValidateReplicas verifies that the service can run the provided number of containers.  Replicas can't
+// share a fixed host port, so only services without one can run more than a single container.
+func (svc *Service) ValidateReplicas(replicas int) error {
+	if replicas < 0 || replicas > MaxReplicas {
+		return fmt.Errorf("Service: %v\nReplicas must be between 0 and %v", svc.Name, MaxReplicas)
+	}
+
+	if replicas > 1 {
+		for _, portStr := range svc.Ports {
+			// Already validated
+			mappings, _ := parsePort(portStr)
+			for _, mapping := range mappings {
+				if mapping.Binding.HostPort != "" && !strings.Contains(mapping.Binding.HostPort, "-") {
+					return fmt.Errorf("Service: %v\nPort mapping %v binds a fixed host port, so the service can't run more than one replica", svc.Name, portStr)
+				}
+			}
 		}
-		containerPath := volumeParts[1]
-		mounts = append(mounts, mount.Mount{
-			Type:   mount.TypeBind,
-			Source: hostPath,
-			Target: containerPath,
-		})
 	}
 
-	return mounts
+	return nil
 }
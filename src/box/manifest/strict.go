@@ -0,0 +1,181 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Types which decode themselves, accepting more than one YAML form
+type yamlUnmarshaler interface {
+	UnmarshalYAML(unmarshal func(interface{}) error) error
+}
+
+var yamlUnmarshalerType = reflect.TypeOf((*yamlUnmarshaler)(nil)).Elem()
+
+// position returns where the node was defined
+func (ld *layerLoader) position(node *yaml.Node) Position {
+	return Position{
+		Filename: ld.origins[node],
+		Line:     node.Line,
+		Column:   node.Column,
+	}
+}
+
+// yamlFields returns the fields of a struct type by their YAML key.  Untagged fields aren't set from YAML.
+func yamlFields(t reflect.Type) (map[string]reflect.StructField, []string) {
+	fields := map[string]reflect.StructField{}
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field
+		names = append(names, name)
+	}
+
+	return fields, names
+}
+
+// joinPath appends a key to the path of its parent
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return fmt.Sprintf("%v.%v", path, key)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = current[j-1] + 1
+			if prev[j]+1 < current[j] {
+				current[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < current[j] {
+				current[j] = prev[j-1] + cost
+			}
+		}
+		prev = current
+	}
+
+	return prev[len(b)]
+}
+
+// suggestField returns the known field closest to an unknown one, if any is close enough to be a typo
+func suggestField(unknown string, names []string) string {
+	best := ""
+	bestDistance := 3
+	for _, name := range names {
+		if d := editDistance(unknown, name); d < bestDistance {
+			best = name
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+// checkNode verifies that the node can be decoded into the type, collecting an error for every unknown
+// field and every value of the wrong type.  path describes the location of the node for messages.
+func (ld *layerLoader) checkNode(node *yaml.Node, t reflect.Type, path string) Errors {
+	errs := Errors{}
+	fail := func(n *yaml.Node, format string, args ...interface{}) {
+		errs = append(errs, &Error{Position: ld.position(n), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// An empty value leaves the zero value in place
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return errs
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if reflect.PtrTo(t).Implements(yamlUnmarshalerType) {
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			fail(node, "%v: %v", path, strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+		return errs
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			fail(node, "%v must be a mapping", path)
+			return errs
+		}
+
+		fields, names := yamlFields(t)
+		for i := 0; i < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				location := ""
+				if path != "" {
+					location = " in " + path
+				}
+				if suggestion := suggestField(key.Value, names); suggestion != "" {
+					fail(key, "Unknown field %v%v, did you mean %v?", key.Value, location, suggestion)
+				} else {
+					fail(key, "Unknown field %v%v", key.Value, location)
+				}
+				continue
+			}
+			errs = append(errs, ld.checkNode(value, field.Type, joinPath(path, key.Value))...)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			fail(node, "%v must be a mapping", path)
+			return errs
+		}
+
+		for i := 0; i < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			errs = append(errs, ld.checkNode(value, t.Elem(), joinPath(path, key.Value))...)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			fail(node, "%v must be a list", path)
+			return errs
+		}
+
+		for i, item := range node.Content {
+			errs = append(errs, ld.checkNode(item, t.Elem(), fmt.Sprintf("%v[%v]", path, i))...)
+		}
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			fail(node, "%v must be a single value", path)
+			return errs
+		}
+
+		if err := node.Decode(reflect.New(t).Interface()); err != nil {
+			fail(node, "%v must be of type %v, got %v", path, t.Kind(), node.Value)
+		}
+	}
+
+	return errs
+}
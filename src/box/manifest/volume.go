@@ -0,0 +1,196 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+	units "github.com/docker/go-units"
+)
+
+// Volume declares a named volume shared by the project's services
+type Volume struct {
+	// BlockStorage places the volume on the block storage mount in production, so that its data
+	// survives the droplet being rebuilt
+	BlockStorage bool `yaml:"block_storage,omitempty"`
+}
+
+// VolumeMount is a parsed service volume specification
+type VolumeMount struct {
+	Type     mount.Type
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+var volumeNameRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9_.-]*$")
+
+// ParseVolume parses a volume specification in the form of <source>:<target>[:ro|rw], where source is
+// either a host path (beginning with /, ., ~ or @/) for a bind mount, or the name of a declared volume
+func ParseVolume(spec string) (*VolumeMount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("Volume \"%v\" must be in the form of <source>:<target>[:ro]", spec)
+	}
+
+	vm := &VolumeMount{
+		Source: parts[0],
+		Target: parts[1],
+	}
+
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			vm.ReadOnly = true
+		case "rw":
+		default:
+			return nil, fmt.Errorf("Volume \"%v\" has an invalid mode \"%v\", must be ro or rw", spec, parts[2])
+		}
+	}
+
+	if !strings.HasPrefix(vm.Target, "/") {
+		return nil, fmt.Errorf("Volume \"%v\" target must be an absolute path", spec)
+	}
+
+	switch {
+	case vm.Source == "":
+		return nil, fmt.Errorf("Volume \"%v\" must specify a source", spec)
+	case strings.ContainsAny(vm.Source[:1], "/.~@"):
+		vm.Type = mount.TypeBind
+	case volumeNameRe.Match([]byte(vm.Source)):
+		vm.Type = mount.TypeVolume
+	default:
+		return nil, fmt.Errorf("Volume \"%v\" source must be a host path or a volume name", spec)
+	}
+
+	return vm, nil
+}
+
+// parseTmpfs parses a tmpfs specification in the form of <target>[:size=<size>][,mode=<octal mode>]
+func parseTmpfs(spec string) (*mount.Mount, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	m := &mount.Mount{
+		Type:         mount.TypeTmpfs,
+		Target:       parts[0],
+		TmpfsOptions: &mount.TmpfsOptions{},
+	}
+
+	if !strings.HasPrefix(m.Target, "/") {
+		return nil, fmt.Errorf("Tmpfs \"%v\" target must be an absolute path", spec)
+	}
+
+	if len(parts) == 1 {
+		return m, nil
+	}
+
+	for _, option := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Tmpfs \"%v\" option \"%v\" must be in the form of <key>=<value>", spec, option)
+		}
+
+		switch kv[0] {
+		case "size":
+			size, err := units.RAMInBytes(kv[1])
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("Tmpfs \"%v\" size \"%v\" is invalid.  Eg: 64m", spec, kv[1])
+			}
+			m.TmpfsOptions.SizeBytes = size
+		case "mode":
+			mode, err := strconv.ParseUint(kv[1], 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Tmpfs \"%v\" mode \"%v\" must be octal.  Eg: 1777", spec, kv[1])
+			}
+			m.TmpfsOptions.Mode = os.FileMode(mode)
+		default:
+			return nil, fmt.Errorf("Tmpfs \"%v\" option \"%v\" is not supported, only size and mode are", spec, kv[0])
+		}
+	}
+
+	return m, nil
+}
+
+func validateVolume(service, volume string, volumes map[string]*Volume) error {
+	vm, err := ParseVolume(volume)
+	if err != nil {
+		return fmt.Errorf(
+			"Service: %v\n%w.  Eg: /var/log/mylogs:/var/log/something, or pgdata:/var/lib/postgresql/data",
+			service,
+			err,
+		)
+	}
+
+	if vm.Type == mount.TypeVolume {
+		if _, ok := volumes[vm.Source]; !ok {
+			return fmt.Errorf(
+				"Service: %v\nVolume \"%v\" is not declared in the top level volumes section",
+				service,
+				vm.Source,
+			)
+		}
+	}
+
+	return nil
+}
+
+func validateTmpfs(service, tmpfs string) error {
+	if _, err := parseTmpfs(tmpfs); err != nil {
+		return fmt.Errorf("Service: %v\n%w", service, err)
+	}
+
+	return nil
+}
+
+func validateVolumeName(name string) error {
+	if !volumeNameRe.Match([]byte(name)) {
+		return fmt.Errorf(
+			"Volume name \"%v\" must begin with an alphanumeric character, and contain only alphanumerics, underscores, periods or hyphens",
+			name,
+		)
+	}
+
+	return nil
+}
+
+// GetHostMounts returns the mounts for the service container.  Bind mounts prefixed with @/ are placed
+// under dataDir, and relative paths are resolved against the working directory.  Named volumes are
+// resolved to their engine volume names using volumeName.
+func (svc *Service) GetHostMounts(dataDir string, volumeName func(string) string) []mount.Mount {
+	homeDir, _ := os.UserHomeDir()
+
+	mounts := []mount.Mount{}
+	for _, volume := range svc.Volumes {
+		// Already validated
+		vm, _ := ParseVolume(volume)
+
+		source := vm.Source
+		if vm.Type == mount.TypeVolume {
+			source = volumeName(source)
+		} else if strings.HasPrefix(source, LocalImagePrefix) {
+			source = filepath.Join(dataDir, source[len(LocalImagePrefix):])
+		} else if strings.HasPrefix(source, "~/") {
+			source = filepath.Join(homeDir, source[2:])
+		} else if !filepath.IsAbs(source) {
+			source, _ = filepath.Abs(source)
+		}
+
+		mounts = append(mounts, mount.Mount{
+			Type:     vm.Type,
+			Source:   source,
+			Target:   vm.Target,
+			ReadOnly: vm.ReadOnly,
+		})
+	}
+
+	for _, tmpfs := range svc.Tmpfs {
+		// Already validated
+		m, _ := parseTmpfs(tmpfs)
+		mounts = append(mounts, *m)
+	}
+
+	return mounts
+}
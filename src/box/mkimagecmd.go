@@ -6,22 +6,26 @@ import (
 	"box/api/digitalocean/droplet"
 	dropletenum "box/api/digitalocean/enum/droplet"
 	"box/api/digitalocean/snapshot"
+	"box/api/digitalocean/tag"
 	"box/config"
+	"box/provision"
 	"box/sshconn"
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"strconv"
 	"time"
 )
 
 const imageHostName = "box-image-maker"
-const baseImageName = "box-base"
 const maxConnectAttempts = 10
 const sshRetrySeconds = 10
-const configRepo = "box.do-config"
 const adminUser = "owner"
 
+// Remote directory holding the provisioning scripts while an image is built
+const provisionDir = "/root/box-provision"
+
 type MkImageCmd struct {
 	Name      string `arg help="Project name"`
 	Overwrite bool   `default=false help="Overwrite existing image"`
@@ -42,7 +46,18 @@ func (cmd *MkImageCmd) Run() error {
 		os.Exit(1)
 	}
 
-	doSvc := digitalocean.NewService(cfg.DigitalOceanAPIKey)
+	// Read up front, so that a missing script doesn't leave a droplet behind, or the image deleted
+	steps, err := provision.Steps(cfg.Provision)
+	if err != nil {
+		return err
+	}
+	imageName := provision.ImageName(steps)
+
+	apiToken, err := cfg.APIToken()
+	if err != nil {
+		return err
+	}
+	doSvc := digitalocean.NewService(apiToken)
 
 	if cfg.ImageID != 0 {
 		// Delete the existing image
@@ -63,21 +78,24 @@ func (cmd *MkImageCmd) Run() error {
 		}
 	}
 
-	fmt.Printf("Checking DigitalOcean account for an existing image...")
+	fmt.Printf("Checking DigitalOcean account for an existing image %v...", imageName)
 	snapshots, err := snapshot.GetAllDropletSnapshots(doSvc)
 	if err != nil {
 		return err
 	}
 
 	for _, snapshot := range snapshots {
-		if snapshot.Name == baseImageName {
+		if snapshot.Name == imageName {
 			fmt.Println("Found")
 			cfg.ImageID, err = strconv.Atoi(snapshot.ID)
 			if err != nil {
 				return fmt.Errorf("Unable to convert snapshot ID to int: %w", err)
 			}
 			err = cfg.Save()
-			return err
+			if err != nil {
+				return err
+			}
+			return tagImage(doSvc, cfg)
 		}
 	}
 	fmt.Println("Not found")
@@ -96,6 +114,8 @@ func (cmd *MkImageCmd) Run() error {
 		cfg.Region,
 		droplet.DefaultPublicImage,
 		[]int{cfg.PublicKeyID},
+		nil,
+		"",
 	)
 	if err != nil {
 		return err
@@ -138,7 +158,7 @@ func (cmd *MkImageCmd) Run() error {
 	var conn *sshconn.SSHConn
 	for i := 0; i < maxConnectAttempts; i++ {
 		fmt.Println("Trying to contact via SSH...")
-		conn, err = sshconn.NewSSHConn(signer, "root", ipAddress)
+		conn, err = sshconn.NewSSHConn(signer, "root", ipAddress, "")
 		if err == nil {
 			connected = true
 			break
@@ -155,11 +175,30 @@ func (cmd *MkImageCmd) Run() error {
 	defer conn.Close()
 	fmt.Println("SSH connection established, continuing")
 
-	err = conn.Run([]string{
-		fmt.Sprintf("wget -O /root/config_image.sh https://raw.githubusercontent.com/hashibuto/%v/master/scripts/config_image.sh", configRepo),
-		"chmod +x /root/config_image.sh",
-		fmt.Sprintf("/root/config_image.sh %v %v", adminUser, configRepo),
-	})
+	fmt.Print("Uploading provisioning scripts...")
+	err = conn.Run([]string{fmt.Sprintf("mkdir -p %v", provisionDir)})
+	if err != nil {
+		return err
+	}
+	commands := []string{}
+	for _, step := range steps {
+		filename := path.Join(provisionDir, step.Name)
+		err = conn.WriteFile(filename, step.Script, os.FileMode(0700))
+		if err != nil {
+			return err
+		}
+		commands = append(commands, fmt.Sprintf("ADMIN_USER=%v BOX_PROJECT=%v bash '%v'", adminUser, cfg.ProjectName, filename))
+	}
+	fmt.Println("Done")
+
+	// Powered down once the connection has been let go of
+	commands = append(
+		commands,
+		fmt.Sprintf("echo %v > /etc/box-image", imageName),
+		fmt.Sprintf("rm -rf %v", provisionDir),
+		"(nohup sh -c 'sleep 5; poweroff' >/dev/null 2>&1 &)",
+	)
+	err = conn.Run(commands)
 	if err != nil {
 		return err
 	}
@@ -177,7 +216,7 @@ func (cmd *MkImageCmd) Run() error {
 
 	fmt.Println("Droplet powered down, creating snapshot")
 
-	actionObj, err := droplet.CreateSnapshot(doSvc, dropletObj.ID, baseImageName)
+	actionObj, err := droplet.CreateSnapshot(doSvc, dropletObj.ID, imageName)
 	if err != nil {
 		return err
 	}
@@ -205,6 +244,24 @@ func (cmd *MkImageCmd) Run() error {
 		return err
 	}
 
+	return tagImage(doSvc, cfg)
+}
+
+// tagImage tags the base image with the project, which shares it with any other project using it
+func tagImage(doSvc *digitalocean.Service, cfg *config.Config) error {
+	fmt.Printf("Tagging image with %v...", cfg.Tag())
+	err := tag.Create(doSvc, cfg.Tag())
+	if err != nil {
+		return err
+	}
+	err = tag.TagResources(doSvc, cfg.Tag(), []tag.Resource{
+		{ID: strconv.Itoa(cfg.ImageID), Type: tag.ResourceImage},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
 	return nil
 }
 
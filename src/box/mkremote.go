@@ -1,21 +1,30 @@
 package main
 
 import (
+	"box/access"
 	"box/api/digitalocean"
 	"box/api/digitalocean/action"
 	"box/api/digitalocean/blockstorage"
 	"box/api/digitalocean/domain"
 	"box/api/digitalocean/droplet"
 	"box/api/digitalocean/firewall"
+	"box/api/digitalocean/tag"
 	"box/config"
+	"box/manifest"
+	"box/provision"
+	"box/runtime"
+	"box/secrets"
+	"box/sshconn"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type MakeRemoteCmd struct {
-	Name string `arg help="Project name"`
+	Name    string   `arg help="Project name"`
+	Profile []string `help:"Also open the ports of services selected by this profile, may be repeated"`
 }
 
 var doNameServers []string = []string{
@@ -35,12 +44,54 @@ func (cmd *MakeRemoteCmd) Run() error {
 		return err
 	}
 
+	// Team members' keys are authorized for the admin user by cloud-init, never as the droplet's SSH keys, which
+	// are root's
+	accessStore, err := access.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	// Without an image built by box mkimage, the droplet is provisioned by cloud-init from the stock image
+	var steps []provision.Step
 	if cfg.ImageID == 0 {
-		fmt.Println("Please build the box deployment image first using: box mkimage")
-		os.Exit(1)
+		fmt.Println("No image was built with box mkimage, a new droplet will be provisioned on its first boot instead")
+		steps, err = provision.Steps(cfg.Provision)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Shipped to the droplet still sealed, services' secret references are resolved here when creating its containers
+	secretStore, err := secrets.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+
+	// Needed to tell when provisioning completes and to ship secrets, obtained now to avoid creating resources
+	// should it fail
+	signer, err := sshconn.GetSigner(cfg.PrivateKeyFilename)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if signer.Conn != nil {
+			signer.Conn.Close()
+		}
+	}()
 
-	doSvc := digitalocean.NewService(cfg.DigitalOceanAPIKey)
+	apiToken, err := cfg.APIToken()
+	if err != nil {
+		return err
+	}
+	doSvc := digitalocean.NewService(apiToken)
+
+	// Every resource is tagged, so that the configuration can be rebuilt from them with box adopt
+	fmt.Printf("Creating tag %v...", cfg.Tag())
+	err = tag.Create(doSvc, cfg.Tag())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
 
 	// Check that the bare domain is present in DigitalOcean's network section
 	domainObj, err := domain.Get(doSvc, cfg.BareDomainName)
@@ -70,11 +121,16 @@ func (cmd *MakeRemoteCmd) Run() error {
 		fmt.Println("Domain entry exists for", cfg.BareDomainName)
 	}
 
+	inboundRules, fromManifest, err := projectInboundRules(cfg.ProjectName, cmd.Profile)
+	if err != nil {
+		return err
+	}
+
 	createFirewall := false
 	if cfg.FirewallID != "" {
 		// Verify the presence of the existing firewall, if it doesn't exist, it will be replaced
 		fmt.Print("Verifying existing firewall...")
-		_, err := firewall.Get(doSvc, cfg.FirewallID)
+		firewallObj, err := firewall.Get(doSvc, cfg.FirewallID)
 		if err != nil {
 			if e, ok := err.(*digitalocean.RespError); ok {
 				if e.StatusCode == 404 {
@@ -88,6 +144,19 @@ func (cmd *MakeRemoteCmd) Run() error {
 			}
 		} else {
 			fmt.Println("Found")
+			// Without the project's manifest the ports it publishes aren't known, so none are closed
+			err = syncInboundRules(doSvc, firewallObj, inboundRules, fromManifest)
+			if err != nil {
+				return err
+			}
+			if !tag.Has(firewallObj.Tags, cfg.Tag()) {
+				fmt.Print("Applying firewall to the project's droplet...")
+				err = firewall.AddTags(doSvc, firewallObj.ID, []string{cfg.Tag()})
+				if err != nil {
+					return err
+				}
+				fmt.Println("Done")
+			}
 		}
 	} else {
 		fmt.Println("No firewall configured")
@@ -98,36 +167,6 @@ func (cmd *MakeRemoteCmd) Run() error {
 		fmt.Print("Creating firewall...")
 		name := fmt.Sprintf("box-%v", strings.ToLower(cfg.ProjectName))
 
-		inboundRules := []firewall.InboundRule{
-			{
-				Protocol: "tcp",
-				Ports:    "22",
-				Sources: firewall.Addresses{
-					Addresses: allAddresses,
-				},
-			},
-			{
-				Protocol: "tcp",
-				Ports:    "80",
-				Sources: firewall.Addresses{
-					Addresses: allAddresses,
-				},
-			},
-			{
-				Protocol: "tcp",
-				Ports:    "443",
-				Sources: firewall.Addresses{
-					Addresses: allAddresses,
-				},
-			},
-			{
-				Protocol: "icmp",
-				Sources: firewall.Addresses{
-					Addresses: allAddresses,
-				},
-			},
-		}
-
 		outboundRules := []firewall.OutboundRule{
 			{
 				Protocol: "icmp",
@@ -151,7 +190,8 @@ func (cmd *MakeRemoteCmd) Run() error {
 			},
 		}
 
-		firewallObj, err := firewall.Create(doSvc, name, inboundRules, outboundRules)
+		// The firewall protects the droplet by its tag
+		firewallObj, err := firewall.Create(doSvc, name, inboundRules, outboundRules, []string{cfg.Tag()})
 		if err != nil {
 			return err
 		}
@@ -167,10 +207,11 @@ func (cmd *MakeRemoteCmd) Run() error {
 	}
 
 	// Check if block storage has been provisioned, if not provision it
+	var volumeName string
 	createVolume := false
 	if cfg.BlockStorageID != "" {
 		fmt.Print("Verifying existing block storage volume...")
-		_, err := blockstorage.Get(doSvc, cfg.BlockStorageID)
+		volumeObj, err := blockstorage.Get(doSvc, cfg.BlockStorageID)
 		if err != nil {
 			if e, ok := err.(*digitalocean.RespError); ok {
 				if e.StatusCode == 404 {
@@ -182,6 +223,7 @@ func (cmd *MakeRemoteCmd) Run() error {
 				return err
 			}
 		} else {
+			volumeName = volumeObj.Name
 			fmt.Println("Found")
 		}
 	} else {
@@ -192,12 +234,13 @@ func (cmd *MakeRemoteCmd) Run() error {
 	if createVolume == true {
 		fmt.Print("Creating block storage volume...")
 		name := fmt.Sprintf("box-%v", strings.ToLower(cfg.ProjectName))
-		volumeObj, err := blockstorage.Create(doSvc, name, cfg.Region, cfg.VolumeSize)
+		volumeObj, err := blockstorage.Create(doSvc, name, cfg.Region, cfg.VolumeSize, []string{cfg.Tag()})
 		if err != nil {
 			return err
 		}
 
 		cfg.BlockStorageID = volumeObj.ID
+		volumeName = volumeObj.Name
 		// Save in order to prevent redoing this step if a failure occurs
 		err = cfg.Save()
 		if err != nil {
@@ -231,19 +274,59 @@ func (cmd *MakeRemoteCmd) Run() error {
 	}
 
 	if createDroplet == true {
-		fmt.Print("Creating droplet...")
+		sshKeys := []int{cfg.PublicKeyID}
+
 		name := fmt.Sprintf("box-%v", strings.ToLower(cfg.ProjectName))
-		dropletObj, err = droplet.CreateFromPrivateImage(
-			doSvc,
-			name,
-			cfg.DropletSlug,
-			cfg.Region,
-			cfg.ImageID,
-			[]int{cfg.PublicKeyID},
-		)
+		// Team members granted access keep it on a rebuilt droplet, as the keys registered with the account
+		adminKeys, err := registeredAdminKeys(doSvc, accessStore, cfg.ProjectName)
+		if err != nil {
+			return err
+		}
+		userData, err := provision.UserData(provision.UserDataParams{
+			AdminUser:   adminUser,
+			AdminKeys:   adminKeys,
+			ProjectName: cfg.ProjectName,
+			VolumeName:  volumeName,
+			DataDir:     runtime.ProdDataDir,
+			DomainName:  cfg.BareDomainName,
+			Email:       cfg.Email,
+			Steps:       steps,
+		})
 		if err != nil {
 			return err
 		}
+
+		if cfg.ImageID == 0 {
+			fmt.Print("Creating droplet from the stock image...")
+			dropletObj, err = droplet.CreateFromPublicImage(
+				doSvc,
+				name,
+				cfg.DropletSlug,
+				cfg.Region,
+				droplet.DefaultPublicImage,
+				sshKeys,
+				[]string{cfg.Tag()},
+				userData,
+			)
+			if err != nil {
+				return err
+			}
+		} else {
+			fmt.Print("Creating droplet...")
+			dropletObj, err = droplet.CreateFromPrivateImage(
+				doSvc,
+				name,
+				cfg.DropletSlug,
+				cfg.Region,
+				cfg.ImageID,
+				sshKeys,
+				[]string{cfg.Tag()},
+				userData,
+			)
+			if err != nil {
+				return err
+			}
+		}
 		fmt.Println("Done")
 
 		for dropletObj.Status == "new" {
@@ -285,6 +368,17 @@ func (cmd *MakeRemoteCmd) Run() error {
 		}
 	}
 
+	// Resources created before they were tagged at creation are tagged here
+	fmt.Print("Tagging droplet and block storage volume...")
+	err = tag.TagResources(doSvc, cfg.Tag(), []tag.Resource{
+		{ID: strconv.Itoa(cfg.DropletID), Type: tag.ResourceDroplet},
+		{ID: cfg.BlockStorageID, Type: tag.ResourceVolume},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
 	found := false
 	for _, volumeID := range dropletObj.VolumeIds {
 		if volumeID == cfg.BlockStorageID {
@@ -316,6 +410,25 @@ func (cmd *MakeRemoteCmd) Run() error {
 		fmt.Println("Block storage volume attached to droplet")
 	}
 
+	conn, err := connectNewDroplet(cfg, signer)
+	if err != nil {
+		return err
+	}
+	// Closed here rather than with the connection, the signer is closed once done
+	defer conn.Conn.Close()
+
+	// The first boot of a droplet only completes once the volume is attached, which is checked again on later
+	// runs in case it didn't
+	err = waitForProvisioning(conn)
+	if err != nil {
+		return err
+	}
+
+	err = pushSecrets(conn, secretStore, cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+
 	// Make sure an A domain record exists for the droplet
 	fmt.Print("Verifying domain record...")
 	domainRecs, err := domain.ListRecords(doSvc, cfg.BareDomainName, "A")
@@ -357,3 +470,196 @@ func (cmd *MakeRemoteCmd) Run() error {
 
 	return nil
 }
+
+// connectNewDroplet returns an SSH connection to the project's droplet running commands as root, waiting for a droplet which was
+// just created to accept it
+func connectNewDroplet(cfg *config.Config, signer *sshconn.SSHSigner) (*sshconn.SSHConn, error) {
+	for i := 0; i < maxConnectAttempts; i++ {
+		fmt.Println("Trying to contact via SSH...")
+		conn, err := dialDroplet(cfg, signer)
+		if err == nil {
+			return conn, nil
+		}
+		fmt.Println(err)
+		fmt.Printf("Failed, trying again in %vs...\n", sshRetrySeconds)
+		time.Sleep(time.Second * sshRetrySeconds)
+	}
+
+	return nil, fmt.Errorf("Unable to connect to the droplet via SSH, it may still be starting, run box mkremote %v again later", cfg.ProjectName)
+}
+
+// waitForProvisioning waits for cloud-init to finish provisioning the droplet, which it reports by writing the
+// completion file once every step succeeded, and checks that the router, registry and cron services are up
+func waitForProvisioning(conn *sshconn.SSHConn) error {
+	fmt.Print("Waiting for the droplet to be provisioned...")
+	output, err := conn.Output(fmt.Sprintf("cloud-init status --wait >/dev/null; cat %v 2>/dev/null || true", provision.CompletionFile))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(output)) == "" {
+		fmt.Println("Failed")
+		return fmt.Errorf("The droplet wasn't provisioned, see /var/log/cloud-init-output.log on it")
+	}
+	fmt.Println("Done")
+
+	fmt.Print("Checking the droplet's services...")
+	names := runtime.ProdContainerNames()
+	output, err = conn.Output(fmt.Sprintf("docker inspect -f '{{.Name}} {{.State.Running}}' %v 2>/dev/null || true", strings.Join(names, " ")))
+	if err != nil {
+		return err
+	}
+	running := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == "true" {
+			running[strings.TrimPrefix(fields[0], "/")] = true
+		}
+	}
+	down := []string{}
+	for _, name := range names {
+		if !running[name] {
+			down = append(down, name)
+		}
+	}
+	if len(down) > 0 {
+		fmt.Println("Failed")
+		return fmt.Errorf("The droplet's %v containers aren't running, see docker logs on it", strings.Join(down, ", "))
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+// baseInboundRules returns the inbound rules required by every box firewall
+func baseInboundRules() []firewall.InboundRule {
+	return []firewall.InboundRule{
+		{
+			Protocol: "tcp",
+			Ports:    "22",
+			Sources: firewall.Addresses{
+				Addresses: allAddresses,
+			},
+		},
+		{
+			Protocol: "tcp",
+			Ports:    "80",
+			Sources: firewall.Addresses{
+				Addresses: allAddresses,
+			},
+		},
+		{
+			Protocol: "tcp",
+			Ports:    "443",
+			Sources: firewall.Addresses{
+				Addresses: allAddresses,
+			},
+		},
+		{
+			Protocol: "icmp",
+			Sources: firewall.Addresses{
+				Addresses: allAddresses,
+			},
+		},
+	}
+}
+
+// inboundRuleKey identifies an inbound rule by its protocol and ports
+func inboundRuleKey(rule firewall.InboundRule) string {
+	return fmt.Sprintf("%v/%v", rule.Protocol, rule.Ports)
+}
+
+// projectInboundRules returns the base inbound rules, along with a rule for each port which the project's services
+// publish on a non-loopback interface.  Service ports are only considered when the manifest in the current directory
+// belongs to the project, which is reported along with the rules.
+func projectInboundRules(projectName string, profiles []string) ([]firewall.InboundRule, bool, error) {
+	inboundRules := baseInboundRules()
+
+	if _, err := os.Stat(manifestFilename); err != nil {
+		if os.IsNotExist(err) {
+			fmt.Printf("No %v found in the current directory, only the base firewall rules will be applied\n", manifestFilename)
+			return inboundRules, false, nil
+		}
+		return nil, false, err
+	}
+
+	mfst, err := loadManifest(manifest.EnvProd)
+	if err != nil {
+		return nil, false, err
+	}
+	if mfst.Project != projectName {
+		fmt.Printf("%v belongs to project %v, only the base firewall rules will be applied\n", manifestFilename, mfst.Project)
+		return inboundRules, false, nil
+	}
+
+	seen := map[string]bool{}
+	for _, rule := range inboundRules {
+		seen[inboundRuleKey(rule)] = true
+	}
+	for _, port := range mfst.GetPublicPorts(manifest.EnvProd, profiles) {
+		rule := firewall.InboundRule{
+			Protocol: port.Protocol,
+			Ports:    port.Ports,
+			Sources: firewall.Addresses{
+				Addresses: allAddresses,
+			},
+		}
+		if seen[inboundRuleKey(rule)] == false {
+			seen[inboundRuleKey(rule)] = true
+			inboundRules = append(inboundRules, rule)
+		}
+	}
+
+	return inboundRules, true, nil
+}
+
+// syncInboundRules adds any missing inbound rules to the firewall, and when removeUnused is set, removes tcp and
+// udp rules which are no longer required.  Rules are matched on protocol and ports, so sources restricted by hand
+// are left alone.
+func syncInboundRules(doSvc *digitalocean.Service, firewallObj *firewall.Firewall, inboundRules []firewall.InboundRule, removeUnused bool) error {
+	existing := map[string]bool{}
+	for _, rule := range firewallObj.InboundRules {
+		existing[inboundRuleKey(rule)] = true
+	}
+
+	desired := map[string]bool{}
+	toAdd := []firewall.InboundRule{}
+	for _, rule := range inboundRules {
+		desired[inboundRuleKey(rule)] = true
+		if rule.Protocol != "icmp" && existing[inboundRuleKey(rule)] == false {
+			toAdd = append(toAdd, rule)
+		}
+	}
+
+	toRemove := []firewall.InboundRule{}
+	for _, rule := range firewallObj.InboundRules {
+		if removeUnused && rule.Protocol != "icmp" && desired[inboundRuleKey(rule)] == false {
+			toRemove = append(toRemove, rule)
+		}
+	}
+
+	if len(toAdd) > 0 {
+		fmt.Print("Opening firewall ports")
+		for _, rule := range toAdd {
+			fmt.Print(" ", inboundRuleKey(rule))
+		}
+		fmt.Print("...")
+		if err := firewall.AddInboundRules(doSvc, firewallObj.ID, toAdd); err != nil {
+			return err
+		}
+		fmt.Println("Done")
+	}
+
+	if len(toRemove) > 0 {
+		fmt.Print("Closing firewall ports")
+		for _, rule := range toRemove {
+			fmt.Print(" ", inboundRuleKey(rule))
+		}
+		fmt.Print("...")
+		if err := firewall.RemoveInboundRules(doSvc, firewallObj.ID, toRemove); err != nil {
+			return err
+		}
+		fmt.Println("Done")
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"box/manifest"
+	"os"
+)
+
+const manifestFilename = manifest.BaseFilename
+
+// loadManifest loads the run manifest from the current working directory, layered with the override for
+// the runtime environment when one exists
+func loadManifest(env string) (*manifest.Manifest, error) {
+	dirName, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest.NewManifest(manifest.LayerFilenames(dirName, env)...)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"box/access"
+	"box/api/digitalocean/sshkeys"
+	"box/bundle"
+	"box/config"
+	"box/secrets"
+	"box/sshconn"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moby/term"
+	"golang.org/x/crypto/ssh"
+)
+
+type ProjectCmd struct {
+	Export ProjectExportCmd `cmd:"" help:"Write a passphrase encrypted bundle from which a teammate can operate the project"`
+	Import ProjectImportCmd `cmd:"" help:"Load a project from a bundle exported by a teammate"`
+}
+
+type ProjectExportCmd struct {
+	Name     string        `arg:"" help:"Project name"`
+	Output   string        `short:"o" help:"Bundle filename, defaults to <project>.boxbundle"`
+	Member   string        `help:"Name of the teammate the bundle is for, who is granted access to the droplet as with box access grant"`
+	Expires  time.Duration `default:"168h" help:"How long the droplet accepts the bundle's key, unless the teammate replaced it with their own by importing the bundle"`
+	NoAccess bool          `help:"Leave out the key which lets the teammate authorize their own on the droplet"`
+}
+
+type ProjectImportCmd struct {
+	Bundle     string `arg:"" type:"existingfile" help:"Bundle filename"`
+	Context    string `help:"Context to operate the project with.  Defaults to the current context"`
+	Credential string `help:"Name of the credential holding the account's API token.  Defaults to that of the context, or the default credential"`
+	PrivateKey string `help:"Private key full path, whose public key is alongside it with a .pub extension.  Defaults to that of the context, or ~/.ssh/id_rsa"`
+}
+
+// Files of the project's configuration directory which are bundled, besides the configuration itself
+func bundledFileNames() []string {
+	return append([]string{config.KnownHostsFileName, access.FileName}, secrets.FileNames...)
+}
+
+// readBundlePassphrase reads the bundle's passphrase, asking for it twice when it's typed in for a new bundle
+func readBundlePassphrase(confirm bool) (string, error) {
+	passphrase, err := readHiddenInput("Bundle passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("A passphrase is required")
+	}
+
+	if confirm && term.IsTerminal(os.Stdin.Fd()) {
+		again, err := readHiddenInput("Repeat the passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("The passphrases don't match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+func (cmd *ProjectExportCmd) Run() error {
+	cfg, err := config.Load(cmd.Name)
+	if err != nil {
+		return err
+	}
+	shared, err := cfg.Shared()
+	if err != nil {
+		return err
+	}
+	output := cmd.Output
+	if output == "" {
+		output = fmt.Sprintf("%v.boxbundle", cfg.ProjectName)
+	}
+
+	grantAccess := !cmd.NoAccess && cfg.DropletPublicIP != ""
+	if !cmd.NoAccess && cfg.DropletPublicIP == "" {
+		fmt.Println("The project has no droplet yet, the bundle won't grant access to one")
+	}
+	var store *access.Store
+	if grantAccess {
+		if cmd.Member == "" {
+			return fmt.Errorf("Name the teammate the bundle is for with --member, or leave access to the droplet out with --no-access")
+		}
+		if !access.NameRe.Match([]byte(cmd.Member)) {
+			return fmt.Errorf("Team member names must only contain letters, numbers, or any of ._@- and be at most 64 characters long")
+		}
+		store, err = access.Load(cfg.ProjectName)
+		if err != nil {
+			return err
+		}
+		if _, exists := store.Grants[cmd.Member]; exists {
+			return fmt.Errorf("%v has already been granted access to project %v, revoke it first to export a bundle for them", cmd.Member, cfg.ProjectName)
+		}
+	}
+
+	passphrase, err := readBundlePassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	b := &bundle.Bundle{
+		Config: string(shared),
+		Files:  map[string]string{},
+	}
+
+	// The teammate's key can't reach the droplet until it's authorized, which they do with a key granted to them
+	// until then, and which expires unless they do
+	if grantAccess {
+		accessSigner, seed, err := sshconn.GenerateSigner()
+		if err != nil {
+			return err
+		}
+		publicKey := accessSigner.Signer.PublicKey()
+		store.Grants[cmd.Member] = &access.Grant{
+			PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+			Fingerprint: ssh.FingerprintSHA256(publicKey),
+			Granted:     time.Now().UTC(),
+			Expires:     time.Now().Add(cmd.Expires).UTC(),
+		}
+
+		fmt.Print("Authorizing the bundle's key on the droplet...")
+		conn, err := connectDroplet(cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if err := conn.AuthorizeKey(adminUser, store.AuthorizedKey(cmd.Member)); err != nil {
+			return err
+		}
+		fmt.Println("Done")
+
+		// Saved ahead of bundling, so that the teammate's copy holds the grant too
+		if err := store.Save(); err != nil {
+			return err
+		}
+		b.AccessKey = base64.StdEncoding.EncodeToString(seed)
+		b.AccessMember = cmd.Member
+	}
+
+	projectDir, err := cfg.Dir()
+	if err != nil {
+		return err
+	}
+	for _, name := range bundledFileNames() {
+		data, err := ioutil.ReadFile(filepath.Join(projectDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b.Files[name] = string(data)
+	}
+
+	fmt.Printf("Writing bundle %v...", output)
+	sealed, err := b.Seal(passphrase)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(output, sealed, os.FileMode(0600)); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+	fmt.Println("Share the bundle and its passphrase separately, the teammate loads it with: box project import", output)
+	if b.AccessKey != "" {
+		fmt.Printf(
+			"The bundle grants %v access to the droplet until %v unless imported before, revoke it with: box access revoke %v\n",
+			cmd.Member,
+			store.Grants[cmd.Member].Expires.Local().Format(time.RFC822),
+			cmd.Member,
+		)
+	}
+
+	return nil
+}
+
+func (cmd *ProjectImportCmd) Run() error {
+	data, err := ioutil.ReadFile(cmd.Bundle)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readBundlePassphrase(false)
+	if err != nil {
+		return err
+	}
+	b, err := bundle.Open(data, passphrase)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.ParseShared([]byte(b.Config))
+	if err != nil {
+		return err
+	}
+	if _, err := config.Load(cfg.ProjectName); err == nil {
+		return fmt.Errorf("Project %v already exists", cfg.ProjectName)
+	}
+	for name := range b.Files {
+		allowed := false
+		for _, bundledName := range bundledFileNames() {
+			allowed = allowed || name == bundledName
+		}
+		if !allowed {
+			return fmt.Errorf("The bundle holds an unexpected file: %v", name)
+		}
+	}
+
+	acct, err := resolveAccount(cmd.Context, cmd.Credential)
+	if err != nil {
+		return err
+	}
+	cfg.Context = acct.contextName
+	cfg.Credential = acct.credentialName
+	cfg.PrivateKeyFilename = privateKeyFilename(cmd.PrivateKey, acct.context)
+
+	pbkFilename := fmt.Sprintf("%v.pub", cfg.PrivateKeyFilename)
+	pbkData, err := ioutil.ReadFile(pbkFilename)
+	if err != nil {
+		return fmt.Errorf("Unable to read public key file %v, choose another key with --private-key", pbkFilename)
+	}
+	publicKey := strings.Trim(string(pbkData), " \n\t")
+
+	if acct.context.PrivateKeyFilename == cfg.PrivateKeyFilename && acct.context.PublicKeyID != 0 {
+		cfg.PublicKeyID = acct.context.PublicKeyID
+	} else {
+		cfg.PublicKeyID, err = findPublicKey(acct.doSvc, cfg.PrivateKeyFilename)
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.PublicKeyID == 0 {
+		fmt.Print("Registering SSH public key...")
+		createdKey, err := sshkeys.Create(acct.doSvc, fmt.Sprintf("box-key-%v", cfg.ProjectName), publicKey)
+		if err != nil {
+			return err
+		}
+		cfg.PublicKeyID = createdKey.ID
+		fmt.Println("Done")
+	}
+
+	fmt.Printf("Writing project %v...", cfg.ProjectName)
+	if err := config.Adopt(cfg); err != nil {
+		return err
+	}
+	projectDir, err := cfg.Dir()
+	if err != nil {
+		return err
+	}
+	for name, content := range b.Files {
+		if err := ioutil.WriteFile(filepath.Join(projectDir, name), []byte(content), os.FileMode(0600)); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Done")
+
+	if b.AccessKey == "" || cfg.DropletPublicIP == "" {
+		fmt.Println("The bundle doesn't grant access to a droplet")
+		return nil
+	}
+
+	if !access.NameRe.Match([]byte(b.AccessMember)) {
+		return fmt.Errorf("The bundle names an invalid team member: %v", b.AccessMember)
+	}
+	seed, err := base64.StdEncoding.DecodeString(b.AccessKey)
+	if err != nil {
+		return fmt.Errorf("The bundle's access key is corrupt: %w", err)
+	}
+	accessSigner, err := sshconn.SignerFromSeed(seed)
+	if err != nil {
+		return err
+	}
+	ownKey, _, _, _, err := ssh.ParseAuthorizedKey(pbkData)
+	if err != nil {
+		return fmt.Errorf("Unable to process public key file %v: %w", pbkFilename, err)
+	}
+	store, err := access.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+	store.Grants[b.AccessMember] = &access.Grant{
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(ownKey))),
+		Fingerprint: ssh.FingerprintSHA256(ownKey),
+		PublicKeyID: cfg.PublicKeyID,
+		Granted:     time.Now().UTC(),
+	}
+
+	fmt.Print("Authorizing your key on the droplet...")
+	conn, err := dialDroplet(cfg, accessSigner)
+	if err != nil {
+		return fmt.Errorf("Project %v was imported, but the bundle's key wasn't accepted by the droplet, it may have been used already or expired: %w", cfg.ProjectName, err)
+	}
+	defer conn.Close()
+	if err := conn.AuthorizeKey(adminUser, store.AuthorizedKey(b.AccessMember)); err != nil {
+		return err
+	}
+	// Used up once the teammate's own key is authorized
+	if err := conn.RevokeKey(adminUser, accessSigner.Signer.PublicKey()); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	return store.Save()
+}
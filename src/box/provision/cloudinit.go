@@ -0,0 +1,136 @@
+package provision
+
+import (
+	_ "embed"
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CompletionFile is written on the droplet once cloud-init has provisioned it, holding the name of the
+// provisioning which was applied
+const CompletionFile = "/var/lib/box/provisioned"
+
+// DigitalOcean refuses user data any larger
+const maxUserDataSize = 64 * 1024
+
+// Where the steps are written on the droplet, before cloud-init runs them
+const userDataDir = "/var/lib/box/provision"
+
+//go:embed scripts/firstboot.sh
+var firstBootScript []byte
+
+//go:embed scripts/services.sh
+var servicesScript []byte
+
+// UserDataParams describe the droplet which cloud-init provisions
+type UserDataParams struct {
+	AdminUser string
+	// Public keys authorized for the admin user, in authorized_keys format
+	AdminKeys   []string
+	ProjectName string
+	VolumeName  string
+	DataDir     string
+	// Domain the router serves and certificates are requested for, by the email address
+	DomainName string
+	Email      string
+	// Provisioning steps, as returned by Steps, or nil for a droplet created from a base image they were
+	// already applied to
+	Steps []Step
+}
+
+type cloudConfigUser struct {
+	Name              string   `yaml:"name"`
+	Shell             string   `yaml:"shell,omitempty"`
+	Sudo              string   `yaml:"sudo,omitempty"`
+	LockPasswd        bool     `yaml:"lock_passwd,omitempty"`
+	SSHAuthorizedKeys []string `yaml:"ssh_authorized_keys,omitempty"`
+}
+
+type cloudConfigFile struct {
+	Path        string `yaml:"path"`
+	Permissions string `yaml:"permissions"`
+	Content     string `yaml:"content"`
+}
+
+type cloudConfig struct {
+	PackageUpdate  bool              `yaml:"package_update"`
+	PackageUpgrade bool              `yaml:"package_upgrade"`
+	Packages       []string          `yaml:"packages,omitempty"`
+	Users          []interface{}     `yaml:"users"`
+	WriteFiles     []cloudConfigFile `yaml:"write_files"`
+	RunCmd         [][]string        `yaml:"runcmd"`
+}
+
+// UserData returns the cloud-config document which prepares a droplet on its first boot and starts the router,
+// registry and cron services.  A droplet created from the stock image is provisioned like one created from a base
+// image, without building one: the base step is replaced by its first boot counterpart, since cloud-init itself
+// takes care of the rest, and the remaining steps are run after it.
+func UserData(params UserDataParams) (string, error) {
+	// Recorded in the completion file, the steps of a base image were applied when it was built
+	name := "box-image"
+	steps := []Step{{Name: "00-firstboot.sh", Script: firstBootScript}}
+	if params.Steps != nil {
+		name = ImageName(params.Steps)
+		steps = append(steps, params.Steps[1:]...)
+	}
+	steps = append(steps, Step{Name: "99-services.sh", Script: servicesScript})
+
+	cc := cloudConfig{
+		PackageUpdate:  params.Steps != nil,
+		PackageUpgrade: params.Steps != nil,
+		Users: []interface{}{
+			// Keeps the keys of the droplet's SSH keys authorized for root
+			"default",
+			cloudConfigUser{
+				Name:              params.AdminUser,
+				Shell:             "/bin/bash",
+				Sudo:              "ALL=(ALL) NOPASSWD:ALL",
+				LockPasswd:        true,
+				SSHAuthorizedKeys: params.AdminKeys,
+			},
+		},
+		WriteFiles: []cloudConfigFile{},
+		RunCmd:     [][]string{},
+	}
+	if params.Steps != nil {
+		cc.Packages = []string{"docker.io", "unattended-upgrades"}
+	}
+
+	env := fmt.Sprintf(
+		"ADMIN_USER=%v BOX_PROJECT=%v VOLUME_NAME=%v DATA_DIR=%v DOMAIN_NAME=%v EMAIL=%v",
+		params.AdminUser,
+		params.ProjectName,
+		params.VolumeName,
+		params.DataDir,
+		params.DomainName,
+		params.Email,
+	)
+	commands := []string{}
+	for _, step := range steps {
+		filename := path.Join(userDataDir, step.Name)
+		cc.WriteFiles = append(cc.WriteFiles, cloudConfigFile{
+			Path:        filename,
+			Permissions: "0700",
+			Content:     string(step.Script),
+		})
+		commands = append(commands, fmt.Sprintf("%v bash '%v'", env, filename))
+	}
+	// Chained, since cloud-init carries on with the next command after a failure, and the completion file
+	// must only be written once every step succeeded
+	commands = append(commands, fmt.Sprintf("echo %v > %v", name, CompletionFile))
+	cc.RunCmd = append(cc.RunCmd, []string{"sh", "-c", strings.Join(commands, " && ")})
+
+	data, err := yaml.Marshal(&cc)
+	if err != nil {
+		return "", fmt.Errorf("provision.UserData: %w", err)
+	}
+	userData := "#cloud-config\n" + string(data)
+	if len(userData) > maxUserDataSize {
+		return "", fmt.Errorf("The provisioning scripts come to %v bytes of user data, DigitalOcean accepts at most %v", len(userData), maxUserDataSize)
+	}
+
+	return userData, nil
+}
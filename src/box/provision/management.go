@@ -0,0 +1,116 @@
+package provision
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// RegistryImage is the upstream image the registry service runs, tagged box-registry on the droplet
+const RegistryImage = "registry:2.7.1"
+
+//go:embed management/router management/cron
+var managementSources embed.FS
+
+//go:embed scripts/management.sh
+var managementScript string
+
+// managementTag returns the tag given to the management service images along with latest, which identifies
+// the version of the provisioning steps they were built by
+func managementTag() string {
+	return fmt.Sprintf("v%v", Version)
+}
+
+// managementArchive returns the sources of the management service images as a gzipped tarball.  Names, modes
+// and times are fixed, so the archive, and the image name which depends on it, only change with the sources.
+func managementArchive() ([]byte, error) {
+	filenames := []string{}
+	err := fs.WalkDir(managementSources, "management", func(filename string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			filenames = append(filenames, filename)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filenames)
+
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, filename := range filenames {
+		data, err := managementSources.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		// Scripts are run by the images' entrypoints
+		mode := int64(0644)
+		if strings.HasSuffix(filename, ".sh") {
+			mode = 0755
+		}
+		header := tar.Header{
+			Name: strings.TrimPrefix(filename, "management/"),
+			Mode: mode,
+			Size: int64(len(data)),
+		}
+		if err := tw.WriteHeader(&header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// managementStep returns the step which builds the images of the management services
+func managementStep() (Step, error) {
+	archive, err := managementArchive()
+	if err != nil {
+		return Step{}, fmt.Errorf("provision.managementStep: %w", err)
+	}
+
+	// Wrapped like the output of base64, to keep the script readable
+	encoded := base64.StdEncoding.EncodeToString(archive)
+	lines := []string{}
+	for len(encoded) > 76 {
+		lines = append(lines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	lines = append(lines, encoded)
+
+	tmpl, err := template.New("management").Parse(managementScript)
+	if err != nil {
+		return Step{}, fmt.Errorf("provision.managementStep: %w", err)
+	}
+	script := bytes.Buffer{}
+	err = tmpl.Execute(&script, map[string]string{
+		"Archive":       strings.Join(lines, "\n"),
+		"Tag":           managementTag(),
+		"RegistryImage": RegistryImage,
+	})
+	if err != nil {
+		return Step{}, fmt.Errorf("provision.managementStep: %w", err)
+	}
+
+	return Step{Name: "01-management.sh", Script: script.Bytes()}, nil
+}
@@ -4,6 +4,12 @@ set -e
 
 echo "Running certificate request script"
 
+if [ -z "$DOMAIN_NAME" ]
+then
+  echo "No domain name configured, not requesting"
+  exit 0
+fi
+
 # Check that the certificate for $DOMAIN_NAME exists.
 CERT_FILE=/etc/letsencrypt/live/$DOMAIN_NAME/fullchain.pem
 if [ -e $CERT_FILE ]
@@ -18,18 +24,20 @@ else
 
   mkdir -p $CHALLENGE_DIR
   echo $CHALLENGE_CHARS > $CHALLENGE_FILE_PATH
-  RESP_CHARS=$(curl -sf http://$DOMAIN_NAME/.well-known/acme-challenge/$CHALLENGE_FNAME)
+  # An unreachable domain fails the challenge below, rather than the script
+  RESP_CHARS=$(curl -sf http://$DOMAIN_NAME/.well-known/acme-challenge/$CHALLENGE_FNAME || true)
   if [ "$RESP_CHARS" = "$CHALLENGE_CHARS" ]
   then
-    rm CHALLENGE_FILE_PATH
+    rm $CHALLENGE_FILE_PATH
     echo "Internal challenge succeded"
     certbot certonly \
       --non-interactive \
       --agree-tos \
       --email $EMAIL \
       --keep-until-expiring \
-      --webroot
-      -w /var/www/acme
+      --webroot \
+      -w /var/www/acme \
+      -d $DOMAIN_NAME
 
     echo "Successfully requested certificate for ${DOMAIN_NAME}"
     # Restart the nginx container, so that it picks up the presence of the certificate and loads it
@@ -0,0 +1,55 @@
+package provision
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// Version of the embedded provisioning steps, raised whenever they change
+const Version = 2
+
+//go:embed scripts/base.sh
+var baseScript []byte
+
+// Step is a single provisioning script, run as root on the droplet an image is built from
+type Step struct {
+	Name   string
+	Script []byte
+}
+
+// Steps returns the embedded base and management steps, followed by a step for each of the project's own scripts
+func Steps(snippetFilenames []string) ([]Step, error) {
+	management, err := managementStep()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []Step{{Name: "00-base.sh", Script: baseScript}, management}
+	for i, filename := range snippetFilenames {
+		script, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read provisioning script %v: %w", filename, err)
+		}
+		steps = append(steps, Step{
+			Name:   fmt.Sprintf("%02d-%v", i+10, filepath.Base(filename)),
+			Script: script,
+		})
+	}
+
+	return steps, nil
+}
+
+// ImageName returns the snapshot name of an image provisioned with the steps, which records the version of
+// the embedded steps and changes along with any of the scripts
+func ImageName(steps []Step) string {
+	hash := sha256.New()
+	for _, step := range steps {
+		fmt.Fprintf(hash, "%v\x00%v\x00", step.Name, len(step.Script))
+		hash.Write(step.Script)
+	}
+
+	return fmt.Sprintf("box-base-v%v-%x", Version, hash.Sum(nil)[:4])
+}
@@ -0,0 +1,33 @@
+#!/bin/bash
+# Provisions the box base image on a fresh Ubuntu 20.04 droplet, as root.  The droplet is powered off and
+# snapshotted afterwards, so nothing here may depend on the droplet it runs on.
+#
+# ADMIN_USER names the user which operates the host.
+set -euo pipefail
+
+: "${ADMIN_USER:?ADMIN_USER must be set}"
+export DEBIAN_FRONTEND=noninteractive
+
+# Let cloud-init finish the first boot before touching packages
+cloud-init status --wait >/dev/null || true
+
+apt-get update
+apt-get -y -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold upgrade
+apt-get -y install docker.io unattended-upgrades
+systemctl enable docker
+
+# The admin user operates the host over SSH, with the keys each droplet's cloud-init authorizes for it
+if ! id "$ADMIN_USER" >/dev/null 2>&1; then
+	adduser --disabled-password --gecos "" "$ADMIN_USER"
+fi
+usermod -aG sudo,docker "$ADMIN_USER"
+echo "$ADMIN_USER ALL=(ALL) NOPASSWD:ALL" > "/etc/sudoers.d/90-$ADMIN_USER"
+chmod 440 "/etc/sudoers.d/90-$ADMIN_USER"
+install -d -m 700 -o "$ADMIN_USER" -g "$ADMIN_USER" "/home/$ADMIN_USER/.ssh"
+
+# Keys only
+sed -i -E 's/^#?PasswordAuthentication .*/PasswordAuthentication no/' /etc/ssh/sshd_config
+
+# Droplets created from the image get their own host keys and authorized keys on first boot
+cloud-init clean --logs
+rm -f /root/.ssh/authorized_keys
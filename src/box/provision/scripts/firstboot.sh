@@ -0,0 +1,41 @@
+#!/bin/bash
+# Prepares a droplet on its first boot, run as root by cloud-init.  On a droplet created from the stock Ubuntu
+# 20.04 image, it takes the place of the base step, cloud-init having put docker and the admin user in place
+# by the time it runs.  On a droplet created from a base image, it mounts the block storage volume.
+#
+# ADMIN_USER names the user which operates the host, VOLUME_NAME the project's block storage volume and
+# DATA_DIR where it's mounted.
+set -euo pipefail
+
+: "${ADMIN_USER:?ADMIN_USER must be set}"
+: "${VOLUME_NAME:?VOLUME_NAME must be set}"
+: "${DATA_DIR:?DATA_DIR must be set}"
+
+# The docker group only exists once docker.io is installed, after the admin user was created
+usermod -aG docker "$ADMIN_USER"
+systemctl enable --now docker
+
+# Keys only
+sed -i -E 's/^#?PasswordAuthentication .*/PasswordAuthentication no/' /etc/ssh/sshd_config
+systemctl reload ssh
+
+# box mkremote attaches the volume once the droplet is active, which may well be after boot
+device="/dev/disk/by-id/scsi-0DO_Volume_$VOLUME_NAME"
+for i in $(seq 1 120); do
+	[ -e "$device" ] && break
+	sleep 5
+done
+if [ ! -e "$device" ]; then
+	echo "Block storage volume $VOLUME_NAME was never attached" >&2
+	exit 1
+fi
+
+# A volume carried over from a previous droplet keeps its data, only a blank one is formatted
+if ! blkid "$device" >/dev/null 2>&1; then
+	mkfs.ext4 -q "$device"
+fi
+mkdir -p "$DATA_DIR"
+if ! grep -qF "$device" /etc/fstab; then
+	echo "$device $DATA_DIR ext4 defaults,nofail,discard 0 2" >> /etc/fstab
+fi
+mountpoint -q "$DATA_DIR" || mount "$DATA_DIR"
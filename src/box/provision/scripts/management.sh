@@ -0,0 +1,19 @@
+#!/bin/bash
+# Builds the images of the router, registry and cron services which run alongside every project, as root.
+# Their sources are embedded below by box, so the images always match the version of box which built them.
+set -euo pipefail
+
+systemctl start docker
+
+src=$(mktemp -d)
+trap 'rm -rf "$src"' EXIT
+base64 -d > "$src/management.tar.gz" <<'ARCHIVE'
+{{.Archive}}
+ARCHIVE
+tar -xzf "$src/management.tar.gz" -C "$src"
+
+docker build -t box-router:{{.Tag}} -t box-router:latest "$src/router"
+docker build -t box-cron:{{.Tag}} -t box-cron:latest "$src/cron"
+docker pull {{.RegistryImage}}
+docker tag {{.RegistryImage}} box-registry:{{.Tag}}
+docker tag {{.RegistryImage}} box-registry:latest
@@ -0,0 +1,71 @@
+#!/bin/bash
+# Starts the router, registry and cron services on the droplet, as root, once the management images are in place.
+# Containers are named and labeled like those box creates, so box takes them over when it starts the project.
+# Run on every droplet, whether created from the stock image or a base image, and safe to run again.
+#
+# BOX_PROJECT names the project, DATA_DIR where its block storage volume is mounted, DOMAIN_NAME and EMAIL the
+# domain certificates are requested for and by.
+set -euo pipefail
+
+: "${BOX_PROJECT:?BOX_PROJECT must be set}"
+: "${DATA_DIR:?DATA_DIR must be set}"
+DOMAIN_NAME="${DOMAIN_NAME:-}"
+EMAIL="${EMAIL:-}"
+
+systemctl start docker
+
+# The router includes the configuration box generates for routed services, empty until the project starts
+mkdir -p "$DATA_DIR/letsencrypt" "$DATA_DIR/www/acme" "$DATA_DIR/registry" "$DATA_DIR/router"
+for conf in upstreams.conf locations.conf; do
+	[ -e "$DATA_DIR/router/$conf" ] || touch "$DATA_DIR/router/$conf"
+done
+
+network="box__$BOX_PROJECT"
+if ! docker network inspect "$network" >/dev/null 2>&1; then
+	docker network create --driver bridge --label "box.do.project=$BOX_PROJECT" "$network"
+fi
+
+# run starts the named service's container, creating it with the remaining arguments unless it already exists
+run() {
+	local service=$1
+	shift
+	if docker container inspect "box__$service" >/dev/null 2>&1; then
+		docker start "box__$service"
+		return
+	fi
+	docker run -d \
+		--name "box__$service" \
+		--restart unless-stopped \
+		--network "$network" \
+		--label "box.do.project=$BOX_PROJECT" \
+		--label "box.do.service=$service" \
+		"$@"
+}
+
+run router \
+	--hostname box-router \
+	--network-alias box-router \
+	-p 0.0.0.0:80:80 \
+	-p 0.0.0.0:443:443 \
+	-e "DOMAIN_NAME=$DOMAIN_NAME" \
+	-v "$DATA_DIR/letsencrypt:/etc/letsencrypt" \
+	-v "$DATA_DIR/www/acme:/var/www/acme" \
+	-v "$DATA_DIR/router:/etc/nginx/box" \
+	box-router
+
+# Published on the droplet's loopback address only, box reaches it through an SSH tunnel
+run registry \
+	--hostname box-registry \
+	--network-alias box-registry \
+	-p 127.0.0.1:5000:5000 \
+	-v "$DATA_DIR/registry:/var/lib/registry" \
+	box-registry
+
+run cron \
+	-e "DOMAIN_NAME=$DOMAIN_NAME" \
+	-e "EMAIL=$EMAIL" \
+	-e "NGINX_CONTAINER_NAME=box__router" \
+	-v "$DATA_DIR/letsencrypt:/etc/letsencrypt" \
+	-v "$DATA_DIR/www/acme:/var/www/acme" \
+	-v /var/run/docker.sock:/var/run/docker.sock \
+	box-cron
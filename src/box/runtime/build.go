@@ -0,0 +1,312 @@
+package runtime
+
+import (
+	"box/manifest"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	units "github.com/docker/go-units"
+)
+
+// Maximum number of images built concurrently
+const maxParallelBuilds = 4
+
+// Platform targeted by images destined for a remote deployment, unless the service specifies otherwise
+const defaultRemotePlatform = "linux/amd64"
+
+// BuildResult holds the outcome of building the image for a single service
+type BuildResult struct {
+	Service  string
+	Image    string
+	Hash     string
+	Skipped  bool
+	Duration time.Duration
+	Size     int64
+	Output   string
+	Err      error
+}
+
+// buildSpec holds the resolved inputs required to build the image for a service
+type buildSpec struct {
+	contextPath   string
+	relDockerfile string
+	excludes      []string
+	platform      string
+	hash          string
+}
+
+// newBuildSpec resolves the build inputs for a service, and computes the build hash which identifies them
+func (rt *Runtime) newBuildSpec(service *manifest.Service) (*buildSpec, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("runtime.newBuildSpec: %w", err)
+	}
+
+	contextPath, err := filepath.Abs(filepath.Join(dir, service.Build.Context))
+	if err != nil {
+		return nil, fmt.Errorf("runtime.newBuildSpec: %w", err)
+	}
+	dockerfileAbsPath, err := filepath.Abs(filepath.Join(contextPath, service.Build.Dockerfile))
+	if err != nil {
+		return nil, fmt.Errorf("runtime.newBuildSpec: %w", err)
+	}
+
+	relDockerfile, err := relativeDockerfile(contextPath, dockerfileAbsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	excludes, err := contextExcludes(contextPath, relDockerfile)
+	if err != nil {
+		return nil, err
+	}
+
+	contextHash, err := hashBuildContext(contextPath, excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	platform := service.Build.Platform
+	if platform == "" && rt.Production == true {
+		platform = defaultRemotePlatform
+	}
+
+	// The dockerfile contents are covered by the context hash, but not which file is used.  Secrets and
+	// cache sources don't alter the resulting image, so they are left out.
+	hash := sha256.New()
+	fmt.Fprintf(hash, "context=%v\x00dockerfile=%v\x00", contextHash, relDockerfile)
+	fmt.Fprintf(hash, "target=%v\x00platform=%v\x00", service.Build.Target, platform)
+	argKeys := []string{}
+	for key := range service.Build.Args {
+		argKeys = append(argKeys, key)
+	}
+	sort.Strings(argKeys)
+	for _, key := range argKeys {
+		fmt.Fprintf(hash, "arg:%v=%v\x00", key, service.Build.Args[key])
+	}
+
+	return &buildSpec{
+		contextPath:   contextPath,
+		relDockerfile: relDockerfile,
+		excludes:      excludes,
+		platform:      platform,
+		hash:          fmt.Sprintf("%x", hash.Sum(nil)),
+	}, nil
+}
+
+// BuildImage builds the image for a single service through the engine API using BuildKit.  Build
+// output is captured in the result rather than echoed, since builds may run concurrently.  Unless
+// force is set, the build is skipped when the existing image was built from identical inputs.
+func (rt *Runtime) BuildImage(service *manifest.Service, force bool) *BuildResult {
+	result := &BuildResult{
+		Service: service.Name,
+		Image:   service.GetImage(rt.Config.ProjectNameHash()),
+	}
+
+	started := time.Now()
+	spec, err := rt.newBuildSpec(service)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Hash = spec.hash
+
+	if force == false {
+		inspect, _, err := rt.Client.ImageInspectWithRaw(rt.Context, result.Image)
+		if err == nil && inspect.Config != nil && inspect.Config.Labels[LabelBuildHash] == spec.hash {
+			result.Skipped = true
+			result.Size = inspect.Size
+			result.Duration = time.Since(started)
+			return result
+		}
+	}
+
+	output := strings.Builder{}
+	result.Err = rt.buildImage(service, spec, result.Image, &output)
+	result.Duration = time.Since(started)
+	result.Output = output.String()
+	if result.Err != nil {
+		return result
+	}
+
+	inspect, _, err := rt.Client.ImageInspectWithRaw(rt.Context, result.Image)
+	if err != nil {
+		result.Err = fmt.Errorf("Unable to inspect image %v: %w", result.Image, err)
+		return result
+	}
+	result.Size = inspect.Size
+
+	return result
+}
+
+func (rt *Runtime) buildImage(service *manifest.Service, spec *buildSpec, image string, output *strings.Builder) error {
+	labels := rt.serviceLabels(service, 0)
+	labels[LabelBuildHash] = spec.hash
+
+	options := types.ImageBuildOptions{
+		Tags:       []string{image},
+		Dockerfile: spec.relDockerfile,
+		Labels:     labels,
+		BuildArgs:  service.Build.GetArgs(),
+		Target:     service.Build.Target,
+		CacheFrom:  service.Build.CacheFrom,
+		Platform:   spec.platform,
+		Remove:     true,
+		Version:    types.BuilderBuildKit,
+	}
+
+	// Secrets can only be supplied to the builder over a session
+	if len(service.Build.Secrets) > 0 {
+		ctx, cancel := context.WithCancel(rt.Context)
+		defer cancel()
+
+		sess, err := rt.startBuildSession(ctx, service.Build.Secrets)
+		if err != nil {
+			return err
+		}
+		defer sess.Close()
+		options.SessionID = sess.ID()
+	}
+
+	buildContext := tarBuildContext(spec.contextPath, spec.excludes)
+	defer buildContext.Close()
+
+	resp, err := rt.Client.ImageBuild(rt.Context, buildContext, options)
+	if err != nil {
+		return fmt.Errorf("Unable to start build: %w", err)
+	}
+	defer resp.Body.Close()
+
+	trace := newBuildkitTrace(output)
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		msg := jsonmessage.JSONMessage{}
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("Unable to read build output: %w", err)
+		}
+
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+
+		switch {
+		case msg.ID == buildkitTraceID && msg.Aux != nil:
+			if err := trace.Write(*msg.Aux); err != nil {
+				return fmt.Errorf("Unable to decode build progress: %w", err)
+			}
+		case msg.Stream != "":
+			output.WriteString(msg.Stream)
+		case msg.Status != "":
+			fmt.Fprintln(output, msg.Status)
+		}
+	}
+
+	return nil
+}
+
+// Build builds the images for all active services which have a build context, several at a time, then
+// prints a summary of the results.  Images whose build inputs are unchanged are skipped unless force is set.
+func (rt *Runtime) Build(force bool) ([]*BuildResult, error) {
+	activeServices, err := rt.activeServices()
+	if err != nil {
+		return nil, err
+	}
+
+	services := []*manifest.Service{}
+	for _, service := range activeServices {
+		if service.Build.Context != "" {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	results := make([]*BuildResult, len(services))
+	sem := make(chan struct{}, maxParallelBuilds)
+	wg := sync.WaitGroup{}
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service *manifest.Service) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			fmt.Println("Building image for", service.Name)
+			results[i] = rt.BuildImage(service, force)
+			if results[i].Skipped {
+				fmt.Printf("Build context for %v is unchanged, skipping\n", service.Name)
+			} else if results[i].Err != nil {
+				fmt.Printf("Build failed for %v after %v\n", service.Name, results[i].Duration.Round(time.Millisecond))
+			} else {
+				fmt.Printf("Built %v in %v\n", service.Name, results[i].Duration.Round(time.Millisecond))
+			}
+		}(i, service)
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result.Service)
+			fmt.Printf("\n--- Build output for %v ---\n%v", result.Service, result.Output)
+			fmt.Println("Error:", result.Err)
+		}
+	}
+
+	printBuildSummary(results)
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("Error building %v", strings.Join(failed, ", "))
+	}
+
+	return results, nil
+}
+
+// printBuildSummary prints a table of build results
+func printBuildSummary(results []*BuildResult) {
+	if len(results) == 0 {
+		fmt.Println("No services to build")
+		return
+	}
+
+	fmt.Println()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "SERVICE\tIMAGE\tDURATION\tSIZE\tSTATUS")
+	for _, result := range results {
+		status := "built"
+		size := units.HumanSize(float64(result.Size))
+		if result.Skipped {
+			status = "unchanged"
+		} else if result.Err != nil {
+			status = "failed"
+			size = "-"
+		}
+		fmt.Fprintf(
+			writer,
+			"%v\t%v\t%v\t%v\t%v\n",
+			result.Service,
+			result.Image,
+			result.Duration.Round(time.Millisecond),
+			size,
+			status,
+		)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,218 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+const dockerignoreFilename = ".dockerignore"
+
+// readDockerignore returns the exclusion patterns contained in the .dockerignore file at the root of
+// the build context, if present.  Patterns are cleaned in the same way as the docker CLI.
+func readDockerignore(contextPath string) ([]string, error) {
+	f, err := os.Open(filepath.Join(contextPath, dockerignoreFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	excludes := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		invert := strings.HasPrefix(pattern, "!")
+		if invert {
+			pattern = strings.TrimSpace(pattern[1:])
+		}
+		if len(pattern) > 0 {
+			pattern = filepath.Clean(pattern)
+			pattern = filepath.ToSlash(pattern)
+			if len(pattern) > 1 && pattern[0] == '/' {
+				pattern = pattern[1:]
+			}
+		}
+		if invert {
+			pattern = "!" + pattern
+		}
+
+		excludes = append(excludes, pattern)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read %v: %w", dockerignoreFilename, err)
+	}
+
+	return excludes, nil
+}
+
+// relativeDockerfile returns the path of the dockerfile relative to the build context, which is
+// how the engine expects to receive it.  Dockerfiles outside of the context are not supported.
+func relativeDockerfile(contextPath, dockerfilePath string) (string, error) {
+	relPath, err := filepath.Rel(contextPath, dockerfilePath)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(relPath, "..") {
+		return "", fmt.Errorf("Dockerfile %v must be located within the build context %v", dockerfilePath, contextPath)
+	}
+
+	return filepath.ToSlash(relPath), nil
+}
+
+// contextExcludes returns the exclusion patterns for the build context.  The dockerfile and .dockerignore
+// are never excluded, since the engine needs them to perform the build.
+func contextExcludes(contextPath, relDockerfile string) ([]string, error) {
+	excludes, err := readDockerignore(contextPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(excludes) > 0 {
+		excludes = append(excludes, "!"+dockerignoreFilename, "!"+relDockerfile)
+	}
+
+	return excludes, nil
+}
+
+// walkBuildContext walks the build context in lexical order, invoking walkFunc for every path which
+// isn't excluded.  relPath is always slash separated and relative to the context root.
+func walkBuildContext(contextPath string, excludes []string, walkFunc func(path, relPath string, info os.FileInfo) error) error {
+	pm, err := fileutils.NewPatternMatcher(excludes)
+	if err != nil {
+		return fmt.Errorf("Invalid %v pattern: %w", dockerignoreFilename, err)
+	}
+
+	return filepath.Walk(contextPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(contextPath, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+
+		excluded, err := pm.Matches(relPath)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// Excluded directories can only be skipped outright when no exception could re-include a child
+			if info.IsDir() && !pm.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		return walkFunc(path, filepath.ToSlash(relPath), info)
+	})
+}
+
+// tarBuildContext streams the build context as an uncompressed tar archive, honouring .dockerignore
+func tarBuildContext(contextPath string, excludes []string) io.ReadCloser {
+	reader, writer := io.Pipe()
+
+	go func() {
+		tw := tar.NewWriter(writer)
+		err := walkBuildContext(contextPath, excludes, func(path, relPath string, info os.FileInfo) error {
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				var err error
+				link, err = os.Readlink(path)
+				if err != nil {
+					return err
+				}
+			}
+
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = relPath
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			// Ownership is meaningless inside the engine, so normalise it for reproducibility
+			header.Uid, header.Gid = 0, 0
+			header.Uname, header.Gname = "", ""
+
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	return reader
+}
+
+// hashBuildContext returns a hex encoded SHA256 digest over the paths, modes and contents of every file
+// in the build context which isn't excluded
+func hashBuildContext(contextPath string, excludes []string) (string, error) {
+	hash := sha256.New()
+	err := walkBuildContext(contextPath, excludes, func(path, relPath string, info os.FileInfo) error {
+		fmt.Fprintf(hash, "%v\x00%v\x00", relPath, info.Mode())
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "%v\x00", link)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if _, err := io.Copy(hash, f); err != nil {
+				return err
+			}
+			hash.Write([]byte{0})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Unable to hash build context %v: %w", contextPath, err)
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"box/manifest"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/opencontainers/go-digest"
+)
+
+// Aux message identifier under which the engine emits BuildKit progress
+const buildkitTraceID = "moby.buildkit.trace"
+
+// buildkitTrace collates BuildKit status updates into plain text progress output, similar to the
+// docker CLI's "plain" progress mode
+type buildkitTrace struct {
+	steps     map[digest.Digest]int
+	completed map[digest.Digest]bool
+	output    *strings.Builder
+}
+
+func newBuildkitTrace(output *strings.Builder) *buildkitTrace {
+	return &buildkitTrace{
+		steps:     map[digest.Digest]int{},
+		completed: map[digest.Digest]bool{},
+		output:    output,
+	}
+}
+
+// step returns the step number associated with the vertex digest, allocating a new one if required
+func (bt *buildkitTrace) step(dgst digest.Digest) int {
+	if n, ok := bt.steps[dgst]; ok {
+		return n
+	}
+	n := len(bt.steps) + 1
+	bt.steps[dgst] = n
+	return n
+}
+
+// Write decodes a moby.buildkit.trace aux payload and writes the progress it describes to the output
+func (bt *buildkitTrace) Write(aux json.RawMessage) error {
+	var data []byte
+	if err := json.Unmarshal(aux, &data); err != nil {
+		return err
+	}
+
+	status := controlapi.StatusResponse{}
+	if err := status.Unmarshal(data); err != nil {
+		return err
+	}
+
+	for _, vertex := range status.Vertexes {
+		_, seen := bt.steps[vertex.Digest]
+		n := bt.step(vertex.Digest)
+		if !seen && vertex.Name != "" {
+			fmt.Fprintf(bt.output, "#%v %v\n", n, vertex.Name)
+		}
+		if vertex.Error != "" {
+			fmt.Fprintf(bt.output, "#%v ERROR: %v\n", n, vertex.Error)
+		} else if vertex.Completed != nil && !bt.completed[vertex.Digest] {
+			bt.completed[vertex.Digest] = true
+			if vertex.Cached {
+				fmt.Fprintf(bt.output, "#%v CACHED\n", n)
+			} else {
+				fmt.Fprintf(bt.output, "#%v DONE\n", n)
+			}
+		}
+	}
+
+	for _, log := range status.Logs {
+		n := bt.step(log.Vertex)
+		for _, line := range strings.Split(strings.TrimRight(string(log.Msg), "\n"), "\n") {
+			fmt.Fprintf(bt.output, "#%v %v\n", n, line)
+		}
+	}
+
+	return nil
+}
+
+// startBuildSession starts a BuildKit session through the engine, which serves the supplied build
+// secrets to the builder on request.  Secret values never leave the session, nor are they written
+// to the image.  The session must be closed once the build has completed.
+func (rt *Runtime) startBuildSession(ctx context.Context, secrets []manifest.BuildSecret) (*session.Session, error) {
+	sess, err := session.NewSession(ctx, "box", "")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create build session: %w", err)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("runtime.startBuildSession: %w", err)
+	}
+
+	sources := []secretsprovider.Source{}
+	for _, secret := range secrets {
+		source := secretsprovider.Source{
+			ID:  secret.ID,
+			Env: secret.Env,
+		}
+		if secret.Src != "" {
+			source.FilePath, err = secret.GetSrcPath(dir)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sources = append(sources, source)
+	}
+	store, err := secretsprovider.NewStore(sources)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load build secrets: %w", err)
+	}
+	sess.Allow(secretsprovider.NewSecretProvider(store))
+
+	dialer := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+		return rt.Client.DialHijack(ctx, "/session", proto, meta)
+	}
+	go sess.Run(ctx, dialer)
+
+	return sess, nil
+}
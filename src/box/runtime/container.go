@@ -6,63 +6,135 @@ import (
 	"os"
 
 	"github.com/docker/docker/api/types/container"
+	mounttypes "github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
 )
 
-// CreateContainer creates a container using the Runtime object, from a provided Service manifest
-func (rt *Runtime) CreateContainer(service *manifest.Service) (*container.ContainerCreateCreatedBody, error) {
-	hostname := service.GetHostname()
-	containerName := fmt.Sprint("%v%v", boxContainerPrefix, service.Name)
+// defaultRestartPolicy returns the restart policy for services which don't specify one.  In production
+// services are restarted unless stopped, except for one-shot services that others wait on to complete.
+func (rt *Runtime) defaultRestartPolicy(service *manifest.Service) string {
+	if rt.Production == false {
+		return manifest.RestartNo
+	}
+
+	for _, other := range rt.Manifest.Services {
+		if dep, ok := other.DependsOn[service.Name]; ok && dep.Condition == manifest.ConditionServiceCompletedSuccessfully {
+			return manifest.RestartNo
+		}
+	}
+
+	return manifest.RestartUnlessStopped
+}
+
+// slotHostname returns the hostname of a single replica of the service.  A slot of 0 indicates
+// that the service container is not slotted.
+func slotHostname(service *manifest.Service, slot int) string {
+	if slot == 0 {
+		return service.GetHostname()
+	}
+
+	return fmt.Sprintf("%v_%v", service.GetHostname(), slot)
+}
 
-	// If a routing exists, new containers automatically get a "_1" suffix,
-	// same goes for hostname
-	if service.Routing.Path.Pattern != "" {
-		hostname = fmt.Sprintf("%v_1", hostname)
-		containerName = fmt.Sprintf("%v_1", containerName)
+// containerName returns the container name of a single replica of the service
+func containerName(service *manifest.Service, slot int) string {
+	if slot == 0 {
+		return fmt.Sprintf("%v%v", boxContainerPrefix, service.Name)
+	}
+
+	return fmt.Sprintf("%v%v_%v", boxContainerPrefix, service.Name, slot)
+}
+
+// dataDir returns the host directory under which "@/" host mounts are placed
+func (rt *Runtime) dataDir() (string, error) {
+	if rt.Production == true {
+		return ProdDataDir, nil
+	}
+
+	dataDir, err := rt.Config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dataDir); err != nil {
+		return "", fmt.Errorf("Unable to stat data directory %v: %w", dataDir, err)
+	}
+
+	return dataDir, nil
+}
+
+// CreateContainer creates a container using the Runtime object, from a provided Service manifest.  Manifest
+// services run as one or more replicas, each in its own numbered slot, while core services use slot 0.
+func (rt *Runtime) CreateContainer(service *manifest.Service, slot int) (*container.ContainerCreateCreatedBody, error) {
+	hostname := slotHostname(service, slot)
+
+	env, err := service.GetEnv(rt.resolveSecret)
+	if err != nil {
+		return nil, err
 	}
 
 	contConfig := container.Config{
 		Hostname:     hostname,
-		Env:          service.GetEnv(),
+		Env:          env,
 		Image:        service.GetImage(rt.Config.ProjectNameHash()),
 		ExposedPorts: service.GetContainerPortSet(),
+		Healthcheck:  service.GetHealthConfig(),
+		Labels:       rt.serviceLabels(service, slot),
+		Cmd:          strslice.StrSlice(service.Command),
+		Entrypoint:   strslice.StrSlice(service.Entrypoint),
+		User:         service.User,
+		WorkingDir:   service.WorkingDir,
+		StopTimeout:  service.GetStopTimeout(),
 	}
 
-	var dataDir string
-	if rt.Production == true {
-		dataDir = prodDataDir
-	} else {
-		dataDir, err := rt.Config.DataDir()
-		if err != nil {
-			return nil, err
-		}
-		if _, err := os.Stat(dataDir); err != nil {
-			return nil, fmt.Errorf("Unable to stat data directory %v: %w", dataDir, err)
-		}
+	dataDir, err := rt.dataDir()
+	if err != nil {
+		return nil, err
 	}
-	mounts := service.GetHostMounts(dataDir)
+	mounts := service.GetHostMounts(dataDir, rt.volumeName)
 	for _, mount := range mounts {
-		if _, err := os.Stat(mount.Source); err != nil {
-			// More than likely the mount point doesn't exist on the host, so make it
-			// It will be owned be the user running this command
-			err = os.MkdirAll(mount.Source, os.FileMode(755))
-			if err != nil {
-				return nil, fmt.Errorf("Unable to make host mount directory %v: %w", mount.Source, err)
-			}
+		if mount.Type != mounttypes.TypeBind {
+			continue
+		}
+		// More than likely the mount point doesn't exist on the host, so make it
+		// It will be owned be the user running this command
+		made, err := rt.host().EnsureDir(mount.Source)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to make host mount directory %v: %w", mount.Source, err)
+		}
+		if made {
+			fmt.Printf("Prepared host bind mount point: %v\n", mount.Source)
 		}
 	}
 
 	hostConfig := container.HostConfig{
-		PortBindings: service.GetHostPortMap(),
-		Mounts:       mounts,
+		PortBindings:  service.GetHostPortMap(),
+		Mounts:        mounts,
+		Resources:     service.GetResources(),
+		RestartPolicy: service.GetRestartPolicy(rt.defaultRestartPolicy(service)),
+	}
+
+	// Attach to the project network, reachable by the service hostname, which is shared by all replicas,
+	// as well as the slotted one
+	aliases := []string{service.GetHostname()}
+	if slot > 0 {
+		aliases = append(aliases, hostname)
+	}
+	networkConfig := network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			rt.networkName(): {
+				Aliases: aliases,
+			},
+		},
 	}
 
 	containerBody, err := rt.Client.ContainerCreate(
 		rt.Context,
 		&contConfig,
 		&hostConfig,
+		&networkConfig,
 		nil,
-		nil,
-		containerName,
+		containerName(service, slot),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("Create container failed: %w", err)
@@ -3,6 +3,7 @@ package runtime
 import (
 	"box/manifest"
 	"fmt"
+	"sort"
 )
 
 type tranche []manifest.Service
@@ -17,9 +18,9 @@ func makeDependencyTranches(services []manifest.Service) ([]tranche, error) {
 	tranches := []tranche{}
 	for len(remainingServices) > 0 {
 		current := []manifest.Service{}
-		for serviceName, service := range remainingServices {
+		for _, service := range remainingServices {
 			remainingDeps := false
-			for _, dep := range service.DependsOn {
+			for dep := range service.DependsOn {
 				if _, ok := remainingServices[dep]; ok {
 					remainingDeps = true
 					break
@@ -27,7 +28,6 @@ func makeDependencyTranches(services []manifest.Service) ([]tranche, error) {
 			}
 			if remainingDeps == false {
 				current = append(current, service)
-				delete(remainingServices, serviceName)
 			}
 		}
 
@@ -35,6 +35,12 @@ func makeDependencyTranches(services []manifest.Service) ([]tranche, error) {
 			return nil, fmt.Errorf("Impossible depends_on tree in services")
 		}
 
+		// Removed once the whole tranche is known, so that a service never shares a tranche with its dependency
+		sort.Slice(current, func(i, j int) bool { return current[i].Name < current[j].Name })
+		for _, service := range current {
+			delete(remainingServices, service.Name)
+		}
+
 		tranches = append(tranches, current)
 	}
 
@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"box/manifest"
+	"reflect"
+	"testing"
+)
+
+func TestMakeDependencyTranches(t *testing.T) {
+	service := func(name string, deps ...string) manifest.Service {
+		dependsOn := manifest.Dependencies{}
+		for _, dep := range deps {
+			dependsOn[dep] = manifest.Dependency{Condition: manifest.ConditionServiceStarted}
+		}
+		return manifest.Service{Name: name, DependsOn: dependsOn}
+	}
+
+	tests := []struct {
+		name     string
+		services []manifest.Service
+		want     [][]string
+		wantErr  bool
+	}{
+		{
+			name:     "no dependencies",
+			services: []manifest.Service{service("web"), service("db")},
+			want:     [][]string{{"db", "web"}},
+		},
+		{
+			name:     "chain",
+			services: []manifest.Service{service("web", "api"), service("api", "db"), service("db")},
+			want:     [][]string{{"db"}, {"api"}, {"web"}},
+		},
+		{
+			name:     "diamond",
+			services: []manifest.Service{service("web", "api", "worker"), service("api", "db"), service("worker", "db"), service("db")},
+			want:     [][]string{{"db"}, {"api", "worker"}, {"web"}},
+		},
+		{
+			name:     "dependent listed after its dependency",
+			services: []manifest.Service{service("db"), service("api", "db"), service("web", "api")},
+			want:     [][]string{{"db"}, {"api"}, {"web"}},
+		},
+		{
+			name:     "cycle",
+			services: []manifest.Service{service("a", "b"), service("b", "a"), service("c")},
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Map iteration order varies between runs, which a single run may not expose
+			for i := 0; i < 20; i++ {
+				tranches, err := makeDependencyTranches(test.services)
+				if test.wantErr {
+					if err == nil {
+						t.Fatalf("expected an error, got %v tranches", len(tranches))
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				got := [][]string{}
+				for _, tr := range tranches {
+					names := []string{}
+					for _, service := range tr {
+						names = append(names, service.Name)
+					}
+					got = append(got, names)
+				}
+				if !reflect.DeepEqual(got, test.want) {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
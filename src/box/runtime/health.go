@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"box/manifest"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// DefaultStartupTimeout is the time allowed for all services to start and satisfy their dependency conditions
+const DefaultStartupTimeout = 5 * time.Minute
+
+const conditionPollInterval = time.Second
+
+// waitForCondition blocks until the container for the named service satisfies the dependency condition,
+// or the context expires
+func (rt *Runtime) waitForCondition(ctx context.Context, serviceName, containerID, condition string) error {
+	if condition == manifest.ConditionServiceStarted {
+		// Services are only started once all of the preceding tranche has started
+		return nil
+	}
+
+	for {
+		inspect, err := rt.Client.ContainerInspect(ctx, containerID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("Timed out waiting for service %v to satisfy %v", serviceName, condition)
+			}
+			return fmt.Errorf("Unable to inspect container for service %v: %w", serviceName, err)
+		}
+
+		state := inspect.State
+		exited := state.Status == "exited" || state.Status == "dead"
+		switch condition {
+		case manifest.ConditionServiceHealthy:
+			if state.Health != nil {
+				switch state.Health.Status {
+				case types.Healthy:
+					return nil
+				case types.Unhealthy:
+					return fmt.Errorf("Service %v is unhealthy", serviceName)
+				}
+			}
+			if exited {
+				return fmt.Errorf("Service %v exited with code %v before becoming healthy", serviceName, state.ExitCode)
+			}
+		case manifest.ConditionServiceCompletedSuccessfully:
+			if exited {
+				if state.ExitCode == 0 {
+					return nil
+				}
+				return fmt.Errorf("Service %v exited with code %v", serviceName, state.ExitCode)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Timed out waiting for service %v to satisfy %v", serviceName, condition)
+		case <-time.After(conditionPollInterval):
+		}
+	}
+}
+
+// waitForDependencies blocks until every replica of each dependency of the service satisfies its condition
+func (rt *Runtime) waitForDependencies(ctx context.Context, service *manifest.Service, containerBodiesByServiceName map[string][]container.ContainerCreateCreatedBody) error {
+	for _, name := range service.DependsOn.Names() {
+		condition := service.DependsOn[name].Condition
+		if condition != manifest.ConditionServiceStarted {
+			fmt.Printf("Service %v is waiting for %v to satisfy %v\n", service.Name, name, condition)
+		}
+
+		for _, containerBody := range containerBodiesByServiceName[name] {
+			err := rt.waitForCondition(ctx, name, containerBody.ID, condition)
+			if err != nil {
+				return fmt.Errorf("Unable to start service %v: %w", service.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package runtime
+
+import (
+	"box/sshconn"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// hostFS prepares the directories and files which containers mount from the host the engine runs on
+type hostFS interface {
+	// EnsureDir makes the directory along with any parents, returning whether it had to be made
+	EnsureDir(path string) (bool, error)
+	WriteFile(filename string, data []byte, mode os.FileMode) error
+}
+
+// localFS is the filesystem of this machine, for an engine running on it
+type localFS struct{}
+
+func (localFS) EnsureDir(path string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+
+	return true, os.MkdirAll(path, os.FileMode(0755))
+}
+
+func (localFS) WriteFile(filename string, data []byte, mode os.FileMode) error {
+	return ioutil.WriteFile(filename, data, mode)
+}
+
+// remoteFS is the filesystem of the droplet, reached over the same SSH connection as its engine
+type remoteFS struct {
+	conn *sshconn.SSHConn
+}
+
+func (fs remoteFS) EnsureDir(path string) (bool, error) {
+	if strings.ContainsAny(path, "'\n") {
+		return false, fmt.Errorf("Invalid remote directory: %v", path)
+	}
+
+	output, err := fs.conn.Output(fmt.Sprintf("test -d '%v' || { mkdir -p -m 0755 '%v' && echo made; }", path, path))
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(string(output)) == "made", nil
+}
+
+func (fs remoteFS) WriteFile(filename string, data []byte, mode os.FileMode) error {
+	return fs.conn.WriteFile(filename, data, mode)
+}
+
+// host returns the filesystem of the host the engine runs on
+func (rt *Runtime) host() hostFS {
+	if rt.hostFS == nil {
+		return localFS{}
+	}
+
+	return rt.hostFS
+}
@@ -0,0 +1,94 @@
+package runtime
+
+import (
+	"box/manifest"
+	"box/version"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+)
+
+// Labels applied to every container, network and image created by box
+const (
+	labelPrefix       = "box.do."
+	LabelProject      = labelPrefix + "project"
+	LabelService      = labelPrefix + "service"
+	LabelSlot         = labelPrefix + "slot"
+	LabelManifestHash = labelPrefix + "manifest-hash"
+	LabelVersion      = labelPrefix + "version"
+	LabelBuildHash    = labelPrefix + "build-hash"
+	LabelProfiles     = labelPrefix + "profiles"
+	LabelEnv          = labelPrefix + "env"
+)
+
+// projectLabels returns the labels which identify a resource as belonging to the current project
+func (rt *Runtime) projectLabels() map[string]string {
+	return map[string]string{
+		LabelProject:      rt.Manifest.Project,
+		LabelManifestHash: rt.Manifest.Hash,
+		LabelVersion:      version.Version,
+		LabelProfiles:     strings.Join(rt.Profiles, ","),
+		LabelEnv:          rt.environment(),
+	}
+}
+
+// serviceLabels returns the labels for a resource belonging to a specific service.  A slot of 0
+// indicates that the service container is not slotted.
+func (rt *Runtime) serviceLabels(service *manifest.Service, slot int) map[string]string {
+	labels := rt.projectLabels()
+	labels[LabelService] = service.Name
+	if slot > 0 {
+		labels[LabelSlot] = strconv.Itoa(slot)
+	}
+
+	return labels
+}
+
+// projectFilter returns a filter which matches only resources belonging to the current project
+func (rt *Runtime) projectFilter() filters.Args {
+	return filters.NewArgs(
+		filters.Arg("label", LabelProject+"="+rt.Manifest.Project),
+	)
+}
+
+// managedFilter returns a filter which matches resources belonging to any box project
+func managedFilter() filters.Args {
+	return filters.NewArgs(
+		filters.Arg("label", LabelProject),
+	)
+}
+
+// legacyContainers returns the containers created by versions of box which predate labels, which are only
+// recognizable by their name.  Stopped containers are included when all is set.
+func legacyContainers(ctx context.Context, cli *client.Client, all bool) ([]types.Container, error) {
+	containers, err := cli.ContainerList(
+		ctx,
+		types.ContainerListOptions{
+			All:     all,
+			Filters: filters.NewArgs(filters.Arg("name", boxContainerPrefix)),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	legacy := []types.Container{}
+	for _, container := range containers {
+		if _, labeled := container.Labels[LabelProject]; labeled {
+			continue
+		}
+		// The name filter matches anywhere in the name
+		for _, name := range container.Names {
+			if strings.HasPrefix(strings.TrimPrefix(name, "/"), boxContainerPrefix) {
+				legacy = append(legacy, container)
+				break
+			}
+		}
+	}
+
+	return legacy, nil
+}
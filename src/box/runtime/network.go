@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+)
+
+// networkName returns the name of the bridge network shared by all containers in the project
+func (rt *Runtime) networkName() string {
+	return fmt.Sprintf("%v%v", boxContainerPrefix, rt.Manifest.Project)
+}
+
+// EnsureNetwork creates the project network unless it already exists
+func (rt *Runtime) EnsureNetwork() error {
+	networks, err := rt.Client.NetworkList(
+		rt.Context,
+		types.NetworkListOptions{
+			Filters: rt.projectFilter(),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("runtime.EnsureNetwork: %w", err)
+	}
+
+	for _, network := range networks {
+		if network.Name == rt.networkName() {
+			return nil
+		}
+	}
+
+	fmt.Printf("Creating network %v...", rt.networkName())
+	_, err = rt.Client.NetworkCreate(
+		rt.Context,
+		rt.networkName(),
+		types.NetworkCreate{
+			CheckDuplicate: true,
+			Driver:         "bridge",
+			Labels:         rt.projectLabels(),
+		},
+	)
+	if err != nil {
+		fmt.Println("Error")
+		return fmt.Errorf("Unable to create network %v: %w", rt.networkName(), err)
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+// RemoveNetworks removes all networks belonging to the project
+func (rt *Runtime) RemoveNetworks() error {
+	networks, err := rt.Client.NetworkList(
+		rt.Context,
+		types.NetworkListOptions{
+			Filters: rt.projectFilter(),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("runtime.RemoveNetworks: %w", err)
+	}
+
+	for _, network := range networks {
+		fmt.Printf("Removing network %v...", network.Name)
+		if err = rt.Client.NetworkRemove(rt.Context, network.ID); err != nil {
+			fmt.Println("Error")
+		} else {
+			fmt.Println("Done")
+		}
+	}
+
+	return nil
+}
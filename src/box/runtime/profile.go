@@ -0,0 +1,99 @@
+package runtime
+
+import (
+	"box/manifest"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// environment returns the runtime environment services are selected for
+func (rt *Runtime) environment() string {
+	if rt.Production == true {
+		return manifest.EnvProd
+	}
+
+	return manifest.EnvDev
+}
+
+// isActive returns true if the service runs in the current environment with the active profiles
+func (rt *Runtime) isActive(service *manifest.Service) bool {
+	return rt.Manifest.ExcludedReason(service, rt.environment(), rt.Profiles) == ""
+}
+
+// activeServices returns the manifest services which run in the current environment with the active
+// profiles.  Every active profile must be selected by some service, and an active service can't depend
+// on an excluded one.
+func (rt *Runtime) activeServices() ([]*manifest.Service, error) {
+	known := map[string]bool{}
+	for _, profile := range rt.Manifest.Profiles() {
+		known[profile] = true
+	}
+	for _, profile := range rt.Profiles {
+		if known[profile] == false {
+			return nil, fmt.Errorf("Profile %v is not selected by the runtime_env of any service", profile)
+		}
+	}
+
+	services := []*manifest.Service{}
+	for _, service := range rt.Manifest.Services {
+		if !rt.isActive(service) {
+			continue
+		}
+
+		for _, name := range service.DependsOn.Names() {
+			dep := rt.Manifest.Services[name]
+			if reason := rt.Manifest.ExcludedReason(dep, rt.environment(), rt.Profiles); reason != "" {
+				return nil, fmt.Errorf("Service %v depends on %v, which is excluded: %v", service.Name, name, reason)
+			}
+		}
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+// RunningProfiles returns the profiles the project was started with, and whether the project is running
+func (rt *Runtime) RunningProfiles() ([]string, bool, error) {
+	containers, err := rt.Client.ContainerList(
+		rt.Context,
+		types.ContainerListOptions{
+			Filters: rt.projectFilter(),
+		},
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(containers) == 0 {
+		return nil, false, nil
+	}
+
+	profiles := []string{}
+	for _, profile := range strings.Split(containers[0].Labels[LabelProfiles], ",") {
+		if profile != "" {
+			profiles = append(profiles, profile)
+		}
+	}
+
+	return profiles, true, nil
+}
+
+// RunningEnvironment returns the runtime environment the project was started in, which is empty if the project
+// isn't running or was started by a version of box which didn't record it
+func (rt *Runtime) RunningEnvironment() (string, error) {
+	containers, err := rt.Client.ContainerList(
+		rt.Context,
+		types.ContainerListOptions{
+			Filters: rt.projectFilter(),
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(containers) == 0 {
+		return "", nil
+	}
+
+	return containers[0].Labels[LabelEnv], nil
+}
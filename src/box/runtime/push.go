@@ -0,0 +1,250 @@
+package runtime
+
+import (
+	"box/manifest"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// RegistryPort is the port the registry service listens on, on the droplet's loopback address
+const RegistryPort = 5000
+
+// PushResult holds the outcome of pushing the image for a single service
+type PushResult struct {
+	Service  string
+	Image    string
+	Hash     string
+	Skipped  bool
+	Duration time.Duration
+	Err      error
+}
+
+// EngineIsLocal returns whether the engine runs directly on this machine, sharing its loopback address, which is
+// required to push to a registry tunnelled to it.  An engine on another host or in a virtual machine, such as
+// Docker Desktop's, or a rootless engine with its own network namespace, can't reach the tunnel.
+func (rt *Runtime) EngineIsLocal() (bool, error) {
+	if !strings.HasPrefix(rt.Client.DaemonHost(), "unix://") {
+		return false, nil
+	}
+
+	info, err := rt.Client.Info(rt.Context)
+	if err != nil {
+		return false, fmt.Errorf("runtime.EngineIsLocal: %w", err)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false, fmt.Errorf("runtime.EngineIsLocal: %w", err)
+	}
+	if info.Name != hostname {
+		return false, nil
+	}
+	for _, option := range info.SecurityOptions {
+		if strings.Contains(option, "rootless") {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// PushImage transfers the image built for a single service to the remote runtime's engine.  Unless force is set,
+// the transfer is skipped when the remote engine already holds an image built from identical inputs.  Through the
+// registry at registryHost, only the layers the droplet's registry lacks are sent, otherwise the image is copied
+// whole.
+func (rt *Runtime) PushImage(service *manifest.Service, remote *Runtime, registryHost string, force bool) *PushResult {
+	result := &PushResult{
+		Service: service.Name,
+		Image:   service.GetImage(rt.Config.ProjectNameHash()),
+	}
+
+	started := time.Now()
+	inspect, _, err := rt.Client.ImageInspectWithRaw(rt.Context, result.Image)
+	if err != nil {
+		result.Err = fmt.Errorf("Unable to inspect image %v: %w", result.Image, err)
+		return result
+	}
+	if inspect.Config != nil {
+		result.Hash = inspect.Config.Labels[LabelBuildHash]
+	}
+
+	if force == false && result.Hash != "" {
+		remoteHash, err := imageBuildHash(remote, result.Image)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if remoteHash == result.Hash {
+			result.Skipped = true
+			result.Duration = time.Since(started)
+			return result
+		}
+	}
+
+	if registryHost != "" {
+		result.Err = rt.pushImage(result.Image, registryHost)
+		if result.Err == nil {
+			result.Err = remote.pullImage(result.Image, fmt.Sprintf("127.0.0.1:%v", RegistryPort))
+		}
+	} else {
+		result.Err = rt.copyImage(result.Image, remote)
+	}
+	result.Duration = time.Since(started)
+
+	return result
+}
+
+// imageBuildHash returns the build hash of the image held by the runtime's engine, which is empty if the engine
+// doesn't have the image or it wasn't built by box
+func imageBuildHash(rt *Runtime, image string) (string, error) {
+	inspect, _, err := rt.Client.ImageInspectWithRaw(rt.Context, image)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("Unable to inspect image %v on the droplet: %w", image, err)
+	}
+	if inspect.Config == nil {
+		return "", nil
+	}
+
+	return inspect.Config.Labels[LabelBuildHash], nil
+}
+
+// readJSONMessages consumes the progress of an engine operation, returning the error it reports, if any
+func readJSONMessages(reader io.Reader) error {
+	decoder := json.NewDecoder(reader)
+	for {
+		msg := jsonmessage.JSONMessage{}
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("Unable to read progress: %w", err)
+		}
+
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+	}
+}
+
+func (rt *Runtime) pushImage(image, registryHost string) error {
+	target := fmt.Sprintf("%v/%v", registryHost, image)
+	if err := rt.Client.ImageTag(rt.Context, image, target); err != nil {
+		return fmt.Errorf("Unable to tag image %v: %w", image, err)
+	}
+	// Only the tag is removed, which is of no use once pushed
+	defer rt.Client.ImageRemove(rt.Context, target, types.ImageRemoveOptions{})
+
+	reader, err := rt.Client.ImagePush(rt.Context, target, types.ImagePushOptions{})
+	if err != nil {
+		return fmt.Errorf("Unable to push image %v: %w", image, err)
+	}
+	defer reader.Close()
+
+	if err := readJSONMessages(reader); err != nil {
+		return fmt.Errorf("Unable to push image %v: %w", image, err)
+	}
+
+	return nil
+}
+
+// pullImage pulls the image from the registry at registryHost, as seen by the runtime's engine, tagging it with
+// its own name
+func (rt *Runtime) pullImage(image, registryHost string) error {
+	source := fmt.Sprintf("%v/%v", registryHost, image)
+	reader, err := rt.Client.ImagePull(rt.Context, source, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("Unable to pull image %v on the droplet: %w", image, err)
+	}
+	defer reader.Close()
+	if err := readJSONMessages(reader); err != nil {
+		return fmt.Errorf("Unable to pull image %v on the droplet: %w", image, err)
+	}
+
+	if err := rt.Client.ImageTag(rt.Context, source, image); err != nil {
+		return fmt.Errorf("Unable to tag image %v on the droplet: %w", image, err)
+	}
+	// Only the tag is removed, the registry keeps the image
+	rt.Client.ImageRemove(rt.Context, source, types.ImageRemoveOptions{})
+
+	return nil
+}
+
+// copyImage copies the image whole from the runtime's engine to the remote runtime's
+func (rt *Runtime) copyImage(image string, remote *Runtime) error {
+	reader, err := rt.Client.ImageSave(rt.Context, []string{image})
+	if err != nil {
+		return fmt.Errorf("Unable to save image %v: %w", image, err)
+	}
+	defer reader.Close()
+
+	resp, err := remote.Client.ImageLoad(remote.Context, reader, true)
+	if err != nil {
+		return fmt.Errorf("Unable to load image %v on the droplet: %w", image, err)
+	}
+	defer resp.Body.Close()
+	if err := readJSONMessages(resp.Body); err != nil {
+		return fmt.Errorf("Unable to load image %v on the droplet: %w", image, err)
+	}
+
+	return nil
+}
+
+// Push transfers the images of all active services which have a build context to the remote runtime's engine,
+// through the registry at registryHost unless it's empty.  Images the remote engine already holds, built from
+// identical inputs, are skipped unless force is set.
+func (rt *Runtime) Push(remote *Runtime, registryHost string, force bool) ([]*PushResult, error) {
+	activeServices, err := rt.activeServices()
+	if err != nil {
+		return nil, err
+	}
+
+	services := []*manifest.Service{}
+	for _, service := range activeServices {
+		if service.Build.Context != "" {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	if len(services) == 0 {
+		fmt.Println("No images to push")
+		return nil, nil
+	}
+
+	results := []*PushResult{}
+	failed := []string{}
+	for _, service := range services {
+		fmt.Printf("Pushing image for %v...", service.Name)
+		result := rt.PushImage(service, remote, registryHost, force)
+		results = append(results, result)
+		if result.Skipped {
+			fmt.Println("Unchanged, skipping")
+		} else if result.Err != nil {
+			fmt.Println("Failed")
+			fmt.Println("Error:", result.Err)
+			failed = append(failed, service.Name)
+		} else {
+			fmt.Printf("Done in %v\n", result.Duration.Round(time.Millisecond))
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("Error pushing %v", strings.Join(failed, ", "))
+	}
+
+	return results, nil
+}
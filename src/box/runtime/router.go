@@ -0,0 +1,248 @@
+package runtime
+
+import (
+	"box/manifest"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// Directory under the data directory which holds the generated router configuration
+const routerConfigDir = "router"
+
+// Location of the generated router configuration inside the router container
+const routerConfigMountPoint = "/etc/nginx/box"
+
+const (
+	upstreamsFilename = "upstreams.conf"
+	locationsFilename = "locations.conf"
+)
+
+// Passive health checking, a replica which fails this many times in a row is taken out of rotation
+// for the fail timeout
+const (
+	upstreamMaxFails    = 3
+	upstreamFailTimeout = "10s"
+)
+
+const routerExecPollInterval = 100 * time.Millisecond
+
+var nginxQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// upstreamName returns the name of the nginx upstream which balances across the replicas of a service
+func upstreamName(service *manifest.Service) string {
+	return fmt.Sprintf("box_%v", service.Name)
+}
+
+// locationModifier returns the nginx location modifier which matches the routing path type
+func locationModifier(pathType string) string {
+	switch pathType {
+	case manifest.PathTypeExact:
+		return "= "
+	case manifest.PathTypeRegex:
+		return "~ "
+	}
+
+	return ""
+}
+
+// routedServices returns the active manifest services which have a routing, sorted by name
+func (rt *Runtime) routedServices() []*manifest.Service {
+	services := []*manifest.Service{}
+	for _, service := range rt.Manifest.Services {
+		if service.Routing.Path.Pattern != "" && rt.isActive(service) {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	return services
+}
+
+// writeRouterConfig generates an nginx upstream for each routed service, balancing across the provided
+// slots of the service, along with the location which routes to it.  A routed service without any
+// slots responds with 503 until it is scaled up.
+func (rt *Runtime) writeRouterConfig(slotsByService map[string][]int) error {
+	dataDir, err := rt.dataDir()
+	if err != nil {
+		return err
+	}
+
+	upstreams := bytes.Buffer{}
+	locations := bytes.Buffer{}
+	fmt.Fprintln(&upstreams, "# Generated by box, do not edit")
+	fmt.Fprintln(&locations, "# Generated by box, do not edit")
+
+	for _, service := range rt.routedServices() {
+		slots := slotsByService[service.Name]
+
+		fmt.Fprintf(
+			&locations,
+			"\nlocation %v\"%v\" {\n",
+			locationModifier(service.Routing.Path.Type),
+			nginxQuoter.Replace(service.Routing.Path.Pattern),
+		)
+		if len(slots) == 0 {
+			fmt.Fprintln(&locations, "  return 503;")
+			fmt.Fprintln(&locations, "}")
+			continue
+		}
+		fmt.Fprintf(&locations, "  proxy_pass http://%v;\n", upstreamName(service))
+		fmt.Fprintln(&locations, "  proxy_next_upstream error timeout http_502 http_503 http_504;")
+		fmt.Fprintln(&locations, "}")
+
+		fmt.Fprintf(&upstreams, "\nupstream %v {\n", upstreamName(service))
+		for _, slot := range slots {
+			fmt.Fprintf(
+				&upstreams,
+				"  server %v:%v max_fails=%v fail_timeout=%v;\n",
+				slotHostname(service, slot),
+				service.Routing.Port,
+				upstreamMaxFails,
+				upstreamFailTimeout,
+			)
+		}
+		fmt.Fprintln(&upstreams, "}")
+	}
+
+	configDir := filepath.Join(dataDir, routerConfigDir)
+	_, err = rt.host().EnsureDir(configDir)
+	if err != nil {
+		return fmt.Errorf("Unable to make router configuration directory %v: %w", configDir, err)
+	}
+
+	err = rt.host().WriteFile(filepath.Join(configDir, upstreamsFilename), upstreams.Bytes(), os.FileMode(0644))
+	if err != nil {
+		return fmt.Errorf("Unable to write router configuration: %w", err)
+	}
+	err = rt.host().WriteFile(filepath.Join(configDir, locationsFilename), locations.Bytes(), os.FileMode(0644))
+	if err != nil {
+		return fmt.Errorf("Unable to write router configuration: %w", err)
+	}
+
+	return nil
+}
+
+// execInContainer runs a command inside a running container and returns its combined output and exit code
+func (rt *Runtime) execInContainer(containerID string, cmd []string) (string, int, error) {
+	exec, err := rt.Client.ContainerExecCreate(
+		rt.Context,
+		containerID,
+		types.ExecConfig{
+			Cmd:          cmd,
+			AttachStdout: true,
+			AttachStderr: true,
+		},
+	)
+	if err != nil {
+		return "", 0, err
+	}
+
+	resp, err := rt.Client.ContainerExecAttach(rt.Context, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Close()
+
+	output := bytes.Buffer{}
+	_, err = stdcopy.StdCopy(&output, &output, resp.Reader)
+	if err != nil {
+		return "", 0, err
+	}
+
+	for {
+		inspect, err := rt.Client.ContainerExecInspect(rt.Context, exec.ID)
+		if err != nil {
+			return "", 0, err
+		}
+		if inspect.Running == false {
+			return output.String(), inspect.ExitCode, nil
+		}
+		time.Sleep(routerExecPollInterval)
+	}
+}
+
+// reloadRouter has the router pick up the generated configuration.  The configuration is tested first, since
+// nginx keeps serving with the old configuration should the new one be rejected.  Requests in flight are
+// completed by the old worker processes, so reloading doesn't interrupt service.
+func (rt *Runtime) reloadRouter() error {
+	name := containerName(&routerService, 0)
+	inspect, err := rt.Client.ContainerInspect(rt.Context, name)
+	if err != nil || inspect.State == nil || inspect.State.Running == false {
+		fmt.Println("Router is not running, skipping reload")
+		return nil
+	}
+
+	fmt.Print("Reloading router...")
+	for _, cmd := range [][]string{{"nginx", "-t"}, {"nginx", "-s", "reload"}} {
+		output, exitCode, err := rt.execInContainer(inspect.ID, cmd)
+		if err != nil {
+			fmt.Println("Error")
+			return fmt.Errorf("Unable to reload router: %w", err)
+		}
+		if exitCode != 0 {
+			fmt.Println("Error")
+			return fmt.Errorf("Router rejected the generated configuration:\n%v", output)
+		}
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+// serviceContainers returns all containers belonging to the manifest services, including stopped ones,
+// grouped by service name
+func (rt *Runtime) serviceContainers() (map[string][]types.Container, error) {
+	containers, err := rt.Client.ContainerList(
+		rt.Context,
+		types.ContainerListOptions{
+			All:     true,
+			Filters: rt.projectFilter(),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	containersByService := map[string][]types.Container{}
+	for _, container := range containers {
+		name := container.Labels[LabelService]
+		if _, ok := rt.Manifest.Services[name]; ok && container.Labels[LabelSlot] != "" {
+			containersByService[name] = append(containersByService[name], container)
+		}
+	}
+
+	return containersByService, nil
+}
+
+// runningSlots returns the slots of the running replicas of every manifest service, in ascending order
+func (rt *Runtime) runningSlots() (map[string][]int, error) {
+	containersByService, err := rt.serviceContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	slotsByService := map[string][]int{}
+	for name, containers := range containersByService {
+		for _, container := range containers {
+			slot, err := strconv.Atoi(container.Labels[LabelSlot])
+			if err != nil || container.State != "running" {
+				continue
+			}
+			slotsByService[name] = append(slotsByService[name], slot)
+		}
+		sort.Ints(slotsByService[name])
+	}
+
+	return slotsByService, nil
+}
@@ -1,17 +1,20 @@
 package runtime
 
 import (
-	"box/cmd"
 	"box/config"
 	"box/manifest"
+	"box/secrets"
+	"box/sshconn"
 	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -28,11 +31,16 @@ type runData struct {
 }
 
 type Runtime struct {
-	Manifest   *manifest.Manifest
-	Client     *client.Client
-	Context    context.Context
-	Production bool
-	Config     *config.Config
+	Manifest       *manifest.Manifest
+	Client         *client.Client
+	Context        context.Context
+	Production     bool
+	Config         *config.Config
+	StartupTimeout time.Duration
+	Profiles       []string
+	secrets        *secrets.Store
+	// Filesystem of a remote engine's host, nil for the local engine
+	hostFS hostFS
 }
 
 var routerService manifest.Service = manifest.Service{
@@ -46,15 +54,29 @@ var routerService manifest.Service = manifest.Service{
 	Volumes: []string{
 		"@/letsencrypt:/etc/letsencrypt",
 		"@/www/acme:/var/www/acme",
+		"@/" + routerConfigDir + ":" + routerConfigMountPoint,
 	},
 }
 
+// The production router is published on all interfaces, since it's the entrypoint for outside traffic
+var prodRouterService manifest.Service = manifest.Service{
+	Name:     routerService.Name,
+	Hostname: routerService.Hostname,
+	Image:    routerService.Image,
+	Ports: []string{
+		"0.0.0.0:80:80",
+		"0.0.0.0:443:443",
+	},
+	Volumes: routerService.Volumes,
+}
+
 var registryService manifest.Service = manifest.Service{
 	Name:     "registry",
 	Hostname: "box-registry",
 	Image:    "box-registry",
+	// Only reachable from the droplet itself, box pushes to it through an SSH tunnel
 	Ports: []string{
-		"5000:5000",
+		"127.0.0.1:5000:5000",
 	},
 	Volumes: []string{
 		"@/registry:/var/lib/registry",
@@ -83,19 +105,60 @@ type PullInfo struct {
 	ProgressDetail *ProgressDetail `json:"progressDetail"`
 }
 
-// All box managed containers will start with this prefix
+// All box managed container and network names will start with this prefix
 const boxContainerPrefix = "box__"
 
 var devServices = []manifest.Service{
 	routerService,
 }
 var prodServices = []manifest.Service{
-	routerService,
+	prodRouterService,
 	registryService,
 	cronService,
 }
 
-const prodDataDir = "/mnt/data"
+// coreServices returns the services box runs alongside the project's own.  In production, the router and cron
+// services are told the domain certificates are requested for, as when the droplet first started them.
+func (rt *Runtime) coreServices() []manifest.Service {
+	if rt.Production == false {
+		return devServices
+	}
+
+	services := append([]manifest.Service{}, prodServices...)
+	for i := range services {
+		switch services[i].Name {
+		case routerService.Name:
+			services[i].Environment = map[string]string{
+				"DOMAIN_NAME": rt.Config.BareDomainName,
+			}
+		case cronService.Name:
+			services[i].Environment = map[string]string{
+				"DOMAIN_NAME":          rt.Config.BareDomainName,
+				"EMAIL":                rt.Config.Email,
+				"NGINX_CONTAINER_NAME": containerName(&routerService, 0),
+			}
+		}
+	}
+
+	return services
+}
+
+// ProdContainerNames returns the names of the containers of the services box runs alongside the project's own
+// on its droplet
+func ProdContainerNames() []string {
+	names := []string{}
+	for i := range prodServices {
+		names = append(names, containerName(&prodServices[i], 0))
+	}
+
+	return names
+}
+
+// Where the engine listens on the droplet
+const remoteDockerSocket = "/var/run/docker.sock"
+
+// ProdDataDir is where the block storage volume is mounted on the droplet
+const ProdDataDir = "/mnt/data"
 
 // New returns a new instance of the runtime structure for the supplied project
 func New(mfst *manifest.Manifest, cfg *config.Config, isProduction bool) (*Runtime, error) {
@@ -127,25 +190,32 @@ func New(mfst *manifest.Manifest, cfg *config.Config, isProduction bool) (*Runti
 	}
 
 	ctx := context.Background()
-	if runInfo.Project != mfst.Project {
-		oldContainerIDs := []string{}
 
-		// The previous running project was not this project, so verify that no containers are running
-		containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
-		if err != nil {
-			return nil, err
-		}
+	// Verify that no containers belonging to another box project are running
+	containers, err := cli.ContainerList(
+		ctx,
+		types.ContainerListOptions{
+			Filters: managedFilter(),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, container := range containers {
-			for _, name := range container.Names {
-				if strings.HasPrefix(name, boxContainerPrefix) {
-					oldContainerIDs = append(oldContainerIDs, container.ID)
-					break
-				}
-			}
+	for _, container := range containers {
+		project := container.Labels[LabelProject]
+		if project != mfst.Project {
+			return nil, fmt.Errorf("The project \"%v\" is still running, please stop it before running another project.\n", project)
 		}
+	}
 
-		if len(oldContainerIDs) > 0 {
+	// Containers of a previous version of box belong to the project which last ran
+	if runInfo.Project != mfst.Project {
+		legacy, err := legacyContainers(ctx, cli, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(legacy) > 0 {
 			return nil, fmt.Errorf("The project \"%v\" is still running, please stop it before running another project.\n", runInfo.Project)
 		}
 	}
@@ -162,38 +232,81 @@ func New(mfst *manifest.Manifest, cfg *config.Config, isProduction bool) (*Runti
 
 	isInitialized = true
 	return &Runtime{
-		Manifest:   mfst,
-		Client:     cli,
-		Context:    ctx,
-		Production: isProduction,
-		Config:     cfg,
+		Manifest:       mfst,
+		Client:         cli,
+		Context:        ctx,
+		Production:     isProduction,
+		Config:         cfg,
+		StartupTimeout: DefaultStartupTimeout,
 	}, nil
 }
 
+// NewReadOnly returns an instance of the runtime structure for inspecting the supplied project.  Unlike New, it
+// may be used while another project is running, and doesn't record the project as the one which last ran.
+func NewReadOnly(mfst *manifest.Manifest) (*Runtime, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runtime{
+		Manifest:       mfst,
+		Client:         cli,
+		Context:        context.Background(),
+		StartupTimeout: DefaultStartupTimeout,
+	}, nil
+}
+
+// NewRemote returns an instance of the runtime structure for the project as deployed to its droplet, whose engine
+// is reached through the SSH connection to it.  Like NewReadOnly, it may be used while another project is running
+// locally.  Secrets are still decrypted here, when containers are created.
+func NewRemote(mfst *manifest.Manifest, cfg *config.Config, conn *sshconn.SSHConn) (*Runtime, error) {
+	cli, err := client.NewClientWithOpts(
+		// The host only names the socket, every connection is dialed through the SSH connection
+		client.WithHost("unix://"+remoteDockerSocket),
+		client.WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return conn.Conn.Dial("unix", remoteDockerSocket)
+		}),
+		client.WithAPIVersionNegotiation(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runtime{
+		Manifest:       mfst,
+		Client:         cli,
+		Context:        context.Background(),
+		Production:     true,
+		Config:         cfg,
+		StartupTimeout: DefaultStartupTimeout,
+		hostFS:         remoteFS{conn: conn},
+	}, nil
+}
+
+// StopAnyRunning stops and removes all containers belonging to the project
 func (rt *Runtime) StopAnyRunning() error {
 	containers, err := rt.Client.ContainerList(
 		rt.Context,
 		types.ContainerListOptions{
-			All: true,
+			All:     true,
+			Filters: rt.projectFilter(),
 		},
 	)
 	if err != nil {
 		return err
 	}
 
+	// Along with any created by a previous version of box, whose names would otherwise conflict
+	legacy, err := legacyContainers(rt.Context, rt.Client, true)
+	if err != nil {
+		return err
+	}
+	containers = append(containers, legacy...)
+
 	containerIDs := []string{}
 	for _, container := range containers {
-		for _, name := range container.Names {
-			if strings.HasPrefix(name, "/") {
-				// Remove the leading slash -- why...Docker?
-				name = name[1:]
-			}
-
-			if strings.HasPrefix(name, boxContainerPrefix) {
-				containerIDs = append(containerIDs, container.ID)
-				break
-			}
-		}
+		containerIDs = append(containerIDs, container.ID)
 	}
 
 	if len(containerIDs) > 0 {
@@ -235,6 +348,11 @@ func (rt *Runtime) Shutdown() error {
 		return err
 	}
 
+	err = rt.RemoveNetworks()
+	if err != nil {
+		return err
+	}
+
 	configDir, err := config.GetConfigDir()
 	if err != nil {
 		return err
@@ -247,27 +365,31 @@ func (rt *Runtime) Shutdown() error {
 
 // Start will create and start the required containers
 func (rt *Runtime) Start() error {
-	err := rt.StopAnyRunning()
+	activeServices, err := rt.activeServices()
 	if err != nil {
 		return err
 	}
 
-	var coreServices []manifest.Service
-	if rt.Production == true {
-		coreServices = prodServices
-	} else {
-		coreServices = devServices
+	// Fail before anything running is disrupted
+	if err := rt.checkSecrets(activeServices); err != nil {
+		return err
 	}
 
-	allServices := []*manifest.Service{}
-	for _, service := range coreServices {
-		allServices = append(allServices, &service)
+	err = rt.StopAnyRunning()
+	if err != nil {
+		return err
 	}
-	for _, service := range rt.Manifest.Services {
-		allServices = append(allServices, service)
+
+	coreServices := rt.coreServices()
+
+	allServices := []*manifest.Service{}
+	for i := range coreServices {
+		allServices = append(allServices, &coreServices[i])
 	}
+	allServices = append(allServices, activeServices...)
 
-	containerBodyByServiceName := map[string]container.ContainerCreateCreatedBody{}
+	containerBodiesByServiceName := map[string][]container.ContainerCreateCreatedBody{}
+	slotsByService := map[string][]int{}
 	var createErr error
 
 	// Get locally stored images
@@ -321,84 +443,66 @@ func (rt *Runtime) Start() error {
 		}
 	}
 
-	// Create all the services
-	for _, service := range allServices {
-		hostname := service.GetHostname()
-		containerName := fmt.Sprintf("%v%v", boxContainerPrefix, service.Name)
-
-		// If a routing exists, new containers automatically get a "_1" suffix,
-		// same goes for hostname
-		if service.Routing.Path.Pattern != "" {
-			hostname = fmt.Sprintf("%v_1", hostname)
-			containerName = fmt.Sprintf("%v_1", containerName)
-		}
+	err = rt.EnsureNetwork()
+	if err != nil {
+		return err
+	}
 
-		contConfig := container.Config{
-			Hostname:     hostname,
-			Env:          service.GetEnv(),
-			Image:        service.GetImage(rt.Config.ProjectNameHash()),
-			ExposedPorts: service.GetContainerPortSet(),
-		}
+	err = rt.EnsureVolumes()
+	if err != nil {
+		return err
+	}
 
-		var dataDir string
-		var err error
-		if rt.Production == true {
-			dataDir = prodDataDir
-		} else {
-			dataDir, err = rt.Config.DataDir()
-			if err != nil {
-				return err
-			}
-			if _, err := os.Stat(dataDir); err != nil {
-				return err
+	// The router starts before any routed service, so it has to start without upstreams, otherwise nginx
+	// would fail to resolve them
+	err = rt.writeRouterConfig(nil)
+	if err != nil {
+		return err
+	}
+
+	// Create all the services, core services aren't slotted
+	for i, service := range allServices {
+		slots := []int{0}
+		if i >= len(coreServices) {
+			slots = []int{}
+			for slot := 1; slot <= service.GetReplicas(); slot++ {
+				slots = append(slots, slot)
 			}
+			slotsByService[service.Name] = slots
 		}
-		mounts := service.GetHostMounts(dataDir)
-		for _, mount := range mounts {
-			if _, err := os.Stat(mount.Source); err != nil {
-				fmt.Printf("Preparing host bind mount point: %v\n", mount.Source)
-				// More than likely the mount point doesn't exist on the host, so make it
-				// It will be owned be the user running this command
-				err = os.MkdirAll(mount.Source, os.FileMode(755))
-				if err != nil {
-					return err
-				}
+
+		for _, slot := range slots {
+			fmt.Println("Creating container for service", service.Name)
+			var containerBody *container.ContainerCreateCreatedBody
+			containerBody, createErr = rt.CreateContainer(service, slot)
+			if createErr != nil {
+				break
 			}
-		}
 
-		hostConfig := container.HostConfig{
-			PortBindings: service.GetHostPortMap(),
-			Mounts:       mounts,
+			containerBodiesByServiceName[service.Name] = append(containerBodiesByServiceName[service.Name], *containerBody)
 		}
-
-		fmt.Println("Creating container for service", service.Name)
-		var containerBody container.ContainerCreateCreatedBody
-		containerBody, createErr = rt.Client.ContainerCreate(
-			rt.Context,
-			&contConfig,
-			&hostConfig,
-			nil,
-			nil,
-			containerName,
-		)
-
 		if createErr != nil {
 			break
 		}
-
-		containerBodyByServiceName[service.Name] = containerBody
 	}
 
 	if createErr != nil {
 		fmt.Println("Error creating container", createErr)
-		for _, containerBody := range containerBodyByServiceName {
-			fmt.Println("Removing container ", containerBody.ID)
-			rt.Client.ContainerRemove(rt.Context, containerBody.ID, types.ContainerRemoveOptions{})
+		for _, containerBodies := range containerBodiesByServiceName {
+			for _, containerBody := range containerBodies {
+				fmt.Println("Removing container ", containerBody.ID)
+				rt.Client.ContainerRemove(rt.Context, containerBody.ID, types.ContainerRemoveOptions{})
+			}
 		}
 		return fmt.Errorf("An error occurred while creating containers")
 	}
 
-	serviceTranches, err := makeDependencyTranches(coreServices)
+	manifestServices := []manifest.Service{}
+	for _, service := range activeServices {
+		manifestServices = append(manifestServices, *service)
+	}
+
+	serviceTranches, err := makeDependencyTranches(manifestServices)
 	if err != nil {
 		return err
 	}
@@ -410,20 +514,32 @@ func (rt *Runtime) Start() error {
 		tranches = append(tranches, tranche)
 	}
 
+	// The timeout covers every tranche, including time spent waiting for dependency conditions
+	ctx, cancel := context.WithTimeout(rt.Context, rt.StartupTimeout)
+	defer cancel()
+
 	for _, tranche := range tranches {
 
-		// All containers in a tranche get started together, each in a separate goroutine.
+		// All containers in a tranche get started together, each in a separate goroutine.  Each waits
+		// until its dependencies satisfy their conditions before starting.
 		group := new(errgroup.Group)
 		for _, service := range tranche {
+			service := service
 
 			group.Go(func() error {
-				fmt.Println("Starting container for", service.Name)
-				containerBody := containerBodyByServiceName[service.Name]
-				err := rt.Client.ContainerStart(rt.Context, containerBody.ID, types.ContainerStartOptions{})
+				err := rt.waitForDependencies(ctx, &service, containerBodiesByServiceName)
 				if err != nil {
-					return fmt.Errorf("Error starting container for service %v: %w", service.Name, err)
+					return err
+				}
+
+				for _, containerBody := range containerBodiesByServiceName[service.Name] {
+					fmt.Println("Starting container for", service.Name)
+					err = rt.Client.ContainerStart(rt.Context, containerBody.ID, types.ContainerStartOptions{})
+					if err != nil {
+						return fmt.Errorf("Error starting container for service %v: %w", service.Name, err)
+					}
+					fmt.Println("Successfully started container", containerBody.ID)
 				}
-				fmt.Println("Successfully started container", containerBody.ID)
 
 				return nil
 			})
@@ -440,49 +556,16 @@ func (rt *Runtime) Start() error {
 		}
 	}
 
-	fmt.Println("Containers running!")
-
-	return nil
-}
-
-func (rt *Runtime) Build() error {
-	dir, err := os.Getwd()
+	err = rt.writeRouterConfig(slotsByService)
 	if err != nil {
-		return fmt.Errorf("runtime.Build: %w", err)
+		return err
 	}
-
-	for serviceName, service := range rt.Manifest.Services {
-		if service.Build.Context != "" {
-			fmt.Println("Building image for", serviceName)
-			contextPath, err := filepath.Abs(filepath.Join(dir, service.Build.Context))
-			if err != nil {
-				return fmt.Errorf("runtime.Build: %w", err)
-			}
-			dockerfilePath := filepath.Join(contextPath, service.Build.Dockerfile)
-			dockerfileAbsPath, err := filepath.Abs(dockerfilePath)
-			if err != nil {
-				return fmt.Errorf("runtime.Build: %w", err)
-			}
-
-			image := service.GetImage(rt.Config.ProjectNameHash())
-			builder, err := cmd.New(
-				"docker",
-				"build",
-				"--file", dockerfileAbsPath,
-				"--tag", image,
-				".",
-			)
-			if err != nil {
-				return fmt.Errorf("runtime.Build: %w", err)
-			}
-
-			builder.CWD = contextPath
-			err = builder.Run()
-			if err != nil {
-				return fmt.Errorf("Error building %v: %w", serviceName, err)
-			}
-		}
+	err = rt.reloadRouter()
+	if err != nil {
+		return err
 	}
 
+	fmt.Println("Containers running!")
+
 	return nil
 }
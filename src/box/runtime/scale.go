@@ -0,0 +1,182 @@
+package runtime
+
+import (
+	"box/manifest"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Scale adjusts the number of running replicas of a service in a running project.  New replicas join the
+// router once they are ready, and surplus replicas leave the router before being stopped, so requests are
+// never routed to a container which isn't serving.
+func (rt *Runtime) Scale(serviceName string, replicas int) error {
+	service, ok := rt.Manifest.Services[serviceName]
+	if !ok {
+		return fmt.Errorf("Service %v is not defined in the manifest", serviceName)
+	}
+	if err := service.ValidateReplicas(replicas); err != nil {
+		return err
+	}
+
+	// The running project determines which services are active
+	profiles, running, err := rt.RunningProfiles()
+	if err != nil {
+		return err
+	}
+	if running == false {
+		return fmt.Errorf("The project \"%v\" is not running", rt.Manifest.Project)
+	}
+	rt.Profiles = profiles
+	env, err := rt.RunningEnvironment()
+	if err != nil {
+		return err
+	}
+	if env != "" && env != rt.environment() {
+		return fmt.Errorf("The project \"%v\" is running in %v, not %v", rt.Manifest.Project, env, rt.environment())
+	}
+	if reason := rt.Manifest.ExcludedReason(service, rt.environment(), rt.Profiles); reason != "" {
+		return fmt.Errorf("Service %v can't be scaled, %v", serviceName, reason)
+	}
+
+	containersByService, err := rt.serviceContainers()
+	if err != nil {
+		return err
+	}
+	slotsByService, err := rt.runningSlots()
+	if err != nil {
+		return err
+	}
+
+	current := slotsByService[serviceName]
+	if len(current) == replicas {
+		fmt.Printf("Service %v already has %v replicas\n", serviceName, replicas)
+		return nil
+	}
+
+	// Stopped replicas would otherwise hold on to their slots
+	for _, container := range containersByService[serviceName] {
+		if container.State != "running" {
+			fmt.Printf("Removing stopped container %v...", container.ID)
+			err = rt.Client.ContainerRemove(rt.Context, container.ID, types.ContainerRemoveOptions{})
+			if err != nil {
+				fmt.Println("Error")
+				return fmt.Errorf("Unable to remove stopped container %v: %w", container.ID, err)
+			}
+			fmt.Println("Done")
+		}
+	}
+
+	if replicas > len(current) {
+		slots, err := rt.addReplicas(service, current, replicas-len(current))
+		if err != nil {
+			return err
+		}
+		slotsByService[serviceName] = slots
+		err = rt.writeRouterConfig(slotsByService)
+		if err != nil {
+			return err
+		}
+		err = rt.reloadRouter()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Service %v scaled to %v replicas\n", serviceName, replicas)
+		return nil
+	}
+
+	// Replicas in the highest slots are removed first
+	keep := current[:replicas]
+	remove := current[replicas:]
+	slotsByService[serviceName] = keep
+	err = rt.writeRouterConfig(slotsByService)
+	if err != nil {
+		return err
+	}
+	err = rt.reloadRouter()
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range remove {
+		name := containerName(service, slot)
+		// The container's own stop timeout applies, allowing in-flight requests to complete
+		fmt.Printf("Stopping container %v...", name)
+		if err = rt.Client.ContainerStop(rt.Context, name, nil); err != nil {
+			fmt.Println("Error")
+			return fmt.Errorf("Unable to stop container %v: %w", name, err)
+		}
+		fmt.Println("Done")
+
+		fmt.Printf("Removing container %v...", name)
+		if err = rt.Client.ContainerRemove(rt.Context, name, types.ContainerRemoveOptions{}); err != nil {
+			fmt.Println("Error")
+			return fmt.Errorf("Unable to remove container %v: %w", name, err)
+		}
+		fmt.Println("Done")
+	}
+
+	fmt.Printf("Service %v scaled to %v replicas\n", serviceName, replicas)
+
+	return nil
+}
+
+// addReplicas starts count new replicas of the service in the lowest free slots, and waits for them to
+// become ready.  Should any fail, the new replicas are removed again.  All slots in use are returned.
+func (rt *Runtime) addReplicas(service *manifest.Service, current []int, count int) ([]int, error) {
+	inUse := map[int]bool{}
+	for _, slot := range current {
+		inUse[slot] = true
+	}
+
+	slots := append([]int{}, current...)
+	added := []string{}
+	rollback := func() {
+		for _, containerID := range added {
+			fmt.Println("Removing container", containerID)
+			rt.Client.ContainerRemove(rt.Context, containerID, types.ContainerRemoveOptions{Force: true})
+		}
+	}
+
+	for slot := 1; len(added) < count; slot++ {
+		if inUse[slot] {
+			continue
+		}
+
+		fmt.Println("Creating container for service", service.Name, "in slot", slot)
+		containerBody, err := rt.CreateContainer(service, slot)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		added = append(added, containerBody.ID)
+
+		err = rt.Client.ContainerStart(rt.Context, containerBody.ID, types.ContainerStartOptions{})
+		if err != nil {
+			rollback()
+			return nil, fmt.Errorf("Error starting container for service %v: %w", service.Name, err)
+		}
+		slots = append(slots, slot)
+	}
+
+	condition := manifest.ConditionServiceStarted
+	if service.HealthCheck != nil {
+		condition = manifest.ConditionServiceHealthy
+	}
+
+	ctx, cancel := context.WithTimeout(rt.Context, rt.StartupTimeout)
+	defer cancel()
+	for _, containerID := range added {
+		if err := rt.waitForCondition(ctx, service.Name, containerID, condition); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+
+	sort.Ints(slots)
+
+	return slots, nil
+}
@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"box/manifest"
+	"box/secrets"
+	"fmt"
+)
+
+// secretStore returns the project's secret store, loading it on first use
+func (rt *Runtime) secretStore() (*secrets.Store, error) {
+	if rt.secrets == nil {
+		store, err := secrets.Load(rt.Config.ProjectName)
+		if err != nil {
+			return nil, err
+		}
+		rt.secrets = store
+	}
+
+	return rt.secrets, nil
+}
+
+// resolveSecret decrypts the secret, which happens only as its container is created
+func (rt *Runtime) resolveSecret(name string) (string, error) {
+	store, err := rt.secretStore()
+	if err != nil {
+		return "", err
+	}
+
+	return store.Get(name)
+}
+
+// checkSecrets verifies that every secret referred to by the services is set, without decrypting any
+func (rt *Runtime) checkSecrets(services []*manifest.Service) error {
+	for _, service := range services {
+		names := service.GetSecrets()
+		if len(names) == 0 {
+			continue
+		}
+
+		store, err := rt.secretStore()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !store.Has(name) {
+				return fmt.Errorf("Service: %v\nSecret %v is not set, set it with: box secrets set %v", service.Name, name, name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"sort"
+)
+
+// ServiceStatus describes the state of a single manifest service
+type ServiceStatus struct {
+	Name     string
+	Replicas int
+	Running  int
+	Excluded string
+}
+
+// Status returns the state of every manifest service, sorted by name.  Services which don't run in the
+// current environment with the active profiles carry the reason they are excluded.
+func (rt *Runtime) Status() ([]ServiceStatus, error) {
+	slotsByService, err := rt.runningSlots()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []ServiceStatus{}
+	for _, service := range rt.Manifest.Services {
+		statuses = append(statuses, ServiceStatus{
+			Name:     service.Name,
+			Replicas: service.GetReplicas(),
+			Running:  len(slotsByService[service.Name]),
+			Excluded: rt.Manifest.ExcludedReason(service, rt.environment(), rt.Profiles),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses, nil
+}
@@ -0,0 +1,200 @@
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// Label identifying the manifest name of a named volume
+const LabelVolume = labelPrefix + "volume"
+
+// Directory on the block storage mount under which production volumes are placed
+const prodVolumeDir = "volumes"
+
+// VolumeStatus describes a named volume belonging to the project
+type VolumeStatus struct {
+	Name       string
+	EngineName string
+	Mountpoint string
+	Declared   bool
+	Created    bool
+	InUseBy    []string
+}
+
+// volumeName returns the engine name of a named volume declared in the manifest
+func (rt *Runtime) volumeName(name string) string {
+	return fmt.Sprintf("%v%v_%v", boxContainerPrefix, rt.Manifest.Project, name)
+}
+
+// projectVolumes returns all engine volumes belonging to the project, keyed by their manifest name
+func (rt *Runtime) projectVolumes() (map[string]*types.Volume, error) {
+	resp, err := rt.Client.VolumeList(rt.Context, rt.projectFilter())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list volumes: %w", err)
+	}
+
+	volumes := map[string]*types.Volume{}
+	for _, vol := range resp.Volumes {
+		volumes[vol.Labels[LabelVolume]] = vol
+	}
+
+	return volumes, nil
+}
+
+// EnsureVolumes creates any declared volumes which don't exist yet
+func (rt *Runtime) EnsureVolumes() error {
+	existing, err := rt.projectVolumes()
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for name := range rt.Manifest.Volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+
+		labels := rt.projectLabels()
+		labels[LabelVolume] = name
+		body := volume.VolumeCreateBody{
+			Name:   rt.volumeName(name),
+			Driver: "local",
+			Labels: labels,
+		}
+
+		// Block storage volumes are bind mounts of a directory on the block storage mount, managed by
+		// the engine like any other volume
+		if rt.Production == true && rt.Manifest.Volumes[name].BlockStorage {
+			device := filepath.Join(ProdDataDir, prodVolumeDir, name)
+			if _, err := rt.host().EnsureDir(device); err != nil {
+				return fmt.Errorf("Unable to make volume directory %v: %w", device, err)
+			}
+			body.DriverOpts = map[string]string{
+				"type":   "none",
+				"o":      "bind",
+				"device": device,
+			}
+		}
+
+		fmt.Printf("Creating volume %v...", name)
+		_, err := rt.Client.VolumeCreate(rt.Context, body)
+		if err != nil {
+			fmt.Println("Error")
+			return fmt.Errorf("Unable to create volume %v: %w", name, err)
+		}
+		fmt.Println("Done")
+	}
+
+	return nil
+}
+
+// volumeUsers returns the names of all containers, running or not, which mount the engine volume
+func (rt *Runtime) volumeUsers(engineName string) ([]string, error) {
+	containers, err := rt.Client.ContainerList(
+		rt.Context,
+		types.ContainerListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("volume", engineName)),
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list containers: %w", err)
+	}
+
+	users := []string{}
+	for _, container := range containers {
+		name := container.ID[:12]
+		if len(container.Names) > 0 {
+			// Remove the leading slash
+			name = container.Names[0][1:]
+		}
+		users = append(users, name)
+	}
+
+	return users, nil
+}
+
+// ListVolumes returns the status of every declared volume, as well as any volumes belonging to the
+// project which are no longer declared
+func (rt *Runtime) ListVolumes() ([]*VolumeStatus, error) {
+	existing, err := rt.projectVolumes()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := map[string]*VolumeStatus{}
+	for name := range rt.Manifest.Volumes {
+		statuses[name] = &VolumeStatus{
+			Name:       name,
+			EngineName: rt.volumeName(name),
+			Declared:   true,
+		}
+	}
+	for name, vol := range existing {
+		status, ok := statuses[name]
+		if !ok {
+			status = &VolumeStatus{Name: name}
+			statuses[name] = status
+		}
+		status.EngineName = vol.Name
+		status.Mountpoint = vol.Mountpoint
+		status.Created = true
+
+		status.InUseBy, err = rt.volumeUsers(vol.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := []*VolumeStatus{}
+	for _, status := range statuses {
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
+// RemoveVolume removes a named volume and its data.  Volumes which are mounted by any container,
+// running or stopped, are not removed.
+func (rt *Runtime) RemoveVolume(name string) error {
+	existing, err := rt.projectVolumes()
+	if err != nil {
+		return err
+	}
+
+	vol, ok := existing[name]
+	if !ok {
+		return fmt.Errorf("The project \"%v\" has no volume named %v", rt.Manifest.Project, name)
+	}
+
+	users, err := rt.volumeUsers(vol.Name)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("Volume %v is in use by %v, please shut down the project before removing it", name, users)
+	}
+
+	fmt.Printf("Removing volume %v...", name)
+	err = rt.Client.VolumeRemove(rt.Context, vol.Name, false)
+	if err != nil {
+		fmt.Println("Error")
+		return fmt.Errorf("Unable to remove volume %v: %w", name, err)
+	}
+	fmt.Println("Done")
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"box/config"
+	"box/manifest"
+	"box/runtime"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ScaleCmd struct {
+	Services       []string      `arg:"" name:"service=replicas" help:"Service name and number of replicas, eg: auth=3"`
+	StartupTimeout time.Duration `default:"5m" help:"Time allowed for new replicas to become ready"`
+	Remote         bool          `default:"false" help:"Scale the project as deployed to its droplet, with its production manifest"`
+}
+
+func (cmd *ScaleCmd) Run() error {
+	replicasByService := map[string]int{}
+	names := []string{}
+	for _, arg := range cmd.Services {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Scale target %v is incorrect, targets must be in the form of <service>=<replicas>", arg)
+		}
+		replicas, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("Scale target %v is incorrect, replicas must be a number", arg)
+		}
+		if _, ok := replicasByService[parts[0]]; !ok {
+			names = append(names, parts[0])
+		}
+		replicasByService[parts[0]] = replicas
+	}
+
+	env := manifest.EnvDev
+	if cmd.Remote == true {
+		env = manifest.EnvProd
+	}
+
+	mfst, err := loadManifest(env)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load(mfst.Project)
+	if err != nil {
+		return err
+	}
+
+	// Scaling applies to the running project only, the manifest determines the replicas on the next start
+	var rt *runtime.Runtime
+	if cmd.Remote == true {
+		if cfg.DropletPublicIP == "" {
+			return fmt.Errorf("Project %v has no droplet yet, create it with box mkremote first", cfg.ProjectName)
+		}
+		// The droplet's engine is reached through the SSH connection, it isn't exposed otherwise
+		fmt.Print("Connecting to the droplet...")
+		conn, err := connectDroplet(cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		fmt.Println("Done")
+
+		rt, err = runtime.NewRemote(mfst, cfg, conn)
+		if err != nil {
+			return err
+		}
+	} else {
+		rt, err = runtime.New(mfst, cfg, false)
+		if err != nil {
+			return err
+		}
+	}
+	rt.StartupTimeout = cmd.StartupTimeout
+
+	for _, name := range names {
+		err = rt.Scale(name, replicasByService[name])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
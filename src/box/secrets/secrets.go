@@ -0,0 +1,239 @@
+package secrets
+
+import (
+	"box/config"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"golang.org/x/crypto/nacl/box"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The private key never leaves the project's configuration directory, other than in an encrypted project
+// bundle, while the store holds nothing in plain text and may be copied anywhere
+const keyFileName = "secrets.key"
+
+// StoreFileName is the name of the file holding the project's sealed secrets, the only one shipped to its droplet
+const StoreFileName = "secrets.yml"
+
+// FileNames are the names of the files holding a project's secrets, within its configuration directory
+var FileNames []string = []string{keyFileName, StoreFileName}
+
+var NameRe *regexp.Regexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+type storeData struct {
+	PublicKey string            `yaml:"public_key"`
+	Secrets   map[string]string `yaml:"secrets"`
+}
+
+// Store holds a project's secrets, each sealed with the project's public key.  Values are only
+// decrypted on request.
+type Store struct {
+	projectDir string
+	publicKey  *[32]byte
+	sealed     map[string]string
+}
+
+// Load loads the secret store of the project, which is empty until the first secret is set
+func Load(projectName string) (*Store, error) {
+	configDir, err := config.GetConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	projectDir := filepath.Join(configDir, projectName)
+	if _, err := os.Stat(projectDir); err != nil {
+		return nil, fmt.Errorf("Project %v has not been initialized, run box init first", projectName)
+	}
+
+	store := &Store{
+		projectDir: projectDir,
+		sealed:     map[string]string{},
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(projectDir, StoreFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+
+	sd := storeData{}
+	if err := yaml.Unmarshal(data, &sd); err != nil {
+		return nil, fmt.Errorf("Unable to process secret store: %w", err)
+	}
+	store.publicKey, err = decodeKey(sd.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("Secret store public key is invalid: %w", err)
+	}
+	if sd.Secrets != nil {
+		store.sealed = sd.Secrets
+	}
+
+	return store, nil
+}
+
+func decodeKey(encoded string) (*[32]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 32 {
+		return nil, fmt.Errorf("Key must be 32 bytes long")
+	}
+
+	key := [32]byte{}
+	copy(key[:], data)
+
+	return &key, nil
+}
+
+// ensureKey generates the project's key pair if it doesn't have one yet
+func (s *Store) ensureKey() error {
+	if s.publicKey != nil {
+		return nil
+	}
+
+	keyFilename := filepath.Join(s.projectDir, keyFileName)
+	if _, err := os.Stat(keyFilename); err == nil {
+		return fmt.Errorf("Secret key %v exists without a secret store, remove it to start a new store", keyFilename)
+	}
+
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("secrets.ensureKey: %w", err)
+	}
+
+	err = ioutil.WriteFile(keyFilename, []byte(base64.StdEncoding.EncodeToString(privateKey[:])+"\n"), os.FileMode(0600))
+	if err != nil {
+		return fmt.Errorf("Unable to write secret key %v: %w", keyFilename, err)
+	}
+	s.publicKey = publicKey
+
+	return nil
+}
+
+// privateKey reads the project's private key
+func (s *Store) privateKey() (*[32]byte, error) {
+	keyFilename := filepath.Join(s.projectDir, keyFileName)
+	data, err := ioutil.ReadFile(keyFilename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read secret key %v: %w", keyFilename, err)
+	}
+
+	key, err := decodeKey(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("Secret key %v is invalid: %w", keyFilename, err)
+	}
+
+	return key, nil
+}
+
+// save writes the store, which only holds sealed values
+func (s *Store) save() error {
+	sd := storeData{
+		PublicKey: base64.StdEncoding.EncodeToString(s.publicKey[:]),
+		Secrets:   s.sealed,
+	}
+	data, err := yaml.Marshal(&sd)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(s.projectDir, StoreFileName), data, os.FileMode(0600))
+}
+
+// StoreData returns the contents of the project's secret store, which is nil until the first secret is set.  The
+// store only holds sealed values, so it's shipped to the project's droplet as is, while the key never leaves this
+// machine: secrets are decrypted here when box creates the droplet's containers.
+func (s *Store) StoreData() ([]byte, error) {
+	if s.publicKey == nil {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(s.projectDir, StoreFileName))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read secret store %v: %w", StoreFileName, err)
+	}
+
+	return data, nil
+}
+
+// Set seals the value and saves it under the name, replacing any existing value
+func (s *Store) Set(name, value string) error {
+	if !NameRe.Match([]byte(name)) {
+		return fmt.Errorf("Secret name \"%v\" must contain only alphanumerics and underscores, and must not begin with a digit", name)
+	}
+
+	if err := s.ensureKey(); err != nil {
+		return err
+	}
+
+	sealed, err := box.SealAnonymous(nil, []byte(value), s.publicKey, rand.Reader)
+	if err != nil {
+		return fmt.Errorf("secrets.Set: %w", err)
+	}
+	s.sealed[name] = base64.StdEncoding.EncodeToString(sealed)
+
+	return s.save()
+}
+
+// Get decrypts the value of the secret
+func (s *Store) Get(name string) (string, error) {
+	encoded, ok := s.sealed[name]
+	if !ok {
+		return "", fmt.Errorf("Secret %v is not set, set it with: box secrets set %v", name, name)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("Secret %v is corrupt: %w", name, err)
+	}
+
+	privateKey, err := s.privateKey()
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := box.OpenAnonymous(nil, sealed, s.publicKey, privateKey)
+	if !ok {
+		return "", fmt.Errorf("Secret %v can't be decrypted with the project's secret key", name)
+	}
+
+	return string(value), nil
+}
+
+// Remove removes the secret
+func (s *Store) Remove(name string) error {
+	if _, ok := s.sealed[name]; !ok {
+		return fmt.Errorf("Secret %v is not set", name)
+	}
+	delete(s.sealed, name)
+
+	return s.save()
+}
+
+// Has returns true if the secret is set
+func (s *Store) Has(name string) bool {
+	_, ok := s.sealed[name]
+	return ok
+}
+
+// Names returns the names of all secrets in the store, sorted
+func (s *Store) Names() []string {
+	names := []string{}
+	for name := range s.sealed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
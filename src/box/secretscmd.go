@@ -0,0 +1,247 @@
+package main
+
+import (
+	"box/config"
+	"box/manifest"
+	"box/secrets"
+	"box/sshconn"
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moby/term"
+)
+
+type SecretsCmd struct {
+	Set  SecretsSetCmd  `cmd:"" help:"Encrypt and store a secret, which services refer to as secret:NAME in their environment"`
+	Get  SecretsGetCmd  `cmd:"" help:"Decrypt and print a secret"`
+	Rm   SecretsRmCmd   `cmd:"" help:"Remove a secret"`
+	Ls   SecretsLsCmd   `cmd:"" help:"List the project's secrets and the services which refer to them"`
+	Push SecretsPushCmd `cmd:"" help:"Ship the project's secrets to its droplet, still encrypted, once they've changed"`
+}
+
+type SecretsSetCmd struct {
+	Name  string `arg:"" help:"Secret name"`
+	Value string `arg:"" optional:"" help:"Secret value, read from standard input when omitted so that it's kept out of the shell history"`
+}
+
+type SecretsGetCmd struct {
+	Name string `arg:"" help:"Secret name"`
+}
+
+type SecretsRmCmd struct {
+	Name string `arg:"" help:"Secret name"`
+}
+
+type SecretsLsCmd struct {
+}
+
+type SecretsPushCmd struct {
+	Project string `help:"Project name, defaults to the project in the current directory"`
+}
+
+// loadSecrets returns the manifest and secret store of the project in the current directory
+func loadSecrets() (*manifest.Manifest, *secrets.Store, error) {
+	mfst, err := loadManifest(manifest.EnvDev)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := secrets.Load(mfst.Project)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return mfst, store, nil
+}
+
+// readHiddenInput reads a value from standard input, prompting for it without echo when input is a terminal
+func readHiddenInput(prompt string) (string, error) {
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	}
+
+	state, err := term.SaveState(fd)
+	if err != nil {
+		return "", err
+	}
+	if err := term.DisableEcho(fd, state); err != nil {
+		return "", err
+	}
+	defer term.RestoreTerminal(fd, state)
+
+	fmt.Print(prompt)
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(value, "\n"), nil
+}
+
+func (cmd *SecretsSetCmd) Run() error {
+	_, store, err := loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	value := cmd.Value
+	if value == "" {
+		value, err = readHiddenInput(fmt.Sprintf("Value for %v: ", cmd.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Storing secret %v...", cmd.Name)
+	if err := store.Set(cmd.Name, value); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+func (cmd *SecretsGetCmd) Run() error {
+	_, store, err := loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	value, err := store.Get(cmd.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+
+	return nil
+}
+
+func (cmd *SecretsRmCmd) Run() error {
+	mfst, store, err := loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Removing secret %v...", cmd.Name)
+	if err := store.Remove(cmd.Name); err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	for _, svc := range mfst.Services {
+		for _, name := range svc.GetSecrets() {
+			if name == cmd.Name {
+				fmt.Printf("Service %v still refers to %v, and won't start until it's set again\n", svc.Name, cmd.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (cmd *SecretsLsCmd) Run() error {
+	mfst, store, err := loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	usedBy := map[string][]string{}
+	for _, svc := range mfst.Services {
+		for _, name := range svc.GetSecrets() {
+			usedBy[name] = append(usedBy[name], svc.Name)
+		}
+	}
+
+	names := store.Names()
+	// Secrets which are referred to but not set are listed too, since the services won't start without them
+	for name := range usedBy {
+		if !store.Has(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSTATUS\tUSED BY")
+	for _, name := range names {
+		status := "set"
+		if !store.Has(name) {
+			status = "not set"
+		}
+		services := usedBy[name]
+		sort.Strings(services)
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", name, status, strings.Join(services, ", "))
+	}
+	writer.Flush()
+
+	return nil
+}
+
+// pushSecrets writes the project's sealed secret store to the admin user's configuration directory on the droplet.
+// The key isn't shipped, since nothing on the droplet decrypts secrets.  Nothing is written while the project has
+// no secrets.
+func pushSecrets(conn *sshconn.SSHConn, store *secrets.Store, projectName string) error {
+	data, err := store.StoreData()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+
+	fmt.Print("Shipping the project's sealed secrets to the droplet...")
+	configDir := path.Join("/home", adminUser, config.ConfigDirName)
+	projectDir := path.Join(configDir, projectName)
+	err = conn.Run([]string{fmt.Sprintf("mkdir -p -m 0700 '%v'", projectDir)})
+	if err != nil {
+		return err
+	}
+	err = conn.WriteFile(path.Join(projectDir, secrets.StoreFileName), data, os.FileMode(0600))
+	if err != nil {
+		return err
+	}
+	err = conn.Run([]string{fmt.Sprintf("chown -R %v:%v '%v'", adminUser, adminUser, configDir)})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Done")
+
+	return nil
+}
+
+func (cmd *SecretsPushCmd) Run() error {
+	cfg, err := loadConfig(cmd.Project)
+	if err != nil {
+		return err
+	}
+	if cfg.DropletPublicIP == "" {
+		return fmt.Errorf("Project %v has no droplet yet, its secrets are shipped when it's created with box mkremote", cfg.ProjectName)
+	}
+	store, err := secrets.Load(cfg.ProjectName)
+	if err != nil {
+		return err
+	}
+	if len(store.Names()) == 0 {
+		return fmt.Errorf("Project %v has no secrets, set one first with box secrets set", cfg.ProjectName)
+	}
+
+	conn, err := connectDroplet(cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return pushSecrets(conn, store, cfg.ProjectName)
+}
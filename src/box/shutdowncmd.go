@@ -3,21 +3,13 @@ package main
 import (
 	"box/manifest"
 	"box/runtime"
-	"os"
-	"path/filepath"
 )
 
 type ShutdownCmd struct {
 }
 
 func (cmd *ShutdownCmd) Run() error {
-	dirName, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-
-	manifestFilename := filepath.Join(dirName, "box.yml")
-	mfst, err := manifest.NewManifest(manifestFilename)
+	mfst, err := loadManifest(manifest.EnvDev)
 	if err != nil {
 		return err
 	}
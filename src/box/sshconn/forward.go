@@ -0,0 +1,47 @@
+package sshconn
+
+import (
+	"io"
+	"net"
+)
+
+// Forward listens on a local port chosen by the system, forwarding every connection made to it to remoteAddr
+// as seen from the remote server, until the listener is closed.  The port is found from the listener's address.
+func (conn *SSHConn) Forward(remoteAddr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			local, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer local.Close()
+				remote, err := conn.Conn.Dial("tcp", remoteAddr)
+				if err != nil {
+					return
+				}
+				defer remote.Close()
+
+				// Done once either side hangs up
+				done := make(chan struct{}, 2)
+				go func() {
+					io.Copy(remote, local)
+					done <- struct{}{}
+				}()
+				go func() {
+					io.Copy(local, remote)
+					done <- struct{}{}
+				}()
+				<-done
+			}()
+		}
+	}()
+
+	return listener, nil
+}
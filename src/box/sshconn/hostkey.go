@@ -0,0 +1,51 @@
+package sshconn
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// pinnedHostKeyCallback returns a host key callback which trusts a host's key the first time it's seen,
+// recording it in the known hosts file, and refuses any other key for that host afterwards
+func pinnedHostKeyCallback(knownHostsFilename string) (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(knownHostsFilename); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(knownHostsFilename, []byte{}, os.FileMode(0600)); err != nil {
+			return nil, err
+		}
+	}
+
+	check, err := knownhosts.New(knownHostsFilename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to process known hosts file %v: %w", knownHostsFilename, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf(
+				"The host key of %v doesn't match the one pinned in %v.  If the droplet was replaced, remove its line from the file",
+				hostname,
+				knownHostsFilename,
+			)
+		}
+
+		file, err := os.OpenFile(knownHostsFilename, os.O_APPEND|os.O_WRONLY, os.FileMode(0600))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = fmt.Fprintln(file, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+
+		return err
+	}, nil
+}
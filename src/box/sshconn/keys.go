@@ -0,0 +1,217 @@
+package sshconn
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// GenerateSigner returns a signer for a new ed25519 key, along with the seed from which SignerFromSeed
+// recreates it
+func GenerateSigner() (*SSHSigner, []byte, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seed := privateKey.Seed()
+	signer, err := SignerFromSeed(seed)
+
+	return signer, seed, err
+}
+
+// SignerFromSeed returns a signer for the ed25519 key of the seed
+func SignerFromSeed(seed []byte) (*SSHSigner, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("An ed25519 seed must be %v bytes long", ed25519.SeedSize)
+	}
+
+	signer, err := ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		return nil, err
+	}
+
+	return &SSHSigner{
+		Signer: signer,
+		Conn:   nil,
+	}, nil
+}
+
+// marshalPrivateKey encodes an ed25519 private key in the unencrypted OpenSSH format
+func marshalPrivateKey(privateKey ed25519.PrivateKey, comment string) ([]byte, error) {
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	// Repeated check bytes tell whether the key was decrypted correctly
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return nil, err
+	}
+	checkValue := binary.BigEndian.Uint32(check)
+	block := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{checkValue, checkValue, ssh.KeyAlgoED25519, privateKey.Public().(ed25519.PublicKey), privateKey, comment})
+	for i := 1; len(block)%8 != 0; i++ {
+		block = append(block, byte(i))
+	}
+
+	data := ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, publicKey.Marshal(), block})
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: append([]byte("openssh-key-v1\x00"), data...),
+	}), nil
+}
+
+// WriteKeyPair generates an ed25519 key, writing the private key in OpenSSH format to filename and its
+// public key alongside it with a .pub extension
+func WriteKeyPair(filename, comment string) (ed25519.PrivateKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	privateData, err := marshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	publicData := fmt.Sprintf("%v %v\n", strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))), comment)
+
+	if err := ioutil.WriteFile(filename, privateData, os.FileMode(0600)); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(fmt.Sprintf("%v.pub", filename), []byte(publicData), os.FileMode(0644)); err != nil {
+		return nil, err
+	}
+
+	return privateKey, nil
+}
+
+// ProtectKey encrypts the private key file with a passphrase, which ssh-keygen prompts for
+func ProtectKey(filename string) error {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		return fmt.Errorf("ssh-keygen is required to protect a key with a passphrase: %w", err)
+	}
+
+	cmd := exec.Command("ssh-keygen", "-p", "-f", filename)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// AddToAgent loads the private key into the running SSH agent
+func AddToAgent(privateKey interface{}, comment string) error {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return errors.New("No SSH agent is running")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return agent.NewClient(conn).Add(agent.AddedKey{
+		PrivateKey: privateKey,
+		Comment:    comment,
+	})
+}
+
+// AuthorizedKey returns the signer's public key as a line of an authorized_keys file, followed by the
+// comment
+func (signer *SSHSigner) AuthorizedKey(comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.Signer.PublicKey())))
+
+	return fmt.Sprintf("%v %v", line, comment)
+}
+
+// userNameRe matches the user names whose authorized keys can be managed
+var userNameRe *regexp.Regexp = regexp.MustCompile("^[a-z_][a-z0-9_-]*$")
+
+// AuthorizeKey adds the authorized_keys line to those of the user, unless it's already present.  Managing
+// another user's keys requires connecting as root.
+func (conn *SSHConn) AuthorizeKey(username, authorizedKey string) error {
+	if !userNameRe.MatchString(username) {
+		return fmt.Errorf("Invalid user name: %v", username)
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey)); err != nil {
+		return fmt.Errorf("Invalid public key: %w", err)
+	}
+	if strings.ContainsAny(authorizedKey, "'\n") {
+		return fmt.Errorf("Public keys must be a single line, without quotes")
+	}
+
+	return conn.Run([]string{
+		fmt.Sprintf("mkdir -p ~%v/.ssh", username),
+		fmt.Sprintf("touch ~%v/.ssh/authorized_keys", username),
+		fmt.Sprintf("chmod 700 ~%v/.ssh", username),
+		fmt.Sprintf("chmod 600 ~%v/.ssh/authorized_keys", username),
+		fmt.Sprintf("chown -R %v: ~%v/.ssh", username, username),
+		fmt.Sprintf("(grep -qxF '%v' ~%v/.ssh/authorized_keys || echo '%v' >> ~%v/.ssh/authorized_keys)", authorizedKey, username, authorizedKey, username),
+	})
+}
+
+// RevokeKeyByComment removes the user's authorized keys which end with the comment
+func (conn *SSHConn) RevokeKeyByComment(username, comment string) error {
+	if !userNameRe.MatchString(username) {
+		return fmt.Errorf("Invalid user name: %v", username)
+	}
+	for _, c := range comment {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:@", c)) {
+			return fmt.Errorf("Key comments may only contain letters, numbers and any of -_.:@")
+		}
+	}
+
+	return conn.Run([]string{
+		fmt.Sprintf("(test ! -f ~%v/.ssh/authorized_keys || sed -i '/ %v$/d' ~%v/.ssh/authorized_keys)", username, strings.ReplaceAll(comment, ".", "\\."), username),
+	})
+}
+
+// RevokeKey removes the user's authorized keys which hold the public key, whatever their comment
+func (conn *SSHConn) RevokeKey(username string, publicKey ssh.PublicKey) error {
+	if !userNameRe.MatchString(username) {
+		return fmt.Errorf("Invalid user name: %v", username)
+	}
+	blob := base64.StdEncoding.EncodeToString(publicKey.Marshal())
+	filename := fmt.Sprintf("~%v/.ssh/authorized_keys", username)
+
+	return conn.Run([]string{
+		fmt.Sprintf(
+			"(test ! -f %v || { grep -vF ' %v' %v > %v.box || true; cat %v.box > %v; rm -f %v.box; })",
+			filename, blob, filename, filename, filename, filename, filename,
+		),
+	})
+}
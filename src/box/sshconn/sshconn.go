@@ -1,6 +1,7 @@
 package sshconn
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/moby/term"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/agent"
 )
@@ -16,6 +18,8 @@ import (
 type SSHConn struct {
 	Conn      *ssh.Client
 	SSHSigner *SSHSigner
+	// Commands are run as root through sudo, for a user which isn't root
+	sudo bool
 }
 
 type SSHSigner struct {
@@ -23,7 +27,9 @@ type SSHSigner struct {
 	Signer ssh.Signer
 }
 
-// GetSigner returns an SSH signer object for use with SSH connections
+// GetSigner returns an SSH signer object for use with SSH connections.  RSA, ECDSA and ed25519 keys are
+// supported, in OpenSSH or PEM format.  An encrypted key is used through the SSH agent when it holds the key,
+// otherwise its passphrase is prompted for.
 func GetSigner(privkeyFilename string) (*SSHSigner, error) {
 	var signer ssh.Signer
 	var err error
@@ -33,44 +39,42 @@ func GetSigner(privkeyFilename string) (*SSHSigner, error) {
 	}
 
 	signer, err = ssh.ParsePrivateKey(keyData)
-	if _, ok := err.(*ssh.PassphraseMissingError); ok == true {
-		pubkeyFilename := fmt.Sprintf("%v.pub", privkeyFilename)
-		pubkeyData, err := ioutil.ReadFile(pubkeyFilename)
-		if err != nil {
-			fmt.Println("Unable to locate corresponding public key file", pubkeyFilename)
-			return nil, err
-		}
-		pubKey, _, _, _, err := ssh.ParseAuthorizedKey(pubkeyData)
-		myPubKeyBlob := pubKey.Marshal()
-		if err != nil {
-			return nil, err
+	if passphraseErr, ok := err.(*ssh.PassphraseMissingError); ok == true {
+		// OpenSSH format keys hold their public key, others have it alongside
+		pubKey := passphraseErr.PublicKey
+		if pubKey == nil {
+			pubkeyFilename := fmt.Sprintf("%v.pub", privkeyFilename)
+			pubkeyData, err := ioutil.ReadFile(pubkeyFilename)
+			if err != nil {
+				fmt.Println("Unable to locate corresponding public key file", pubkeyFilename)
+				return nil, err
+			}
+			pubKey, _, _, _, err = ssh.ParseAuthorizedKey(pubkeyData)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		socket := os.Getenv("SSH_AUTH_SOCK")
-		if socket == "" {
-			return nil, errors.New("No SSH agent available to process encrypted private key")
+		sshSigner, agentErr := getAgentSigner(pubKey)
+		if agentErr == nil {
+			return sshSigner, nil
 		}
-		conn, err := net.Dial("unix", socket)
-		if err != nil {
-			return nil, err
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return nil, agentErr
 		}
-		agentClient := agent.NewClient(conn)
-		signers, err := agentClient.Signers()
+		passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for %v: ", privkeyFilename))
 		if err != nil {
 			return nil, err
 		}
-		for _, signer = range signers {
-			signerPubKey := signer.PublicKey()
-			blob := signerPubKey.Marshal()
-			if bytes.Compare(blob, myPubKeyBlob) == 0 {
-				return &SSHSigner{
-					Signer: signer,
-					Conn:   conn,
-				}, nil
-			}
+		signer, err := ssh.ParsePrivateKeyWithPassphrase(keyData, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decrypt private key %v: %w", privkeyFilename, err)
 		}
 
-		return nil, errors.New("This key is not being managed by the SSH agent")
+		return &SSHSigner{
+			Signer: signer,
+			Conn:   nil,
+		}, nil
 	}
 	if err != nil {
 		return nil, err
@@ -82,14 +86,77 @@ func GetSigner(privkeyFilename string) (*SSHSigner, error) {
 	}, nil
 }
 
-// NewSSHConn returns a new SSH connection
-func NewSSHConn(signer *SSHSigner, username, hostname string) (*SSHConn, error) {
+// getAgentSigner returns the SSH agent's signer for the public key
+func getAgentSigner(pubKey ssh.PublicKey) (*SSHSigner, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("No SSH agent available to process encrypted private key")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	agentClient := agent.NewClient(conn)
+	signers, err := agentClient.Signers()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	myPubKeyBlob := pubKey.Marshal()
+	for _, signer := range signers {
+		if bytes.Compare(signer.PublicKey().Marshal(), myPubKeyBlob) == 0 {
+			return &SSHSigner{
+				Signer: signer,
+				Conn:   conn,
+			}, nil
+		}
+	}
+	conn.Close()
+
+	return nil, errors.New("This key is not being managed by the SSH agent")
+}
+
+// readPassphrase prompts for a passphrase without echo
+func readPassphrase(prompt string) (string, error) {
+	fd := os.Stdin.Fd()
+	state, err := term.SaveState(fd)
+	if err != nil {
+		return "", err
+	}
+	if err := term.DisableEcho(fd, state); err != nil {
+		return "", err
+	}
+	defer term.RestoreTerminal(fd, state)
+
+	fmt.Print(prompt)
+	passphrase, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(passphrase, "\n"), nil
+}
+
+// NewSSHConn returns a new SSH connection.  The host's key is pinned in knownHostsFilename the first time
+// it's seen, and any other key is refused afterwards.  Without a known hosts file any key is accepted,
+// which is only meant for short lived hosts.
+func NewSSHConn(signer *SSHSigner, username, hostname, knownHostsFilename string) (*SSHConn, error) {
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if knownHostsFilename != "" {
+		var err error
+		hostKeyCallback, err = pinnedHostKeyCallback(knownHostsFilename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	config := &ssh.ClientConfig{
 		User: username,
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(signer.Signer),
 		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // Yes, this is a bad practice
+		HostKeyCallback: hostKeyCallback,
 	}
 
 	conn, err := ssh.Dial("tcp", fmt.Sprintf("%v:22", hostname), config)
@@ -115,10 +182,56 @@ func (conn *SSHConn) Run(commands []string) error {
 	session.Stdout = os.Stdout
 	session.Stderr = os.Stderr
 
-	err = session.Run(strings.Join(commands, " && "))
+	err = session.Run(conn.command(strings.Join(commands, " && ")))
 	return err
 }
 
+// UseSudo runs all subsequent commands as root through sudo, which the user must be allowed without a password
+func (conn *SSHConn) UseSudo() {
+	conn.sudo = true
+}
+
+// command returns the command as it's run on the remote server
+func (conn *SSHConn) command(command string) string {
+	if !conn.sudo {
+		return command
+	}
+
+	return fmt.Sprintf("sudo -n sh -c '%v'", strings.ReplaceAll(command, "'", `'\''`))
+}
+
+// Output runs the command on the remote server and returns its standard output
+func (conn *SSHConn) Output(command string) ([]byte, error) {
+	session, err := conn.Conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	session.Stderr = os.Stderr
+
+	return session.Output(conn.command(command))
+}
+
+// WriteFile writes data to the remote file, which is given the permission bits of mode
+func (conn *SSHConn) WriteFile(filename string, data []byte, mode os.FileMode) error {
+	if strings.ContainsAny(filename, "'\n") {
+		return fmt.Errorf("Invalid remote filename: %v", filename)
+	}
+
+	session, err := conn.Conn.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = bytes.NewReader(data)
+	session.Stderr = os.Stderr
+
+	// The file is created without group or other permissions, so it's never readable by others while written
+	return session.Run(conn.command(fmt.Sprintf("umask 077; cat > '%v' && chmod %o '%v'", filename, mode.Perm(), filename)))
+}
+
 // Close closes the underlying SSH connection
 func (conn *SSHConn) Close() {
 	conn.Conn.Close()
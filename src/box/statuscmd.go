@@ -0,0 +1,63 @@
+package main
+
+import (
+	"box/manifest"
+	"box/runtime"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type StatusCmd struct {
+	Profile []string `help:"Profiles to report against when the project isn't running, may be repeated"`
+}
+
+func (cmd *StatusCmd) Run() error {
+	mfst, err := loadManifest(manifest.EnvDev)
+	if err != nil {
+		return err
+	}
+
+	// Status is always reported for the local project, even while another one is running
+	rt, err := runtime.NewReadOnly(mfst)
+	if err != nil {
+		return err
+	}
+
+	profiles, running, err := rt.RunningProfiles()
+	if err != nil {
+		return err
+	}
+	if running {
+		rt.Profiles = profiles
+		fmt.Printf("Project %v is running", mfst.Project)
+	} else {
+		rt.Profiles = cmd.Profile
+		fmt.Printf("Project %v is not running", mfst.Project)
+	}
+	if len(rt.Profiles) > 0 {
+		fmt.Printf(" with profiles %v", strings.Join(rt.Profiles, ", "))
+	}
+	fmt.Println()
+
+	statuses, err := rt.Status()
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "SERVICE\tREPLICAS\tSTATUS\tREASON")
+	for _, status := range statuses {
+		state := "stopped"
+		if status.Excluded != "" {
+			state = "excluded"
+		} else if status.Running > 0 {
+			state = "running"
+		}
+		fmt.Fprintf(writer, "%v\t%v/%v\t%v\t%v\n", status.Name, status.Running, status.Replicas, state, status.Excluded)
+	}
+	writer.Flush()
+
+	return nil
+}
@@ -0,0 +1,28 @@
+// schemagen writes the JSON Schema for box.yml to the file named by its argument
+package main
+
+import (
+	"box/manifest"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: schemagen <output file>")
+		os.Exit(1)
+	}
+
+	schema, err := manifest.JSONSchema()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = ioutil.WriteFile(os.Args[1], schema, os.FileMode(0644))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
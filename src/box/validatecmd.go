@@ -0,0 +1,23 @@
+package main
+
+import (
+	"box/manifest"
+	"fmt"
+)
+
+type ValidateCmd struct {
+	Env string `default:"dev" enum:"dev,prod" help:"Runtime environment whose override is layered over the base manifest"`
+}
+
+func (cmd *ValidateCmd) Run() error {
+	mfst, err := loadManifest(cmd.Env)
+	if err != nil {
+		if errs, ok := err.(manifest.Errors); ok {
+			return fmt.Errorf("%v\n\nFound %v problems in the manifest", errs, len(errs))
+		}
+		return err
+	}
+
+	fmt.Printf("Manifest for project %v is valid (%v services)\n", mfst.Project, len(mfst.Services))
+	return nil
+}
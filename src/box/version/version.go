@@ -0,0 +1,6 @@
+package version
+
+// Version is the release version of box.  It can be overridden at build time using:
+//
+//	go build -ldflags "-X box/version.Version=<version>"
+var Version = "0.1.0-dev"
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"box/manifest"
+	"box/runtime"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type VolumeCmd struct {
+	Ls     VolumeLsCmd     `cmd:"" help:"List the project's named volumes"`
+	Create VolumeCreateCmd `cmd:"" help:"Create any declared volumes which don't exist yet"`
+	Rm     VolumeRmCmd     `cmd:"" help:"Remove a named volume and all of its data"`
+}
+
+type VolumeLsCmd struct {
+}
+
+type VolumeCreateCmd struct {
+}
+
+type VolumeRmCmd struct {
+	Name string `arg:"" help:"Volume name, as declared in the manifest"`
+}
+
+// newVolumeRuntime returns a runtime for the project in the current directory, for volume management
+func newVolumeRuntime() (*runtime.Runtime, error) {
+	mfst, err := loadManifest(manifest.EnvDev)
+	if err != nil {
+		return nil, err
+	}
+
+	// Volumes are always managed locally
+	return runtime.New(mfst, nil, false)
+}
+
+func (cmd *VolumeLsCmd) Run() error {
+	mfst, err := loadManifest(manifest.EnvDev)
+	if err != nil {
+		return err
+	}
+	// Listing is allowed while another project is running
+	rt, err := runtime.NewReadOnly(mfst)
+	if err != nil {
+		return err
+	}
+
+	volumes, err := rt.ListVolumes()
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tENGINE NAME\tSTATUS\tIN USE BY")
+	for _, volume := range volumes {
+		status := "created"
+		if !volume.Created {
+			status = "not created"
+		} else if !volume.Declared {
+			status = "undeclared"
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", volume.Name, volume.EngineName, status, strings.Join(volume.InUseBy, ", "))
+	}
+	writer.Flush()
+
+	return nil
+}
+
+func (cmd *VolumeCreateCmd) Run() error {
+	rt, err := newVolumeRuntime()
+	if err != nil {
+		return err
+	}
+
+	return rt.EnsureVolumes()
+}
+
+func (cmd *VolumeRmCmd) Run() error {
+	rt, err := newVolumeRuntime()
+	if err != nil {
+		return err
+	}
+
+	return rt.RemoveVolume(cmd.Name)
+}